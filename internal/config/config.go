@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"time"
 
 	"github.com/xxnuo/vibego/internal/utils"
 	"github.com/xxnuo/vibego/internal/version"
@@ -25,6 +26,19 @@ type Config struct {
 
 	AllowWAN         bool
 	DisableLogToFile bool
+	RecordTerminals  bool
+	NotifyWebhook    string
+
+	PersistentTerminals      bool
+	TerminalExitedTimeout    time.Duration
+	TerminalIdleTimeout      time.Duration
+	TerminalSessionRetention time.Duration
+	TerminalResizePolicy     string
+	TerminalPingInterval     time.Duration
+	TerminalPongTimeout      time.Duration
+	TerminalEnvAllow         string
+	TerminalEnvDeny          string
+	TerminalCgroupRoot       string
 
 	OS           string
 	DefaultShell string
@@ -59,6 +73,18 @@ func GetConfig() *Config {
 	flag.BoolVar(&cfg.AllowWAN, "a", utils.GetBoolEnv("VG_ALLOW_WAN", true), "Allow WAN access(shorthand)")
 	flag.StringVar(&cfg.CORSOrigins, "cors-origins", utils.GetEnv("VG_CORS_ORIGINS", "*"), "CORS origins")
 	flag.BoolVar(&cfg.DisableLogToFile, "disable-log-to-file", utils.GetBoolEnv("VG_DISABLE_LOG_TO_FILE", false), "Disable log to file")
+	flag.BoolVar(&cfg.RecordTerminals, "record-terminals", utils.GetBoolEnv("VG_RECORD_TERMINALS", false), "Record every terminal session as an asciicast file")
+	flag.BoolVar(&cfg.PersistentTerminals, "persistent-terminals", utils.GetBoolEnv("VG_PERSISTENT_TERMINALS", false), "Keep terminal sessions running across server restarts")
+	flag.DurationVar(&cfg.TerminalExitedTimeout, "terminal-exited-timeout", utils.GetDurationEnv("VG_TERMINAL_EXITED_TIMEOUT", 0), "Close terminals this long after their process exited, 0 to keep them")
+	flag.DurationVar(&cfg.TerminalIdleTimeout, "terminal-idle-timeout", utils.GetDurationEnv("VG_TERMINAL_IDLE_TIMEOUT", 0), "Kill terminals without clients or output for this long, 0 to disable")
+	flag.DurationVar(&cfg.TerminalSessionRetention, "terminal-session-retention", utils.GetDurationEnv("VG_TERMINAL_SESSION_RETENTION", 0), "Delete closed terminal sessions with their history and recordings after this long, 0 to keep them")
+	flag.StringVar(&cfg.TerminalResizePolicy, "terminal-resize-policy", utils.GetEnv("VG_TERMINAL_RESIZE_POLICY", "smallest"), "Size of terminals shared by several clients: smallest, latest or pinned")
+	flag.DurationVar(&cfg.TerminalPingInterval, "terminal-ping-interval", utils.GetDurationEnv("VG_TERMINAL_PING_INTERVAL", 30*time.Second), "Ping terminal WebSocket clients this often, negative to disable")
+	flag.DurationVar(&cfg.TerminalPongTimeout, "terminal-pong-timeout", utils.GetDurationEnv("VG_TERMINAL_PONG_TIMEOUT", time.Minute), "Detach terminal clients silent for this long, negative to disable")
+	flag.StringVar(&cfg.TerminalEnvAllow, "terminal-env-allow", utils.GetEnv("VG_TERMINAL_ENV_ALLOW", ""), "Comma separated variables terminals may inherit, empty to inherit all but denied ones")
+	flag.StringVar(&cfg.TerminalEnvDeny, "terminal-env-deny", utils.GetEnv("VG_TERMINAL_ENV_DENY", ""), "Comma separated variables terminals never inherit, in addition to VG_* and secrets")
+	flag.StringVar(&cfg.TerminalCgroupRoot, "terminal-cgroup-root", utils.GetEnv("VG_TERMINAL_CGROUP_ROOT", ""), "Delegated cgroup v2 directory for terminal memory and CPU limits, empty to disable them")
+	flag.StringVar(&cfg.NotifyWebhook, "notify-webhook", utils.GetEnv("VG_NOTIFY_WEBHOOK", ""), "URL that receives terminal notifications as JSON POST requests")
 
 	defaultShell := ""
 	switch runtime.GOOS {
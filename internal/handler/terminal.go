@@ -1,27 +1,39 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
 	"github.com/rs/zerolog/log"
+	"github.com/xxnuo/vibego/internal/model"
 	"github.com/xxnuo/vibego/internal/service/terminal"
 	"gorm.io/gorm"
 )
 
 type TerminalHandler struct {
 	manager  *terminal.Manager
+	push     *terminal.WebPushSink
 	upgrader websocket.Upgrader
 }
 
-func NewTerminalHandler(db *gorm.DB, shell string) *TerminalHandler {
-	mgr := terminal.NewManager(db, &terminal.ManagerConfig{Shell: shell})
+func NewTerminalHandler(db *gorm.DB, cfg *terminal.ManagerConfig) *TerminalHandler {
+	if cfg == nil {
+		cfg = &terminal.ManagerConfig{}
+	}
+	push := terminal.NewWebPushSink(db, "")
+	cfg.NotifySinks = append(cfg.NotifySinks, push)
+
+	mgr := terminal.NewManager(db, cfg)
 	mgr.CleanupOnStart()
 
 	return &TerminalHandler{
 		manager: mgr,
+		push:    push,
 		upgrader: websocket.Upgrader{
+			Subprotocols: []string{terminal.SubprotocolBinary, terminal.SubprotocolJSON},
 			CheckOrigin: func(r *http.Request) bool {
 				return true
 			},
@@ -29,27 +41,87 @@ func NewTerminalHandler(db *gorm.DB, shell string) *TerminalHandler {
 	}
 }
 
+// Shutdown closes terminal sessions when the server stops. Persistent
+// sessions are detached instead and re-adopted on the next start.
+func (h *TerminalHandler) Shutdown() {
+	h.manager.Shutdown()
+}
+
 func (h *TerminalHandler) Register(r *gin.RouterGroup) {
 	g := r.Group("/terminal")
 	g.GET("", h.List)
 	g.POST("", h.New)
 	g.POST("/close", h.Close)
 	g.GET("/ws/:id", h.WebSocket)
+	g.GET("/:id/clients", h.Clients)
+	g.DELETE("/:id/clients/:client", h.Kick)
+	g.PUT("/:id/resize-policy", h.SetResizePolicy)
+	g.GET("/:id/screen", h.Screen)
+	g.GET("/:id/search", h.Search)
+	g.POST("/:id/input", h.Input)
+	g.POST("/:id/run", h.Run)
+	g.POST("/:id/signal", h.Signal)
+	g.GET("/:id/processes", h.Processes)
+	g.GET("/profiles", h.ListProfiles)
+	g.POST("/profiles", h.CreateProfile)
+	g.GET("/profiles/:id", h.GetProfile)
+	g.PUT("/profiles/:id", h.UpdateProfile)
+	g.DELETE("/profiles/:id", h.DeleteProfile)
+	g.GET("/ssh-hosts", h.ListSSHHosts)
+	g.POST("/ssh-hosts", h.CreateSSHHost)
+	g.GET("/ssh-hosts/:id", h.GetSSHHost)
+	g.PUT("/ssh-hosts/:id", h.UpdateSSHHost)
+	g.DELETE("/ssh-hosts/:id", h.DeleteSSHHost)
+	g.GET("/groups", h.ListGroups)
+	g.POST("/groups", h.CreateGroup)
+	g.GET("/groups/:id", h.GetGroup)
+	g.DELETE("/groups/:id", h.DeleteGroup)
+	g.POST("/groups/:id/members", h.AddGroupMembers)
+	g.DELETE("/groups/:id/members/:member", h.RemoveGroupMember)
+	g.GET("/groups/:id/ws", h.GroupWebSocket)
+	g.GET("/triggers", h.ListTriggers)
+	g.POST("/triggers", h.CreateTrigger)
+	g.GET("/triggers/:id", h.GetTrigger)
+	g.PUT("/triggers/:id", h.UpdateTrigger)
+	g.DELETE("/triggers/:id", h.DeleteTrigger)
+	g.GET("/recordings", h.ListRecordings)
+	g.GET("/recordings/:id", h.DownloadRecording)
+	g.DELETE("/recordings/:id", h.DeleteRecording)
+	g.GET("/recordings/:id/play", h.PlayRecording)
+	g.GET("/notifications", h.Notifications)
+	g.GET("/notifications/push", h.PushKey)
+	g.POST("/notifications/push", h.PushSubscribe)
+	g.DELETE("/notifications/push", h.PushUnsubscribe)
 }
 
 type TerminalInfo struct {
-	ID          string `json:"id"`
-	Name        string `json:"name"`
-	Shell       string `json:"shell"`
-	Cwd         string `json:"cwd"`
-	Cols        int    `json:"cols"`
-	Rows        int    `json:"rows"`
-	Status      string `json:"status"`
-	PTYStatus   string `json:"pty_status"`
-	ExitCode    int    `json:"exit_code"`
-	HistorySize int64  `json:"history_size"`
-	CreatedAt   int64  `json:"created_at"`
-	UpdatedAt   int64  `json:"updated_at"`
+	ID             string                `json:"id"`
+	Name           string                `json:"name"`
+	Shell          string                `json:"shell"`
+	Args           []string              `json:"args,omitempty"`
+	StartupCommand string                `json:"startup_command,omitempty"`
+	ProfileID      string                `json:"profile_id,omitempty"`
+	Cwd            string                `json:"cwd"`
+	Cols           int                   `json:"cols"`
+	Rows           int                   `json:"rows"`
+	Status         string                `json:"status"`
+	PTYStatus      string                `json:"pty_status"`
+	ExitCode       int                   `json:"exit_code"`
+	ExitSignal     string                `json:"exit_signal,omitempty"`
+	ExitReason     string                `json:"exit_reason,omitempty"`
+	Backend        string                `json:"backend"`
+	HostID         string                `json:"host_id,omitempty"`
+	ResizePolicy   string                `json:"resize_policy"`
+	PinnedClient   string                `json:"pinned_client,omitempty"`
+	Limits         *model.TerminalLimits `json:"limits,omitempty"`
+	LastCommand    string                `json:"last_command"`
+	LastExitCode   int                   `json:"last_exit_code"`
+	CommandRunning bool                  `json:"command_running"`
+	HistorySize    int64                 `json:"history_size"`
+	Writers        int                   `json:"writers"`
+	Viewers        int                   `json:"viewers"`
+	CreatedAt      int64                 `json:"created_at"`
+	UpdatedAt      int64                 `json:"updated_at"`
 }
 
 // List godoc
@@ -68,47 +140,131 @@ func (h *TerminalHandler) List(c *gin.Context) {
 	list := make([]TerminalInfo, len(sessions))
 	for i, s := range sessions {
 		list[i] = TerminalInfo{
-			ID:        s.ID,
-			Name:      s.Name,
-			Shell:     s.Shell,
-			Cwd:       s.Cwd,
-			Cols:      s.Cols,
-			Rows:      s.Rows,
-			Status:    s.Status,
-			PTYStatus: s.PTYStatus,
-			CreatedAt: s.CreatedAt,
-			UpdatedAt: s.UpdatedAt,
+			ID:             s.ID,
+			Name:           s.Name,
+			Shell:          s.Shell,
+			Args:           s.Args,
+			StartupCommand: s.StartupCommand,
+			ProfileID:      s.ProfileID,
+			Cwd:            s.Cwd,
+			Cols:           s.Cols,
+			Rows:           s.Rows,
+			Status:         s.Status,
+			PTYStatus:      s.PTYStatus,
+			ExitCode:       s.ExitCode,
+			ExitSignal:     s.ExitSignal,
+			ExitReason:     s.ExitReason,
+			Backend:        s.Backend,
+			HostID:         s.HostID,
+			ResizePolicy:   s.ResizePolicy,
+			PinnedClient:   s.PinnedClient,
+			Limits:         s.Limits,
+			LastCommand:    s.LastCommand,
+			LastExitCode:   s.LastExitCode,
+			CommandRunning: s.CommandRunning,
+			Writers:        s.Writers,
+			Viewers:        s.Viewers,
+			CreatedAt:      s.CreatedAt,
+			UpdatedAt:      s.UpdatedAt,
 		}
 	}
 	c.JSON(http.StatusOK, gin.H{"terminals": list})
 }
 
+// terminalError maps manager errors for a single terminal to a response.
+func terminalError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, terminal.ErrTerminalNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, terminal.ErrTerminalExited), errors.Is(err, terminal.ErrTerminalBusy):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, terminal.ErrClientNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, terminal.ErrInvalidSignal), errors.Is(err, terminal.ErrInvalidResizePolicy),
+		errors.Is(err, terminal.ErrInvalidQuery):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, terminal.ErrNoProcess), errors.Is(err, terminal.ErrProcessTreeUnsupported):
+		c.JSON(http.StatusNotImplemented, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// requestUser returns the user a request acts for: the user_id clients get
+// from /api/auth/login, sent in the X-User-ID header or, where headers cannot
+// be set as for WebSockets, in the user_id query parameter.
+func requestUser(c *gin.Context) string {
+	if user := c.GetHeader("X-User-ID"); user != "" {
+		return user
+	}
+	return c.Query("user_id")
+}
+
 type NewTerminalRequest struct {
-	Name string `json:"name"`
-	Cwd  string `json:"cwd"`
-	Cols int    `json:"cols"`
-	Rows int    `json:"rows"`
+	Name             string                `json:"name"`
+	Cwd              string                `json:"cwd"`
+	Cols             int                   `json:"cols"`
+	Rows             int                   `json:"rows"`
+	Command          string                `json:"command"`
+	Args             []string              `json:"args"`
+	Env              map[string]string     `json:"env"`
+	Term             string                `json:"term"`
+	StartupCommand   string                `json:"startup_command"`
+	ProfileID        string                `json:"profile_id"`
+	Record           bool                  `json:"record"`
+	ShellIntegration bool                  `json:"shell_integration"`
+	ResizePolicy     string                `json:"resize_policy"`
+	Backend          string                `json:"backend"`
+	HostID           string                `json:"host_id"`
+	Limits           *model.TerminalLimits `json:"limits"`
 }
 
 // New godoc
 // @Summary Create new terminal session
+// @Description Runs the default shell unless command is given. Set host_id to open the terminal on a saved SSH host. limits caps the resources of local terminals; memory and cpu need a cgroup root configured, and processes counts every process of the user VibeGo runs as. The terminal belongs to the user named by the X-User-ID header, whose output triggers apply along with those of profile_id.
 // @Tags Terminal
 // @Accept json
 // @Produce json
 // @Param request body NewTerminalRequest true "Terminal options"
 // @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
 // @Failure 500 {object} map[string]string
+// @Failure 502 {object} map[string]string
 // @Router /api/terminal/new [post]
 func (h *TerminalHandler) New(c *gin.Context) {
 	var req NewTerminalRequest
 	c.ShouldBindJSON(&req)
 
 	info, err := h.manager.Create(terminal.CreateOptions{
-		Name: req.Name,
-		Cwd:  req.Cwd,
-		Cols: req.Cols,
-		Rows: req.Rows,
+		Name:             req.Name,
+		Cwd:              req.Cwd,
+		Cols:             req.Cols,
+		Rows:             req.Rows,
+		Command:          req.Command,
+		Args:             req.Args,
+		Env:              req.Env,
+		Term:             req.Term,
+		StartupCommand:   req.StartupCommand,
+		ProfileID:        req.ProfileID,
+		UserID:           requestUser(c),
+		Record:           req.Record,
+		ShellIntegration: req.ShellIntegration,
+		ResizePolicy:     req.ResizePolicy,
+		Backend:          req.Backend,
+		HostID:           req.HostID,
+		Limits:           req.Limits,
 	})
+	if errors.Is(err, terminal.ErrProfileNotFound) || errors.Is(err, terminal.ErrInvalidResizePolicy) ||
+		errors.Is(err, terminal.ErrUnknownBackend) || errors.Is(err, terminal.ErrSSHHostNotFound) ||
+		errors.Is(err, terminal.ErrInvalidSSHHost) || errors.Is(err, terminal.ErrInvalidLimits) ||
+		errors.Is(err, terminal.ErrLimitsUnsupported) || errors.Is(err, terminal.ErrCgroupUnavailable) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, terminal.ErrSSHHostKey) || errors.Is(err, terminal.ErrSSHAgentUnavailable) {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -143,10 +299,146 @@ func (h *TerminalHandler) Close(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"ok": true})
 }
 
+// Clients godoc
+// @Summary List attached terminal clients
+// @Description Reports who is attached: user, remote address, user agent, attach time, mode and send queue state
+// @Tags Terminal
+// @Produce json
+// @Param id path string true "Terminal ID"
+// @Success 200 {object} map[string][]terminal.ClientInfo
+// @Failure 404 {object} map[string]string
+// @Router /api/terminal/{id}/clients [get]
+func (h *TerminalHandler) Clients(c *gin.Context) {
+	clients, err := h.manager.Clients(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"clients": clients})
+}
+
+// Kick godoc
+// @Summary Disconnect a terminal client
+// @Description Closes one client's connection. The client receives a client_leave message with reason kicked before the socket closes.
+// @Tags Terminal
+// @Produce json
+// @Param id path string true "Terminal ID"
+// @Param client path string true "Client ID"
+// @Success 200 {object} map[string]bool
+// @Failure 404 {object} map[string]string
+// @Router /api/terminal/{id}/clients/{client} [delete]
+func (h *TerminalHandler) Kick(c *gin.Context) {
+	if err := h.manager.Kick(c.Param("id"), c.Param("client")); err != nil {
+		terminalError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+type ResizePolicyRequest struct {
+	Policy   string `json:"policy" binding:"required"`
+	ClientID string `json:"client_id"`
+}
+
+// SetResizePolicy godoc
+// @Summary Set terminal resize policy
+// @Description Chooses how the size is picked when several clients are attached: smallest, latest or pinned to client_id. A pin follows the client when it reconnects with the same client_token
+// @Tags Terminal
+// @Accept json
+// @Produce json
+// @Param id path string true "Terminal ID"
+// @Param request body ResizePolicyRequest true "Resize policy"
+// @Success 200 {object} map[string]bool
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/terminal/{id}/resize-policy [put]
+func (h *TerminalHandler) SetResizePolicy(c *gin.Context) {
+	var req ResizePolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.manager.SetResizePolicy(c.Param("id"), req.Policy, req.ClientID); err != nil {
+		terminalError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+// Screen godoc
+// @Summary Dump terminal screen as plain text
+// @Description Renders the emulated screen of a terminal. Closed sessions are rendered from their stored history.
+// @Tags Terminal
+// @Produce json
+// @Param id path string true "Terminal ID"
+// @Param scrollback query bool false "Include scrollback lines"
+// @Success 200 {object} terminal.ScreenDump
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/terminal/{id}/screen [get]
+func (h *TerminalHandler) Screen(c *gin.Context) {
+	dump, err := h.manager.Screen(c.Param("id"), c.Query("scrollback") == "true")
+	if err != nil {
+		if errors.Is(err, terminal.ErrTerminalNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, dump)
+}
+
+// Search godoc
+// @Summary Search terminal output
+// @Description Searches the buffered and stored output of a terminal with escape sequences stripped. Closed sessions are searched in their stored history. Offsets are stream offsets a client can scroll to.
+// @Tags Terminal
+// @Produce json
+// @Param id path string true "Terminal ID"
+// @Param q query string true "Search text"
+// @Param regex query bool false "Treat q as a regular expression"
+// @Param ignore_case query bool false "Match case-insensitively"
+// @Param context query int false "Lines of context around each match" default(2)
+// @Param limit query int false "Maximum number of matches" default(100)
+// @Success 200 {object} terminal.SearchResult
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/terminal/{id}/search [get]
+func (h *TerminalHandler) Search(c *gin.Context) {
+	contextLines, err := strconv.Atoi(c.DefaultQuery("context", "2"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid context"})
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "0"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+		return
+	}
+
+	result, err := h.manager.Search(c.Param("id"), terminal.SearchOptions{
+		Query:      c.Query("q"),
+		Regex:      c.Query("regex") == "true",
+		IgnoreCase: c.Query("ignore_case") == "true",
+		Context:    contextLines,
+		Limit:      limit,
+	})
+	if err != nil {
+		terminalError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
 // WebSocket godoc
 // @Summary Connect to terminal websocket
+// @Description Use mode=view to attach as a read-only observer. The client roster names you by user_id, since WebSockets cannot send the X-User-ID header. Other clients receive client_join and client_leave messages. Request the vibego.binary subprotocol for binary framing. The first output frame is a snapshot of the screen unless since can be served from the buffer.
 // @Tags Terminal
 // @Param id path string true "Terminal ID"
+// @Param mode query string false "Attach mode (write, view)"
+// @Param since query int false "Resume from this stream offset"
+// @Param user_id query string false "User shown in the client roster"
+// @Param client_token query string false "Identity kept across reconnects, so a pinned size follows the client"
 // @Router /api/terminal/ws/{id} [get]
 func (h *TerminalHandler) WebSocket(c *gin.Context) {
 	id := c.Param("id")
@@ -155,20 +447,37 @@ func (h *TerminalHandler) WebSocket(c *gin.Context) {
 		return
 	}
 
+	opts := terminal.AttachOptions{
+		ReadOnly:    c.Query("mode") == "view",
+		User:        requestUser(c),
+		RemoteAddr:  c.ClientIP(),
+		UserAgent:   c.Request.UserAgent(),
+		ClientToken: c.Query("client_token"),
+	}
+	if since := c.Query("since"); since != "" {
+		offset, err := strconv.ParseInt(since, 10, 64)
+		if err != nil || offset < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid since offset"})
+			return
+		}
+		opts.Resume = true
+		opts.Since = offset
+	}
+
 	conn, err := h.upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to upgrade websocket")
 		return
 	}
 
-	termConn, err := h.manager.Attach(id, conn)
+	termConn, err := h.manager.Attach(id, conn, opts)
 	if err != nil {
 		log.Error().Err(err).Str("id", id).Msg("Failed to attach to terminal")
 		conn.Close()
 		return
 	}
 
-	log.Info().Str("id", id).Msg("Terminal attached via WebSocket")
+	log.Info().Str("id", id).Str("mode", c.DefaultQuery("mode", "write")).Msg("Terminal attached via WebSocket")
 
 	<-termConn.Done
 }
@@ -0,0 +1,173 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+	"github.com/xxnuo/vibego/internal/service/terminal"
+)
+
+type GroupRequest struct {
+	Name    string   `json:"name"`
+	Members []string `json:"members"`
+}
+
+type GroupMembersRequest struct {
+	Members []string `json:"members" binding:"required"`
+}
+
+func groupError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, terminal.ErrGroupNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, terminal.ErrTerminalNotFound):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// ListGroups godoc
+// @Summary List broadcast groups
+// @Tags Terminal
+// @Produce json
+// @Success 200 {object} map[string][]terminal.GroupInfo
+// @Router /api/terminal/groups [get]
+func (h *TerminalHandler) ListGroups(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"groups": h.manager.ListGroups()})
+}
+
+// GetGroup godoc
+// @Summary Get broadcast group
+// @Tags Terminal
+// @Produce json
+// @Param id path string true "Group ID"
+// @Success 200 {object} terminal.GroupInfo
+// @Failure 404 {object} map[string]string
+// @Router /api/terminal/groups/{id} [get]
+func (h *TerminalHandler) GetGroup(c *gin.Context) {
+	group, err := h.manager.GetGroup(c.Param("id"))
+	if err != nil {
+		groupError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, group)
+}
+
+// CreateGroup godoc
+// @Summary Create broadcast group
+// @Description Input sent by a client attached to the group is written to every member terminal. Groups are kept in memory and members must be running.
+// @Tags Terminal
+// @Accept json
+// @Produce json
+// @Param request body GroupRequest true "Group"
+// @Success 201 {object} terminal.GroupInfo
+// @Failure 400 {object} map[string]string
+// @Router /api/terminal/groups [post]
+func (h *TerminalHandler) CreateGroup(c *gin.Context) {
+	var req GroupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	group, err := h.manager.CreateGroup(req.Name, req.Members)
+	if err != nil {
+		groupError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, group)
+}
+
+// DeleteGroup godoc
+// @Summary Delete broadcast group
+// @Description Disconnects the group's clients. Member terminals keep running.
+// @Tags Terminal
+// @Produce json
+// @Param id path string true "Group ID"
+// @Success 200 {object} map[string]bool
+// @Failure 404 {object} map[string]string
+// @Router /api/terminal/groups/{id} [delete]
+func (h *TerminalHandler) DeleteGroup(c *gin.Context) {
+	if err := h.manager.DeleteGroup(c.Param("id")); err != nil {
+		groupError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+// AddGroupMembers godoc
+// @Summary Add terminals to broadcast group
+// @Tags Terminal
+// @Accept json
+// @Produce json
+// @Param id path string true "Group ID"
+// @Param request body GroupMembersRequest true "Terminal IDs"
+// @Success 200 {object} terminal.GroupInfo
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/terminal/groups/{id}/members [post]
+func (h *TerminalHandler) AddGroupMembers(c *gin.Context) {
+	var req GroupMembersRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	group, err := h.manager.AddGroupMembers(c.Param("id"), req.Members)
+	if err != nil {
+		groupError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, group)
+}
+
+// RemoveGroupMember godoc
+// @Summary Remove terminal from broadcast group
+// @Tags Terminal
+// @Produce json
+// @Param id path string true "Group ID"
+// @Param member path string true "Terminal ID"
+// @Success 200 {object} terminal.GroupInfo
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/terminal/groups/{id}/members/{member} [delete]
+func (h *TerminalHandler) RemoveGroupMember(c *gin.Context) {
+	group, err := h.manager.RemoveGroupMember(c.Param("id"), c.Param("member"))
+	if err != nil {
+		groupError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, group)
+}
+
+// GroupWebSocket godoc
+// @Summary Connect to broadcast group websocket
+// @Description cmd messages (or binary input frames with the vibego.binary subprotocol) are written to every running member. The client receives a group message listing the members on attach and whenever they change, but no terminal output.
+// @Tags Terminal
+// @Param id path string true "Group ID"
+// @Router /api/terminal/groups/{id}/ws [get]
+func (h *TerminalHandler) GroupWebSocket(c *gin.Context) {
+	id := c.Param("id")
+	if _, err := h.manager.GetGroup(id); err != nil {
+		groupError(c, err)
+		return
+	}
+
+	conn, err := h.upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to upgrade websocket")
+		return
+	}
+
+	groupConn, err := h.manager.AttachGroup(id, conn)
+	if err != nil {
+		log.Error().Err(err).Str("group", id).Msg("Failed to attach to broadcast group")
+		conn.Close()
+		return
+	}
+
+	log.Info().Str("group", id).Msg("Broadcast group attached via WebSocket")
+
+	<-groupConn.Done
+}
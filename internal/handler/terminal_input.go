@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"encoding/base64"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xxnuo/vibego/internal/service/terminal"
+)
+
+type TerminalInputRequest struct {
+	Data   string `json:"data" binding:"required"`
+	Base64 bool   `json:"base64"`
+}
+
+// Input godoc
+// @Summary Send input to a terminal
+// @Description Writes text or keystrokes into the terminal. Control keys can be sent as their characters, e.g. "\u0003" for Ctrl-C, or as base64 encoded bytes.
+// @Tags Terminal
+// @Accept json
+// @Produce json
+// @Param id path string true "Terminal ID"
+// @Param request body TerminalInputRequest true "Input"
+// @Success 200 {object} map[string]bool
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /api/terminal/{id}/input [post]
+func (h *TerminalHandler) Input(c *gin.Context) {
+	var req TerminalInputRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	data := []byte(req.Data)
+	if req.Base64 {
+		var err error
+		if data, err = base64.StdEncoding.DecodeString(req.Data); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid base64 data"})
+			return
+		}
+	}
+	if err := h.manager.WriteInput(c.Param("id"), data); err != nil {
+		terminalError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+type TerminalRunRequest struct {
+	Command   string `json:"command" binding:"required"`
+	Timeout   int    `json:"timeout"`
+	Interrupt bool   `json:"interrupt"`
+}
+
+// Run godoc
+// @Summary Run a command in a terminal
+// @Description Types the command into the terminal and waits until it finishes, returning its output and exit code. Completion is detected through shell integration when enabled and through sentinel markers otherwise. On timeout the partial output is returned and, if interrupt is set, Ctrl-C is sent. Only the last 1 MiB of output is kept, with truncated set.
+// @Tags Terminal
+// @Accept json
+// @Produce json
+// @Param id path string true "Terminal ID"
+// @Param request body TerminalRunRequest true "Command and timeout in seconds (default 30, at most 600)"
+// @Success 200 {object} terminal.RunResult
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /api/terminal/{id}/run [post]
+func (h *TerminalHandler) Run(c *gin.Context) {
+	var req TerminalRunRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Timeout < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid timeout"})
+		return
+	}
+
+	result, err := h.manager.Run(c.Request.Context(), c.Param("id"), req.Command, terminal.RunOptions{
+		Timeout:   time.Duration(req.Timeout) * time.Second,
+		Interrupt: req.Interrupt,
+	})
+	if err != nil {
+		terminalError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Notifications godoc
+// @Summary Stream terminal notifications
+// @Description Server-sent events stream. Each "notification" event carries a terminal.Notification fired on a bell, a prompt waiting for input or the end of a long-running command.
+// @Tags Terminal
+// @Produce text/event-stream
+// @Success 200 {object} terminal.Notification
+// @Router /api/terminal/notifications [get]
+func (h *TerminalHandler) Notifications(c *gin.Context) {
+	events, cancel := h.manager.Notifications().Subscribe()
+	defer cancel()
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case n := <-events:
+			c.SSEvent("notification", n)
+			return true
+		case <-c.Request.Context().Done():
+			return false
+		}
+	})
+}
+
+type PushSubscriptionRequest struct {
+	Endpoint string `json:"endpoint" binding:"required"`
+	Keys     struct {
+		P256dh string `json:"p256dh"`
+		Auth   string `json:"auth"`
+	} `json:"keys"`
+}
+
+// PushKey godoc
+// @Summary Get Web Push public key
+// @Description Returns the VAPID application server key to subscribe with
+// @Tags Terminal
+// @Produce json
+// @Success 200 {object} map[string]string
+// @Failure 503 {object} map[string]string
+// @Router /api/terminal/notifications/push [get]
+func (h *TerminalHandler) PushKey(c *gin.Context) {
+	key, err := h.push.PublicKey()
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "web push unavailable: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"public_key": key})
+}
+
+// PushSubscribe godoc
+// @Summary Subscribe to Web Push notifications
+// @Description The subscription receives the notifications of the user named by the X-User-ID header, or of every user without it
+// @Tags Terminal
+// @Accept json
+// @Produce json
+// @Param request body PushSubscriptionRequest true "Browser PushSubscription"
+// @Success 200 {object} map[string]bool
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/terminal/notifications/push [post]
+func (h *TerminalHandler) PushSubscribe(c *gin.Context) {
+	var req PushSubscriptionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Keys.P256dh == "" || req.Keys.Auth == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "keys.p256dh and keys.auth are required"})
+		return
+	}
+	if err := h.push.Subscribe(requestUser(c), req.Endpoint, req.Keys.P256dh, req.Keys.Auth); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+// PushUnsubscribe godoc
+// @Summary Unsubscribe from Web Push notifications
+// @Tags Terminal
+// @Accept json
+// @Produce json
+// @Param request body PushSubscriptionRequest true "Subscription endpoint"
+// @Success 200 {object} map[string]bool
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/terminal/notifications/push [delete]
+func (h *TerminalHandler) PushUnsubscribe(c *gin.Context) {
+	var req PushSubscriptionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.push.Unsubscribe(req.Endpoint); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
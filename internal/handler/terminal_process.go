@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TerminalSignalRequest struct {
+	Signal string `json:"signal" binding:"required"`
+}
+
+// Signal godoc
+// @Summary Send a signal to a terminal's foreground process group
+// @Description Accepts SIGINT, SIGTERM, SIGHUP, SIGKILL and SIGTSTP, with or without the SIG prefix. Returns the process group the signal was delivered to.
+// @Tags Terminal
+// @Accept json
+// @Produce json
+// @Param id path string true "Terminal ID"
+// @Param request body TerminalSignalRequest true "Signal name"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /api/terminal/{id}/signal [post]
+func (h *TerminalHandler) Signal(c *gin.Context) {
+	var req TerminalSignalRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	pgid, err := h.manager.Signal(c.Param("id"), req.Signal)
+	if err != nil {
+		terminalError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ok": true, "pgid": pgid})
+}
+
+// Processes godoc
+// @Summary List terminal process tree
+// @Description Reads the processes started by the terminal from /proc, with pid, command line, CPU, RSS and start time
+// @Tags Terminal
+// @Produce json
+// @Param id path string true "Terminal ID"
+// @Success 200 {object} terminal.ProcessInfo
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 501 {object} map[string]string
+// @Router /api/terminal/{id}/processes [get]
+func (h *TerminalHandler) Processes(c *gin.Context) {
+	tree, err := h.manager.Processes(c.Param("id"))
+	if err != nil {
+		terminalError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, tree)
+}
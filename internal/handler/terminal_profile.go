@@ -0,0 +1,147 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xxnuo/vibego/internal/model"
+	"github.com/xxnuo/vibego/internal/service/terminal"
+)
+
+type TerminalProfileRequest struct {
+	Name    string                `json:"name" binding:"required"`
+	Program string                `json:"program"`
+	Args    []string              `json:"args"`
+	Env     map[string]string     `json:"env"`
+	Cwd     string                `json:"cwd"`
+	Icon    string                `json:"icon"`
+	Limits  *model.TerminalLimits `json:"limits"`
+}
+
+func (r *TerminalProfileRequest) options(user string) terminal.ProfileOptions {
+	return terminal.ProfileOptions{
+		Name:    r.Name,
+		Program: r.Program,
+		Args:    r.Args,
+		Env:     r.Env,
+		Cwd:     r.Cwd,
+		Icon:    r.Icon,
+		Limits:  r.Limits,
+		UserID:  user,
+	}
+}
+
+func profileError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, terminal.ErrProfileNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, terminal.ErrProfileReadOnly):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, terminal.ErrInvalidLimits):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// ListProfiles godoc
+// @Summary List terminal profiles
+// @Description Built-in profiles for detected shells and AI CLIs come first, followed by the profiles saved by the user named by the X-User-ID header and those shared by every user
+// @Tags Terminal
+// @Produce json
+// @Success 200 {object} map[string][]terminal.ProfileInfo
+// @Failure 500 {object} map[string]string
+// @Router /api/terminal/profiles [get]
+func (h *TerminalHandler) ListProfiles(c *gin.Context) {
+	profiles, err := h.manager.ListProfiles(requestUser(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"profiles": profiles})
+}
+
+// GetProfile godoc
+// @Summary Get terminal profile
+// @Tags Terminal
+// @Produce json
+// @Param id path string true "Profile ID"
+// @Success 200 {object} terminal.ProfileInfo
+// @Failure 404 {object} map[string]string
+// @Router /api/terminal/profiles/{id} [get]
+func (h *TerminalHandler) GetProfile(c *gin.Context) {
+	profile, err := h.manager.GetProfile(c.Param("id"), requestUser(c))
+	if err != nil {
+		profileError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, profile)
+}
+
+// CreateProfile godoc
+// @Summary Create terminal profile
+// @Tags Terminal
+// @Accept json
+// @Produce json
+// @Param request body TerminalProfileRequest true "Profile"
+// @Success 201 {object} terminal.ProfileInfo
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/terminal/profiles [post]
+func (h *TerminalHandler) CreateProfile(c *gin.Context) {
+	var req TerminalProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	profile, err := h.manager.CreateProfile(req.options(requestUser(c)))
+	if err != nil {
+		profileError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, profile)
+}
+
+// UpdateProfile godoc
+// @Summary Update terminal profile
+// @Tags Terminal
+// @Accept json
+// @Produce json
+// @Param id path string true "Profile ID"
+// @Param request body TerminalProfileRequest true "Profile"
+// @Success 200 {object} terminal.ProfileInfo
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/terminal/profiles/{id} [put]
+func (h *TerminalHandler) UpdateProfile(c *gin.Context) {
+	var req TerminalProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	profile, err := h.manager.UpdateProfile(c.Param("id"), req.options(requestUser(c)))
+	if err != nil {
+		profileError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, profile)
+}
+
+// DeleteProfile godoc
+// @Summary Delete terminal profile
+// @Tags Terminal
+// @Produce json
+// @Param id path string true "Profile ID"
+// @Success 200 {object} map[string]bool
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/terminal/profiles/{id} [delete]
+func (h *TerminalHandler) DeleteProfile(c *gin.Context) {
+	if err := h.manager.DeleteProfile(c.Param("id"), requestUser(c)); err != nil {
+		profileError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
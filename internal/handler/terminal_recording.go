@@ -0,0 +1,115 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+	"github.com/xxnuo/vibego/internal/service/terminal"
+)
+
+func recordingError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, terminal.ErrRecordingNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, terminal.ErrRecordingActive):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// ListRecordings godoc
+// @Summary List terminal recordings
+// @Description Lists the recordings of the user named by the X-User-ID header and those shared by every user
+// @Tags Terminal
+// @Produce json
+// @Param session_id query string false "Only recordings of this session"
+// @Success 200 {object} map[string][]terminal.RecordingInfo
+// @Failure 500 {object} map[string]string
+// @Router /api/terminal/recordings [get]
+func (h *TerminalHandler) ListRecordings(c *gin.Context) {
+	recordings, err := h.manager.ListRecordings(c.Query("session_id"), requestUser(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"recordings": recordings})
+}
+
+// DownloadRecording godoc
+// @Summary Download terminal recording
+// @Description Returns the asciicast v2 file
+// @Tags Terminal
+// @Produce application/x-asciicast
+// @Param id path string true "Recording ID"
+// @Success 200 {file} file
+// @Failure 404 {object} map[string]string
+// @Router /api/terminal/recordings/{id} [get]
+func (h *TerminalHandler) DownloadRecording(c *gin.Context) {
+	recording, err := h.manager.GetRecording(c.Param("id"), requestUser(c))
+	if err != nil {
+		recordingError(c, err)
+		return
+	}
+	c.Header("Content-Type", "application/x-asciicast")
+	c.FileAttachment(recording.Path, recording.ID+".cast")
+}
+
+// DeleteRecording godoc
+// @Summary Delete terminal recording
+// @Tags Terminal
+// @Produce json
+// @Param id path string true "Recording ID"
+// @Success 200 {object} map[string]bool
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /api/terminal/recordings/{id} [delete]
+func (h *TerminalHandler) DeleteRecording(c *gin.Context) {
+	if err := h.manager.DeleteRecording(c.Param("id"), requestUser(c)); err != nil {
+		recordingError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+// PlayRecording godoc
+// @Summary Play terminal recording over websocket
+// @Description Streams the recording with the terminal websocket protocol. The stream ends with an exit message.
+// @Tags Terminal
+// @Param id path string true "Recording ID"
+// @Param speed query number false "Playback speed multiplier (default 1)"
+// @Router /api/terminal/recordings/{id}/play [get]
+func (h *TerminalHandler) PlayRecording(c *gin.Context) {
+	id, user := c.Param("id"), requestUser(c)
+	speed := 1.0
+	if s := c.Query("speed"); s != "" {
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil || v <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid speed"})
+			return
+		}
+		speed = v
+	}
+	if _, err := h.manager.GetRecording(id, user); err != nil {
+		recordingError(c, err)
+		return
+	}
+
+	conn, err := h.upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to upgrade websocket")
+		return
+	}
+
+	playConn, err := h.manager.Play(id, user, conn, speed)
+	if err != nil {
+		log.Error().Err(err).Str("id", id).Msg("Failed to play recording")
+		conn.Close()
+		return
+	}
+
+	<-playConn.Done
+}
@@ -0,0 +1,148 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xxnuo/vibego/internal/service/terminal"
+)
+
+type SSHHostRequest struct {
+	Name       string `json:"name"`
+	Host       string `json:"host" binding:"required"`
+	Port       int    `json:"port"`
+	User       string `json:"user" binding:"required"`
+	Auth       string `json:"auth" binding:"required"`
+	Password   string `json:"password"`
+	PrivateKey string `json:"private_key"`
+	Passphrase string `json:"passphrase"`
+	HostKey    string `json:"host_key"`
+}
+
+func (r *SSHHostRequest) options(user string) terminal.SSHHostOptions {
+	return terminal.SSHHostOptions{
+		Name:       r.Name,
+		Host:       r.Host,
+		Port:       r.Port,
+		User:       r.User,
+		Auth:       r.Auth,
+		Password:   r.Password,
+		PrivateKey: r.PrivateKey,
+		Passphrase: r.Passphrase,
+		HostKey:    r.HostKey,
+		UserID:     user,
+	}
+}
+
+func sshHostError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, terminal.ErrSSHHostNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, terminal.ErrInvalidSSHHost):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// ListSSHHosts godoc
+// @Summary List saved SSH hosts
+// @Description Lists the hosts saved by the user named by the X-User-ID header and those shared by every user. Credentials are never returned
+// @Tags Terminal
+// @Produce json
+// @Success 200 {object} map[string][]terminal.SSHHostInfo
+// @Failure 500 {object} map[string]string
+// @Router /api/terminal/ssh-hosts [get]
+func (h *TerminalHandler) ListSSHHosts(c *gin.Context) {
+	hosts, err := h.manager.ListSSHHosts(requestUser(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"hosts": hosts})
+}
+
+// GetSSHHost godoc
+// @Summary Get saved SSH host
+// @Tags Terminal
+// @Produce json
+// @Param id path string true "Host ID"
+// @Success 200 {object} terminal.SSHHostInfo
+// @Failure 404 {object} map[string]string
+// @Router /api/terminal/ssh-hosts/{id} [get]
+func (h *TerminalHandler) GetSSHHost(c *gin.Context) {
+	host, err := h.manager.GetSSHHost(c.Param("id"), requestUser(c))
+	if err != nil {
+		sshHostError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, host)
+}
+
+// CreateSSHHost godoc
+// @Summary Save SSH host
+// @Description auth is password, key or agent. host_key pins the server key in authorized_keys format; without it the key must be in known_hosts. Credentials are stored encrypted with the server's secret key.
+// @Tags Terminal
+// @Accept json
+// @Produce json
+// @Param request body SSHHostRequest true "SSH host"
+// @Success 201 {object} terminal.SSHHostInfo
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/terminal/ssh-hosts [post]
+func (h *TerminalHandler) CreateSSHHost(c *gin.Context) {
+	var req SSHHostRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	host, err := h.manager.CreateSSHHost(req.options(requestUser(c)))
+	if err != nil {
+		sshHostError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, host)
+}
+
+// UpdateSSHHost godoc
+// @Summary Update saved SSH host
+// @Description Empty password and private_key keep the stored credentials. Switching auth drops the credential the old method used
+// @Tags Terminal
+// @Accept json
+// @Produce json
+// @Param id path string true "Host ID"
+// @Param request body SSHHostRequest true "SSH host"
+// @Success 200 {object} terminal.SSHHostInfo
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/terminal/ssh-hosts/{id} [put]
+func (h *TerminalHandler) UpdateSSHHost(c *gin.Context) {
+	var req SSHHostRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	host, err := h.manager.UpdateSSHHost(c.Param("id"), req.options(requestUser(c)))
+	if err != nil {
+		sshHostError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, host)
+}
+
+// DeleteSSHHost godoc
+// @Summary Delete saved SSH host
+// @Tags Terminal
+// @Produce json
+// @Param id path string true "Host ID"
+// @Success 200 {object} map[string]bool
+// @Failure 404 {object} map[string]string
+// @Router /api/terminal/ssh-hosts/{id} [delete]
+func (h *TerminalHandler) DeleteSSHHost(c *gin.Context) {
+	if err := h.manager.DeleteSSHHost(c.Param("id"), requestUser(c)); err != nil {
+		sshHostError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
@@ -1,11 +1,13 @@
 package handler
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
@@ -25,12 +27,12 @@ func setupTestHandler(t *testing.T) (*TerminalHandler, func()) {
 		t.Fatalf("failed to open database: %v", err)
 	}
 
-	if err := db.AutoMigrate(&model.TerminalSession{}, &model.TerminalHistory{}); err != nil {
+	if err := db.AutoMigrate(&model.TerminalSession{}, &model.TerminalHistory{}, &model.TerminalProfile{}, &model.TerminalRecording{}, &model.KV{}, &model.PushSubscription{}, &model.SSHHost{}, &model.TerminalTrigger{}); err != nil {
 		t.Fatalf("failed to migrate: %v", err)
 	}
 
-	mgr := terminal.NewManager(db, &terminal.ManagerConfig{Shell: os.Getenv("SHELL")})
-	handler := &TerminalHandler{manager: mgr}
+	mgr := terminal.NewManager(db, &terminal.ManagerConfig{Shell: os.Getenv("SHELL"), RecordDir: tmpDir})
+	handler := &TerminalHandler{manager: mgr, push: terminal.NewWebPushSink(db, "")}
 
 	cleanup := func() {
 		sessions, _ := mgr.List()
@@ -78,6 +80,27 @@ func TestTerminalHandlerNew(t *testing.T) {
 	}
 }
 
+func TestTerminalHandlerNewWithInvalidLimits(t *testing.T) {
+	handler, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	handler.Register(router.Group("/api"))
+
+	for _, limits := range []model.TerminalLimits{{OpenFiles: -1}, {Memory: 64 << 20}} {
+		body, _ := json.Marshal(NewTerminalRequest{Limits: &limits})
+		req := httptest.NewRequest("POST", "/api/terminal", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected status 400 for %+v, got %d", limits, w.Code)
+		}
+	}
+}
+
 func TestTerminalHandlerList(t *testing.T) {
 	handler, cleanup := setupTestHandler(t)
 	defer cleanup()
@@ -205,3 +228,726 @@ func TestTerminalHistoryPersistence(t *testing.T) {
 		t.Errorf("expected PTY status %s, got %s", model.PTYStatusRunning, found.PTYStatus)
 	}
 }
+
+func TestTerminalHandlerNewWithCommand(t *testing.T) {
+	handler, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	handler.Register(router.Group("/api"))
+
+	reqBody := NewTerminalRequest{
+		Name:    "cmd",
+		Command: "/bin/sh",
+		Args:    []string{"-c", "sleep 5"},
+		Env:     map[string]string{"FOO": "bar"},
+		Term:    "xterm",
+	}
+	body, _ := json.Marshal(reqBody)
+
+	req := httptest.NewRequest("POST", "/api/terminal", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest("GET", "/api/terminal", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var resp map[string][]TerminalInfo
+	json.Unmarshal(w.Body.Bytes(), &resp)
+
+	if len(resp["terminals"]) != 1 {
+		t.Fatalf("expected 1 terminal, got %d", len(resp["terminals"]))
+	}
+	term := resp["terminals"][0]
+	if term.Shell != "/bin/sh" || len(term.Args) != 2 || term.Args[1] != "sleep 5" {
+		t.Errorf("expected command and args in listing, got %s %v", term.Shell, term.Args)
+	}
+}
+
+func TestTerminalHandlerProfiles(t *testing.T) {
+	handler, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	handler.Register(router.Group("/api"))
+
+	body, _ := json.Marshal(TerminalProfileRequest{Name: "dev", Program: "/bin/sh", Env: map[string]string{"A": "1"}})
+	req := httptest.NewRequest("POST", "/api/terminal/profiles", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-User-ID", "alice")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d", w.Code)
+	}
+	var created terminal.ProfileInfo
+	json.Unmarshal(w.Body.Bytes(), &created)
+
+	for user, want := range map[string]bool{"alice": true, "bob": false} {
+		req = httptest.NewRequest("GET", "/api/terminal/profiles", nil)
+		req.Header.Set("X-User-ID", user)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var listResp map[string][]terminal.ProfileInfo
+		json.Unmarshal(w.Body.Bytes(), &listResp)
+		found := false
+		for _, p := range listResp["profiles"] {
+			if p.ID == created.ID {
+				found = true
+			}
+		}
+		if found != want {
+			t.Errorf("expected the profile to be listed for %s: %v, got %v", user, want, found)
+		}
+	}
+
+	req = httptest.NewRequest("DELETE", "/api/terminal/profiles/"+created.ID, nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected deleting another user's profile to be refused, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest("DELETE", "/api/terminal/profiles/"+created.ID, nil)
+	req.Header.Set("X-User-ID", "alice")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest("GET", "/api/terminal/profiles/"+created.ID, nil)
+	req.Header.Set("X-User-ID", "alice")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404, got %d", w.Code)
+	}
+}
+
+func TestTerminalHandlerSSHHosts(t *testing.T) {
+	handler, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	handler.Register(router.Group("/api"))
+
+	body, _ := json.Marshal(SSHHostRequest{Name: "box", Host: "example.com", User: "dev", Auth: model.SSHAuthPassword, Password: "secret"})
+	req := httptest.NewRequest("POST", "/api/terminal/ssh-hosts", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d", w.Code)
+	}
+	if strings.Contains(w.Body.String(), "secret") {
+		t.Error("expected the password not to be returned")
+	}
+	var created terminal.SSHHostInfo
+	json.Unmarshal(w.Body.Bytes(), &created)
+	if !created.HasPassword {
+		t.Error("expected has_password to be set")
+	}
+
+	body, _ = json.Marshal(SSHHostRequest{Host: "example.com", User: "dev", Auth: "telnet"})
+	req = httptest.NewRequest("PUT", "/api/terminal/ssh-hosts/"+created.ID, bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for an unknown auth method, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest("GET", "/api/terminal/ssh-hosts", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var listResp map[string][]terminal.SSHHostInfo
+	json.Unmarshal(w.Body.Bytes(), &listResp)
+	if len(listResp["hosts"]) != 1 || listResp["hosts"][0].ID != created.ID {
+		t.Errorf("unexpected host list %+v", listResp)
+	}
+
+	body, _ = json.Marshal(NewTerminalRequest{HostID: "missing"})
+	req = httptest.NewRequest("POST", "/api/terminal", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for a missing host, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest("DELETE", "/api/terminal/ssh-hosts/"+created.ID, nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest("GET", "/api/terminal/ssh-hosts/"+created.ID, nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404, got %d", w.Code)
+	}
+}
+
+func TestTerminalHandlerGroups(t *testing.T) {
+	handler, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	handler.Register(router.Group("/api"))
+
+	info1, _ := handler.manager.Create(terminal.CreateOptions{Name: "test1", Cols: 80, Rows: 24})
+	info2, _ := handler.manager.Create(terminal.CreateOptions{Name: "test2", Cols: 80, Rows: 24})
+	defer handler.manager.Close(info1.ID)
+	defer handler.manager.Close(info2.ID)
+
+	body, _ := json.Marshal(GroupRequest{Name: "worktrees", Members: []string{info1.ID}})
+	req := httptest.NewRequest("POST", "/api/terminal/groups", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d", w.Code)
+	}
+	var created terminal.GroupInfo
+	json.Unmarshal(w.Body.Bytes(), &created)
+
+	body, _ = json.Marshal(GroupMembersRequest{Members: []string{info2.ID}})
+	req = httptest.NewRequest("POST", "/api/terminal/groups/"+created.ID+"/members", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	var updated terminal.GroupInfo
+	json.Unmarshal(w.Body.Bytes(), &updated)
+	if w.Code != http.StatusOK || len(updated.Members) != 2 {
+		t.Errorf("expected both terminals in the group, got %d %+v", w.Code, updated)
+	}
+
+	body, _ = json.Marshal(GroupMembersRequest{Members: []string{"missing"}})
+	req = httptest.NewRequest("POST", "/api/terminal/groups/"+created.ID+"/members", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for an unknown terminal, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest("DELETE", "/api/terminal/groups/"+created.ID+"/members/"+info1.ID, nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest("GET", "/api/terminal/groups", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	var listResp map[string][]terminal.GroupInfo
+	json.Unmarshal(w.Body.Bytes(), &listResp)
+	if len(listResp["groups"]) != 1 || len(listResp["groups"][0].Members) != 1 || listResp["groups"][0].Members[0] != info2.ID {
+		t.Errorf("unexpected group list %+v", listResp)
+	}
+
+	req = httptest.NewRequest("DELETE", "/api/terminal/groups/"+created.ID, nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", w.Code)
+	}
+
+	for _, path := range []string{"/api/terminal/groups/" + created.ID, "/api/terminal/groups/" + created.ID + "/ws"} {
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if w.Code != http.StatusNotFound {
+			t.Errorf("%s: expected status 404, got %d", path, w.Code)
+		}
+	}
+}
+
+func TestTerminalHandlerTriggers(t *testing.T) {
+	handler, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	handler.Register(router.Group("/api"))
+
+	body, _ := json.Marshal(TriggerRequest{Name: "confirm", Pattern: `\[y/N\]`, Action: model.TriggerReply, Reply: "y\n"})
+	req := httptest.NewRequest("POST", "/api/terminal/triggers", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d", w.Code)
+	}
+	var created terminal.TriggerInfo
+	json.Unmarshal(w.Body.Bytes(), &created)
+	defer handler.manager.DeleteTrigger(created.ID, "")
+	if !created.Enabled {
+		t.Error("expected the trigger to be enabled by default")
+	}
+
+	body, _ = json.Marshal(TriggerRequest{Pattern: "(", Action: model.TriggerHighlight})
+	req = httptest.NewRequest("PUT", "/api/terminal/triggers/"+created.ID, bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for an invalid pattern, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest("GET", "/api/terminal/triggers", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	var listResp map[string][]terminal.TriggerInfo
+	json.Unmarshal(w.Body.Bytes(), &listResp)
+	if len(listResp["triggers"]) != 1 || listResp["triggers"][0].Reply != "y\n" {
+		t.Errorf("unexpected trigger list %+v", listResp)
+	}
+
+	req = httptest.NewRequest("DELETE", "/api/terminal/triggers/"+created.ID, nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest("GET", "/api/terminal/triggers/"+created.ID, nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404, got %d", w.Code)
+	}
+}
+
+func TestTerminalHandlerScreen(t *testing.T) {
+	handler, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	handler.Register(router.Group("/api"))
+
+	info, err := handler.manager.Create(terminal.CreateOptions{
+		Command: "/bin/sh",
+		Args:    []string{"-c", "printf hello; sleep 5"},
+	})
+	if err != nil {
+		t.Fatalf("failed to create terminal: %v", err)
+	}
+	time.Sleep(200 * time.Millisecond)
+
+	req := httptest.NewRequest("GET", "/api/terminal/"+info.ID+"/screen", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	var dump terminal.ScreenDump
+	json.Unmarshal(w.Body.Bytes(), &dump)
+	if len(dump.Lines) != 24 || dump.Lines[0] != "hello" {
+		t.Errorf("expected 'hello' on the first of 24 lines, got %q", dump.Lines)
+	}
+
+	req = httptest.NewRequest("GET", "/api/terminal/missing/screen", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404, got %d", w.Code)
+	}
+}
+
+func TestTerminalHandlerRecordings(t *testing.T) {
+	handler, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	handler.Register(router.Group("/api"))
+
+	body, _ := json.Marshal(NewTerminalRequest{
+		Command: "/bin/sh",
+		Args:    []string{"-c", "printf recorded; sleep 5"},
+		Record:  true,
+	})
+	req := httptest.NewRequest("POST", "/api/terminal", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	time.Sleep(200 * time.Millisecond)
+
+	req = httptest.NewRequest("GET", "/api/terminal/recordings", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var resp map[string][]terminal.RecordingInfo
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if len(resp["recordings"]) != 1 {
+		t.Fatalf("expected 1 recording, got %d", len(resp["recordings"]))
+	}
+	id := resp["recordings"][0].ID
+
+	req = httptest.NewRequest("GET", "/api/terminal/recordings/"+id, nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || !bytes.Contains(w.Body.Bytes(), []byte(`"o","recorded"`)) {
+		t.Errorf("expected cast file with recorded output, got %d %q", w.Code, w.Body.String())
+	}
+
+	req = httptest.NewRequest("DELETE", "/api/terminal/recordings/"+id, nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusConflict {
+		t.Errorf("expected status 409 while recording, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest("GET", "/api/terminal/recordings/missing", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404, got %d", w.Code)
+	}
+}
+
+func TestTerminalHandlerNotifications(t *testing.T) {
+	handler, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	handler.Register(router.Group("/api"))
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		handler.manager.Create(terminal.CreateOptions{
+			Command: "/bin/sh",
+			Args:    []string{"-c", `printf '\a'; sleep 5`},
+		})
+	}()
+
+	resp, err := http.Get(server.URL + "/api/terminal/notifications")
+	if err != nil {
+		t.Fatalf("failed to open stream: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/event-stream") {
+		t.Errorf("expected event stream, got %q", ct)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	var n terminal.Notification
+	for scanner.Scan() {
+		if data, ok := strings.CutPrefix(scanner.Text(), "data:"); ok {
+			json.Unmarshal([]byte(data), &n)
+			break
+		}
+	}
+	if n.Kind != terminal.NotifyBell {
+		t.Errorf("expected bell notification, got %+v", n)
+	}
+}
+
+func TestTerminalHandlerPushSubscription(t *testing.T) {
+	handler, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	handler.Register(router.Group("/api"))
+
+	req := httptest.NewRequest("GET", "/api/terminal/notifications/push", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	var key map[string]string
+	json.Unmarshal(w.Body.Bytes(), &key)
+	if w.Code != http.StatusOK || key["public_key"] == "" {
+		t.Errorf("expected public key, got %d %q", w.Code, w.Body.String())
+	}
+
+	req = httptest.NewRequest("POST", "/api/terminal/notifications/push", strings.NewReader(`{"endpoint":"https://push.example/1"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 without keys, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest("POST", "/api/terminal/notifications/push",
+		strings.NewReader(`{"endpoint":"https://push.example/1","keys":{"p256dh":"key","auth":"secret"}}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest("DELETE", "/api/terminal/notifications/push", strings.NewReader(`{"endpoint":"https://push.example/1"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", w.Code)
+	}
+}
+
+func TestTerminalHandlerInputAndRun(t *testing.T) {
+	handler, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	handler.Register(router.Group("/api"))
+
+	info, err := handler.manager.Create(terminal.CreateOptions{Command: "/bin/sh"})
+	if err != nil {
+		t.Fatalf("failed to create terminal: %v", err)
+	}
+
+	req := httptest.NewRequest("POST", "/api/terminal/"+info.ID+"/input", strings.NewReader(`{"data":"%%%","base64":true}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for invalid base64, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest("POST", "/api/terminal/missing/input", strings.NewReader(`{"data":"ls\n"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest("POST", "/api/terminal/"+info.ID+"/input", strings.NewReader(`{"data":"Y2QgLwo=","base64":true}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest("POST", "/api/terminal/"+info.ID+"/run", strings.NewReader(`{"command":"pwd; exit_code=4; (exit $exit_code)","timeout":5}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	var result terminal.RunResult
+	json.Unmarshal(w.Body.Bytes(), &result)
+	if w.Code != http.StatusOK || result.Output != "/\n" || result.ExitCode != 4 {
+		t.Errorf("unexpected run response %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestTerminalHandlerSignalAndProcesses(t *testing.T) {
+	handler, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	handler.Register(router.Group("/api"))
+
+	info, err := handler.manager.Create(terminal.CreateOptions{
+		Command: "/bin/sh",
+		Args:    []string{"-c", "sleep 30; true"},
+	})
+	if err != nil {
+		t.Fatalf("failed to create terminal: %v", err)
+	}
+
+	req := httptest.NewRequest("GET", "/api/terminal/"+info.ID+"/processes", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	var tree terminal.ProcessInfo
+	json.Unmarshal(w.Body.Bytes(), &tree)
+	if w.Code != http.StatusOK || tree.PID == 0 {
+		t.Errorf("unexpected process tree response %d %q", w.Code, w.Body.String())
+	}
+
+	req = httptest.NewRequest("POST", "/api/terminal/"+info.ID+"/signal", strings.NewReader(`{"signal":"SIGUSR2"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for unsupported signal, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest("POST", "/api/terminal/"+info.ID+"/signal", strings.NewReader(`{"signal":"SIGTERM"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d %q", w.Code, w.Body.String())
+	}
+
+	req = httptest.NewRequest("POST", "/api/terminal/missing/signal", strings.NewReader(`{"signal":"SIGTERM"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404, got %d", w.Code)
+	}
+}
+
+func TestTerminalHandlerResizePolicy(t *testing.T) {
+	handler, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	handler.Register(router.Group("/api"))
+
+	info, err := handler.manager.Create(terminal.CreateOptions{
+		Command: "/bin/sh",
+		Args:    []string{"-c", "sleep 30"},
+	})
+	if err != nil {
+		t.Fatalf("failed to create terminal: %v", err)
+	}
+
+	for body, want := range map[string]int{
+		`{"policy":"latest"}`:                      http.StatusOK,
+		`{"policy":"largest"}`:                     http.StatusBadRequest,
+		`{"policy":"pinned","client_id":"absent"}`: http.StatusNotFound,
+	} {
+		req := httptest.NewRequest("PUT", "/api/terminal/"+info.ID+"/resize-policy", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != want {
+			t.Errorf("%s: expected status %d, got %d %q", body, want, w.Code, w.Body.String())
+		}
+	}
+
+	if term, _ := handler.manager.Get(info.ID); term.ResizePolicy != terminal.ResizeLatest {
+		t.Errorf("expected policy to be updated, got %q", term.ResizePolicy)
+	}
+}
+
+func TestTerminalHandlerClients(t *testing.T) {
+	handler, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	info, err := handler.manager.Create(terminal.CreateOptions{Command: "/bin/sh"})
+	if err != nil {
+		t.Fatalf("failed to create terminal: %v", err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	handler.Register(router.Group("/api"))
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	wsURL := "ws" + server.URL[4:] + "/api/terminal/ws/" + info.ID + "?user_id=alice&mode=view"
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, http.Header{"User-Agent": {"test-agent"}})
+	if err != nil {
+		t.Fatalf("failed to connect websocket: %v", err)
+	}
+	defer conn.Close()
+	time.Sleep(100 * time.Millisecond)
+
+	resp, err := http.Get(server.URL + "/api/terminal/" + info.ID + "/clients")
+	if err != nil {
+		t.Fatalf("failed to list clients: %v", err)
+	}
+	var body struct {
+		Clients []terminal.ClientInfo `json:"clients"`
+	}
+	json.NewDecoder(resp.Body).Decode(&body)
+	resp.Body.Close()
+	if len(body.Clients) != 1 {
+		t.Fatalf("expected 1 client, got %+v", body.Clients)
+	}
+	client := body.Clients[0]
+	if client.User != "alice" || client.Mode != "view" || client.UserAgent != "test-agent" || client.RemoteAddr == "" {
+		t.Errorf("unexpected client %+v", client)
+	}
+
+	for path, want := range map[string]int{
+		"/api/terminal/" + info.ID + "/clients/absent":       http.StatusNotFound,
+		"/api/terminal/" + info.ID + "/clients/" + client.ID: http.StatusOK,
+	} {
+		req := httptest.NewRequest("DELETE", path, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != want {
+			t.Errorf("%s: expected status %d, got %d %q", path, want, w.Code, w.Body.String())
+		}
+	}
+
+	conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			break
+		}
+	}
+	if clients, _ := handler.manager.Clients(info.ID); len(clients) != 0 {
+		t.Errorf("expected the kicked client to be gone, got %+v", clients)
+	}
+}
+
+func TestTerminalHandlerSearch(t *testing.T) {
+	handler, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	handler.Register(router.Group("/api"))
+
+	info, err := handler.manager.Create(terminal.CreateOptions{
+		Command: "/bin/sh",
+		Args:    []string{"-c", "echo first; echo 'build FAILED'; echo last; sleep 30"},
+	})
+	if err != nil {
+		t.Fatalf("failed to create terminal: %v", err)
+	}
+	time.Sleep(200 * time.Millisecond)
+	handler.manager.Close(info.ID)
+
+	req := httptest.NewRequest("GET", "/api/terminal/"+info.ID+"/search?q=failed&ignore_case=true&context=1", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d %q", w.Code, w.Body.String())
+	}
+	var result terminal.SearchResult
+	json.Unmarshal(w.Body.Bytes(), &result)
+	if len(result.Matches) != 1 || result.Matches[0].Line != "build FAILED" || len(result.Matches[0].Before) != 1 {
+		t.Errorf("unexpected search result %+v", result)
+	}
+
+	for path, want := range map[string]int{
+		"/api/terminal/" + info.ID + "/search?q=(&regex=true": http.StatusBadRequest,
+		"/api/terminal/" + info.ID + "/search":                http.StatusBadRequest,
+		"/api/terminal/missing/search?q=x":                    http.StatusNotFound,
+	} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if w.Code != want {
+			t.Errorf("%s: expected status %d, got %d", path, want, w.Code)
+		}
+	}
+}
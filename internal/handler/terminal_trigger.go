@@ -0,0 +1,143 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xxnuo/vibego/internal/service/terminal"
+)
+
+type TriggerRequest struct {
+	Name      string `json:"name"`
+	Pattern   string `json:"pattern" binding:"required"`
+	Action    string `json:"action" binding:"required"`
+	Reply     string `json:"reply"`
+	URL       string `json:"url"`
+	Enabled   *bool  `json:"enabled"`
+	ProfileID string `json:"profile_id"`
+}
+
+func (r *TriggerRequest) options(user string) terminal.TriggerOptions {
+	return terminal.TriggerOptions{
+		Name:      r.Name,
+		Pattern:   r.Pattern,
+		Action:    r.Action,
+		Reply:     r.Reply,
+		URL:       r.URL,
+		Enabled:   r.Enabled == nil || *r.Enabled,
+		UserID:    user,
+		ProfileID: r.ProfileID,
+	}
+}
+
+func triggerError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, terminal.ErrTriggerNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, terminal.ErrInvalidTrigger):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// ListTriggers godoc
+// @Summary List output triggers
+// @Description Lists the triggers of the user named by the X-User-ID header and those shared by every user
+// @Tags Terminal
+// @Produce json
+// @Success 200 {object} map[string][]terminal.TriggerInfo
+// @Failure 500 {object} map[string]string
+// @Router /api/terminal/triggers [get]
+func (h *TerminalHandler) ListTriggers(c *gin.Context) {
+	triggers, err := h.manager.ListTriggers(requestUser(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"triggers": triggers})
+}
+
+// GetTrigger godoc
+// @Summary Get output trigger
+// @Tags Terminal
+// @Produce json
+// @Param id path string true "Trigger ID"
+// @Success 200 {object} terminal.TriggerInfo
+// @Failure 404 {object} map[string]string
+// @Router /api/terminal/triggers/{id} [get]
+func (h *TerminalHandler) GetTrigger(c *gin.Context) {
+	trigger, err := h.manager.GetTrigger(c.Param("id"), requestUser(c))
+	if err != nil {
+		triggerError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, trigger)
+}
+
+// CreateTrigger godoc
+// @Summary Create output trigger
+// @Description Runs action whenever a terminal's output matches the regular expression pattern: highlight, notify, reply (types reply into the terminal) or open_url (asks clients to open url, by default the matched text). reply and url may refer to submatches as $1. The trigger belongs to the user named by the X-User-ID header and applies to their terminals; without the header it applies to every terminal. profile_id further limits it to terminals of that profile. Each trigger acts a limited number of times per terminal in a time window.
+// @Tags Terminal
+// @Accept json
+// @Produce json
+// @Param request body TriggerRequest true "Trigger"
+// @Success 201 {object} terminal.TriggerInfo
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/terminal/triggers [post]
+func (h *TerminalHandler) CreateTrigger(c *gin.Context) {
+	var req TriggerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	trigger, err := h.manager.CreateTrigger(req.options(requestUser(c)))
+	if err != nil {
+		triggerError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, trigger)
+}
+
+// UpdateTrigger godoc
+// @Summary Update output trigger
+// @Tags Terminal
+// @Accept json
+// @Produce json
+// @Param id path string true "Trigger ID"
+// @Param request body TriggerRequest true "Trigger"
+// @Success 200 {object} terminal.TriggerInfo
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/terminal/triggers/{id} [put]
+func (h *TerminalHandler) UpdateTrigger(c *gin.Context) {
+	var req TriggerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	trigger, err := h.manager.UpdateTrigger(c.Param("id"), req.options(requestUser(c)))
+	if err != nil {
+		triggerError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, trigger)
+}
+
+// DeleteTrigger godoc
+// @Summary Delete output trigger
+// @Tags Terminal
+// @Produce json
+// @Param id path string true "Trigger ID"
+// @Success 200 {object} map[string]bool
+// @Failure 404 {object} map[string]string
+// @Router /api/terminal/triggers/{id} [delete]
+func (h *TerminalHandler) DeleteTrigger(c *gin.Context) {
+	if err := h.manager.DeleteTrigger(c.Param("id"), requestUser(c)); err != nil {
+		triggerError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
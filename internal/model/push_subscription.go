@@ -0,0 +1,14 @@
+package model
+
+type PushSubscription struct {
+	ID        string `gorm:"column:id;primaryKey" json:"id"`
+	UserID    string `gorm:"column:user_id;index" json:"user_id"`
+	Endpoint  string `gorm:"column:endpoint;uniqueIndex" json:"endpoint"`
+	P256dh    string `gorm:"column:p256dh" json:"-"`
+	Auth      string `gorm:"column:auth" json:"-"`
+	CreatedAt int64  `gorm:"column:created_at" json:"created_at"`
+}
+
+func (PushSubscription) TableName() string {
+	return "push_subscriptions"
+}
@@ -0,0 +1,29 @@
+package model
+
+// SSHHost is a saved remote machine. An empty HostKey falls back to
+// known_hosts; credentials are encrypted when a secret key is configured.
+type SSHHost struct {
+	ID         string `gorm:"column:id;primaryKey" json:"id"`
+	UserID     string `gorm:"column:user_id;index" json:"user_id"`
+	Name       string `gorm:"column:name" json:"name"`
+	Host       string `gorm:"column:host" json:"host"`
+	Port       int    `gorm:"column:port" json:"port"`
+	User       string `gorm:"column:user" json:"user"`
+	Auth       string `gorm:"column:auth" json:"auth"`
+	Password   string `gorm:"column:password" json:"-"`
+	PrivateKey string `gorm:"column:private_key" json:"-"`
+	Passphrase string `gorm:"column:passphrase" json:"-"`
+	HostKey    string `gorm:"column:host_key" json:"host_key"`
+	CreatedAt  int64  `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt  int64  `gorm:"column:updated_at" json:"updated_at"`
+}
+
+func (SSHHost) TableName() string {
+	return "ssh_hosts"
+}
+
+const (
+	SSHAuthPassword = "password"
+	SSHAuthKey      = "key"
+	SSHAuthAgent    = "agent"
+)
@@ -1,19 +1,30 @@
 package model
 
 type TerminalSession struct {
-	ID          string `gorm:"column:id;primaryKey" json:"id"`
-	UserID      string `gorm:"column:user_id;index" json:"user_id"`
-	Name        string `gorm:"column:name" json:"name"`
-	Shell       string `gorm:"column:shell" json:"shell"`
-	Cwd         string `gorm:"column:cwd" json:"cwd"`
-	Cols        int    `gorm:"column:cols" json:"cols"`
-	Rows        int    `gorm:"column:rows" json:"rows"`
-	Status      string `gorm:"column:status" json:"status"`
-	PTYStatus   string `gorm:"column:pty_status" json:"pty_status"`
-	ExitCode    int    `gorm:"column:exit_code" json:"exit_code"`
-	HistorySize int64  `gorm:"column:history_size" json:"history_size"`
-	CreatedAt   int64  `gorm:"column:created_at" json:"created_at"`
-	UpdatedAt   int64  `gorm:"column:updated_at" json:"updated_at"`
+	ID             string          `gorm:"column:id;primaryKey" json:"id"`
+	UserID         string          `gorm:"column:user_id;index" json:"user_id"`
+	Name           string          `gorm:"column:name" json:"name"`
+	Shell          string          `gorm:"column:shell" json:"shell"`
+	Args           []string        `gorm:"column:args;serializer:json" json:"args"`
+	StartupCommand string          `gorm:"column:startup_command" json:"startup_command"`
+	ProfileID      string          `gorm:"column:profile_id" json:"profile_id"`
+	Cwd            string          `gorm:"column:cwd" json:"cwd"`
+	Cols           int             `gorm:"column:cols" json:"cols"`
+	Rows           int             `gorm:"column:rows" json:"rows"`
+	Status         string          `gorm:"column:status" json:"status"`
+	PTYStatus      string          `gorm:"column:pty_status" json:"pty_status"`
+	ExitCode       int             `gorm:"column:exit_code" json:"exit_code"`
+	ExitSignal     string          `gorm:"column:exit_signal" json:"exit_signal"`
+	ExitReason     string          `gorm:"column:exit_reason" json:"exit_reason"`
+	HistorySize    int64           `gorm:"column:history_size" json:"history_size"`
+	Backend        string          `gorm:"column:backend" json:"backend"`
+	HostID         string          `gorm:"column:host_id" json:"host_id"`
+	Socket         string          `gorm:"column:socket" json:"-"`
+	ResizePolicy   string          `gorm:"column:resize_policy" json:"resize_policy"`
+	Limits         *TerminalLimits `gorm:"column:limits;serializer:json" json:"limits,omitempty"`
+	Cgroup         string          `gorm:"column:cgroup" json:"-"`
+	CreatedAt      int64           `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt      int64           `gorm:"column:updated_at" json:"updated_at"`
 }
 
 func (TerminalSession) TableName() string {
@@ -26,4 +37,28 @@ const (
 
 	PTYStatusRunning = "running"
 	PTYStatusExited  = "exited"
+
+	// BackendSupervisor sessions are hosted by a detached supervisor process
+	// reachable through Socket and survive server restarts. BackendSSH
+	// sessions run on the saved SSH host HostID.
+	BackendLocal      = "local"
+	BackendSupervisor = "supervisor"
+	BackendSSH        = "ssh"
+
+	// ExitReasonCPULimit and ExitReasonMemoryLimit report a process ended
+	// for exceeding its CPU time or cgroup memory limit.
+	ExitReasonCPULimit    = "cpu_limit"
+	ExitReasonMemoryLimit = "memory_limit"
 )
+
+// TerminalLimits caps a terminal's process tree. Memory and CPU need a cgroup,
+// the rest are rlimits; zero leaves a limit unset.
+type TerminalLimits struct {
+	CPUTime      int64   `json:"cpu_time,omitempty"`
+	AddressSpace int64   `json:"address_space,omitempty"`
+	OpenFiles    int64   `json:"open_files,omitempty"`
+	Processes    int64   `json:"processes,omitempty"`
+	Memory       int64   `json:"memory,omitempty"`
+	CPU          float64 `json:"cpu,omitempty"`
+	Sandbox      bool    `json:"sandbox,omitempty"`
+}
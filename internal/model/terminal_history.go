@@ -2,8 +2,9 @@ package model
 
 type TerminalHistory struct {
 	ID        int64  `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
-	SessionID string `gorm:"column:session_id;index" json:"session_id"`
-	Sequence  int64  `gorm:"column:sequence" json:"sequence"`
+	SessionID string `gorm:"column:session_id;index;index:idx_terminal_history_seq,priority:1" json:"session_id"`
+	Sequence  int64  `gorm:"column:sequence;index:idx_terminal_history_seq,priority:2" json:"sequence"`
+	Offset    int64  `gorm:"column:offset" json:"offset"`
 	Data      []byte `gorm:"column:data" json:"data"`
 	CreatedAt int64  `gorm:"column:created_at" json:"created_at"`
 }
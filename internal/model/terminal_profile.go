@@ -0,0 +1,19 @@
+package model
+
+type TerminalProfile struct {
+	ID        string            `gorm:"column:id;primaryKey" json:"id"`
+	UserID    string            `gorm:"column:user_id;index" json:"user_id"`
+	Name      string            `gorm:"column:name" json:"name"`
+	Program   string            `gorm:"column:program" json:"program"`
+	Args      []string          `gorm:"column:args;serializer:json" json:"args"`
+	Env       map[string]string `gorm:"column:env;serializer:json" json:"env"`
+	Cwd       string            `gorm:"column:cwd" json:"cwd"`
+	Icon      string            `gorm:"column:icon" json:"icon"`
+	Limits    *TerminalLimits   `gorm:"column:limits;serializer:json" json:"limits,omitempty"`
+	CreatedAt int64             `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt int64             `gorm:"column:updated_at" json:"updated_at"`
+}
+
+func (TerminalProfile) TableName() string {
+	return "terminal_profiles"
+}
@@ -0,0 +1,19 @@
+package model
+
+type TerminalRecording struct {
+	ID        string  `gorm:"column:id;primaryKey" json:"id"`
+	SessionID string  `gorm:"column:session_id;index" json:"session_id"`
+	UserID    string  `gorm:"column:user_id;index" json:"user_id"`
+	Title     string  `gorm:"column:title" json:"title"`
+	Path      string  `gorm:"column:path" json:"-"`
+	Cols      int     `gorm:"column:cols" json:"cols"`
+	Rows      int     `gorm:"column:rows" json:"rows"`
+	Size      int64   `gorm:"column:size" json:"size"`
+	Duration  float64 `gorm:"column:duration" json:"duration"`
+	CreatedAt int64   `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt int64   `gorm:"column:updated_at" json:"updated_at"`
+}
+
+func (TerminalRecording) TableName() string {
+	return "terminal_recordings"
+}
@@ -0,0 +1,28 @@
+package model
+
+// TerminalTrigger runs Action when output matches Pattern. Empty UserID or
+// ProfileID match all; Reply and URL may use submatches as $1 or ${name}.
+type TerminalTrigger struct {
+	ID        string `gorm:"column:id;primaryKey" json:"id"`
+	UserID    string `gorm:"column:user_id;index" json:"user_id"`
+	ProfileID string `gorm:"column:profile_id;index" json:"profile_id"`
+	Name      string `gorm:"column:name" json:"name"`
+	Pattern   string `gorm:"column:pattern" json:"pattern"`
+	Action    string `gorm:"column:action" json:"action"`
+	Reply     string `gorm:"column:reply" json:"reply"`
+	URL       string `gorm:"column:url" json:"url"`
+	Enabled   bool   `gorm:"column:enabled" json:"enabled"`
+	CreatedAt int64  `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt int64  `gorm:"column:updated_at" json:"updated_at"`
+}
+
+func (TerminalTrigger) TableName() string {
+	return "terminal_triggers"
+}
+
+const (
+	TriggerHighlight = "highlight"
+	TriggerNotify    = "notify"
+	TriggerReply     = "reply"
+	TriggerOpenURL   = "open_url"
+)
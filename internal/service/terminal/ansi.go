@@ -0,0 +1,79 @@
+package terminal
+
+// stripANSI removes escape sequences and control characters except newlines
+// and tabs.
+func stripANSI(data []byte) []byte {
+	out, _ := stripANSIIndex(data, false)
+	return out
+}
+
+// stripANSIIndex is stripANSI that, when withIndex is set, also returns the
+// position in data of every byte kept.
+func stripANSIIndex(data []byte, withIndex bool) ([]byte, []int) {
+	out := make([]byte, 0, len(data))
+	var index []int
+	for i := 0; i < len(data); i++ {
+		b := data[i]
+		switch {
+		case b == 0x1b:
+			i, _ = skipEscape(data, i)
+			continue
+		case b == '\n' || b == '\t':
+		case b < 0x20 || b == 0x7f:
+			continue
+		}
+		out = append(out, b)
+		if withIndex {
+			index = append(index, i)
+		}
+	}
+	return out, index
+}
+
+// skipEscape returns the index of the last byte of the escape sequence at
+// data[i] and whether it is complete.
+func skipEscape(data []byte, i int) (int, bool) {
+	if i+1 >= len(data) {
+		return i, false
+	}
+	switch data[i+1] {
+	case '[':
+		for j := i + 2; j < len(data); j++ {
+			if data[j] >= 0x40 && data[j] <= 0x7e {
+				return j, true
+			}
+		}
+	case ']', 'P', '_', '^':
+		for j := i + 2; j < len(data); j++ {
+			if data[j] == 0x07 {
+				return j, true
+			}
+			if data[j] == 0x1b && j+1 < len(data) && data[j+1] == '\\' {
+				return j + 1, true
+			}
+		}
+	default:
+		j := i + 1
+		for j < len(data)-1 && data[j] >= 0x20 && data[j] <= 0x2f {
+			j++
+		}
+		return j, data[j] < 0x20 || data[j] > 0x2f
+	}
+	return len(data) - 1, false
+}
+
+// splitEscape splits off an escape sequence left unfinished at the end of
+// data, which the next read completes.
+func splitEscape(data []byte) (complete, pending []byte) {
+	for i := 0; i < len(data); i++ {
+		if data[i] != 0x1b {
+			continue
+		}
+		end, ok := skipEscape(data, i)
+		if !ok {
+			return data[:i], data[i:]
+		}
+		i = end
+	}
+	return data, nil
+}
@@ -0,0 +1,77 @@
+package terminal
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/xxnuo/vibego/internal/model"
+)
+
+// backend starts the process behind a new terminal.
+type backend func(session *model.TerminalSession, opts CreateOptions) (process, error)
+
+func (m *Manager) registerBackends() {
+	m.backends = map[string]backend{
+		model.BackendLocal:      m.startLocal,
+		model.BackendSupervisor: m.startSupervised,
+		model.BackendSSH:        m.startSSH,
+	}
+}
+
+// backendFor picks the backend of a new terminal.
+func (m *Manager) backendFor(opts CreateOptions) (string, error) {
+	name := opts.Backend
+	switch {
+	case name != "":
+	case opts.HostID != "":
+		name = model.BackendSSH
+	case m.persistent:
+		name = model.BackendSupervisor
+	default:
+		name = model.BackendLocal
+	}
+	if _, ok := m.backends[name]; !ok {
+		return "", ErrUnknownBackend
+	}
+	if name == model.BackendSSH && opts.HostID == "" {
+		return "", ErrSSHHostNotFound
+	}
+	return name, nil
+}
+
+func (m *Manager) startLocal(session *model.TerminalSession, opts CreateOptions) (process, error) {
+	lc, err := newLocalCommand(session.Shell, session.Args, session.Cwd, session.Cols, session.Rows,
+		withBaseEnv(m.env.inherit(os.Environ())),
+		withEnv(m.terminalEnv(session.Cwd, opts.Env)),
+		withTerm(opts.Term),
+		withShellIntegration(opts.ShellIntegration),
+		withLimits(session.Limits, session.Cgroup),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return lc, nil
+}
+
+func (m *Manager) startSupervised(session *model.TerminalSession, opts CreateOptions) (process, error) {
+	session.Socket = filepath.Join(m.supervisorDir, session.ID+".sock")
+	sc, err := startSupervisor(supervisorSpec{
+		Socket:           session.Socket,
+		Command:          session.Shell,
+		Args:             session.Args,
+		Cwd:              session.Cwd,
+		Cols:             session.Cols,
+		Rows:             session.Rows,
+		BaseEnv:          m.env.inherit(os.Environ()),
+		Env:              m.terminalEnv(session.Cwd, opts.Env),
+		Term:             opts.Term,
+		ShellIntegration: opts.ShellIntegration,
+		Limits:           session.Limits,
+		Cgroup:           session.Cgroup,
+	})
+	if err != nil {
+		return nil, err
+	}
+	sc.command, sc.argv, sc.cwd = session.Shell, session.Args, session.Cwd
+	return sc, nil
+}
@@ -0,0 +1,124 @@
+package terminal
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+)
+
+// Reasons reported when a client leaves a terminal.
+const (
+	LeaveClosed   = "closed"
+	LeaveKicked   = "kicked"
+	LeaveOverflow = "overflow"
+)
+
+const kickDrainTimeout = time.Second
+
+// info describes the client. Callers must hold the terminal's resizeMu.
+func (instance *webTTYInstance) info() ClientInfo {
+	mode := "write"
+	if instance.ReadOnly {
+		mode = "view"
+	}
+	return ClientInfo{
+		ID:           instance.ID,
+		User:         instance.User,
+		RemoteAddr:   instance.RemoteAddr,
+		UserAgent:    instance.UserAgent,
+		AttachedAt:   instance.AttachedAt.Unix(),
+		Mode:         mode,
+		ReadOnly:     instance.ReadOnly,
+		Binary:       instance.Binary,
+		QueuedBytes:  instance.Queue.queuedBytes(),
+		DroppedBytes: instance.Queue.dropped.Load(),
+		Cols:         instance.cols,
+		Rows:         instance.rows,
+	}
+}
+
+// leave disconnects the client, recording why for the leave message.
+func (instance *webTTYInstance) leave(reason string) {
+	instance.reason.CompareAndSwap(nil, reason)
+	instance.Cancel()
+}
+
+func (instance *webTTYInstance) leaveReason() string {
+	if reason, ok := instance.reason.Load().(string); ok {
+		return reason
+	}
+	return LeaveClosed
+}
+
+func (at *activeTerminal) clientMessage(instance *webTTYInstance, msgType, reason string) []byte {
+	at.resizeMu.Lock()
+	info := instance.info()
+	at.resizeMu.Unlock()
+	msgData, _ := json.Marshal(ClientMessage{Type: msgType, Client: info, Reason: reason})
+	return msgData
+}
+
+// announceClient tells every other client that instance joined or left.
+func (at *activeTerminal) announceClient(instance *webTTYInstance, msgType, reason string) {
+	msgData := at.clientMessage(instance, msgType, reason)
+	at.WebTTYs.Range(func(key, value any) bool {
+		if other := value.(*webTTYInstance); other != instance {
+			other.Queue.Write(msgData)
+		}
+		return true
+	})
+}
+
+// closeKicked sends a kicked client its own leave message and waits briefly
+// for it to be written before the connection is closed.
+func (at *activeTerminal) closeKicked(instance *webTTYInstance) {
+	instance.Queue.Write(at.clientMessage(instance, MsgTypeClientLeave, LeaveKicked))
+	instance.Queue.close(true)
+	select {
+	case <-instance.Queue.done:
+	case <-time.After(kickDrainTimeout):
+	}
+}
+
+// Clients lists the clients attached to a terminal in the order they joined.
+func (m *Manager) Clients(id string) ([]ClientInfo, error) {
+	at, ok := m.getActive(id)
+	if !ok {
+		return nil, ErrTerminalNotFound
+	}
+
+	var instances []*webTTYInstance
+	at.WebTTYs.Range(func(key, value any) bool {
+		instances = append(instances, value.(*webTTYInstance))
+		return true
+	})
+	sort.Slice(instances, func(i, j int) bool {
+		if !instances[i].AttachedAt.Equal(instances[j].AttachedAt) {
+			return instances[i].AttachedAt.Before(instances[j].AttachedAt)
+		}
+		return instances[i].ID < instances[j].ID
+	})
+
+	clients := make([]ClientInfo, 0, len(instances))
+	at.resizeMu.Lock()
+	for _, instance := range instances {
+		clients = append(clients, instance.info())
+	}
+	at.resizeMu.Unlock()
+	return clients, nil
+}
+
+// Kick disconnects one client. The client is told it was kicked and the
+// others see it leave.
+func (m *Manager) Kick(id, clientID string) error {
+	at, ok := m.getActive(id)
+	if !ok {
+		return ErrTerminalNotFound
+	}
+	value, ok := at.WebTTYs.Load(clientID)
+	if !ok {
+		return ErrClientNotFound
+	}
+	value.(*webTTYInstance).leave(LeaveKicked)
+	return nil
+}
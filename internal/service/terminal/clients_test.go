@@ -0,0 +1,111 @@
+package terminal
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// readClientMessage reads until a client_join or client_leave message arrives.
+func readClientMessage(t *testing.T, conn *websocket.Conn) ClientMessage {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("failed to read client message: %v", err)
+		}
+		var msg ClientMessage
+		if json.Unmarshal(data, &msg) == nil && (msg.Type == MsgTypeClientJoin || msg.Type == MsgTypeClientLeave) {
+			return msg
+		}
+	}
+}
+
+func TestManager_ClientRoster(t *testing.T) {
+	db := setupTestDB(t)
+	manager := NewManager(db, &ManagerConfig{Shell: "/bin/sh"})
+
+	info, err := manager.Create(CreateOptions{Cwd: os.TempDir(), Command: "/bin/sh"})
+	if err != nil {
+		t.Fatalf("failed to create terminal: %v", err)
+	}
+	defer manager.Close(info.ID)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upgrader := websocket.Upgrader{}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		opts := AttachOptions{
+			ReadOnly:   r.URL.Query().Get("mode") == "view",
+			User:       r.URL.Query().Get("user"),
+			RemoteAddr: "203.0.113.7",
+			UserAgent:  r.UserAgent(),
+		}
+		if _, err := manager.Attach(info.ID, conn, opts); err != nil {
+			conn.Close()
+		}
+	}))
+	defer server.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http")
+	alice, _, err := websocket.DefaultDialer.Dial(wsURL+"?user=alice", http.Header{"User-Agent": {"laptop"}})
+	if err != nil {
+		t.Fatalf("alice failed to dial: %v", err)
+	}
+	defer alice.Close()
+	time.Sleep(50 * time.Millisecond)
+
+	bob, _, err := websocket.DefaultDialer.Dial(wsURL+"?user=bob&mode=view", http.Header{"User-Agent": {"phone"}})
+	if err != nil {
+		t.Fatalf("bob failed to dial: %v", err)
+	}
+	defer bob.Close()
+
+	joined := readClientMessage(t, alice)
+	if joined.Type != MsgTypeClientJoin || joined.Client.User != "bob" || joined.Client.Mode != "view" {
+		t.Errorf("expected alice to see bob join, got %+v", joined)
+	}
+
+	clients, err := manager.Clients(info.ID)
+	if err != nil || len(clients) != 2 {
+		t.Fatalf("expected 2 clients, got %v (%v)", clients, err)
+	}
+	first := clients[0]
+	if first.User != "alice" || first.Mode != "write" || first.RemoteAddr != "203.0.113.7" || first.UserAgent != "laptop" || first.AttachedAt == 0 {
+		t.Errorf("unexpected roster entry %+v", first)
+	}
+	if clients[1].ID != joined.Client.ID {
+		t.Errorf("expected bob second in the roster, got %+v", clients[1])
+	}
+
+	if err := manager.Kick(info.ID, "nobody"); err != ErrClientNotFound {
+		t.Errorf("expected ErrClientNotFound, got %v", err)
+	}
+	if err := manager.Kick(info.ID, joined.Client.ID); err != nil {
+		t.Fatalf("Kick failed: %v", err)
+	}
+
+	if own := readClientMessage(t, bob); own.Type != MsgTypeClientLeave || own.Reason != LeaveKicked {
+		t.Errorf("expected the kicked client to be told, got %+v", own)
+	}
+	if _, _, err := bob.ReadMessage(); err == nil {
+		t.Error("expected the kicked connection to be closed")
+	}
+	left := readClientMessage(t, alice)
+	if left.Type != MsgTypeClientLeave || left.Client.ID != joined.Client.ID || left.Reason != LeaveKicked {
+		t.Errorf("expected alice to see bob kicked, got %+v", left)
+	}
+
+	if clients, _ := manager.Clients(info.ID); len(clients) != 1 {
+		t.Errorf("expected 1 client after the kick, got %d", len(clients))
+	}
+}
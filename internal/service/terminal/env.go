@@ -0,0 +1,78 @@
+package terminal
+
+import (
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// DefaultEnvDeny lists the variables terminals never inherit, matched
+// case-insensitively with path.Match.
+var DefaultEnvDeny = []string{
+	"VG_*",
+	"*TOKEN*",
+	"*SECRET*",
+	"*PASSWORD*",
+	"*PASSWD*",
+	"*API_KEY*",
+	"*APIKEY*",
+	"*ACCESS_KEY*",
+	"*PRIVATE_KEY*",
+	"*CREDENTIAL*",
+}
+
+// EnvPolicy filters the inherited environment and injects Workspaces
+// variables by directory, deepest first. Explicit variables are not filtered.
+type EnvPolicy struct {
+	Deny       []string
+	Allow      []string
+	Workspaces map[string]map[string]string
+}
+
+func matchEnv(patterns []string, name string) bool {
+	name = strings.ToUpper(name)
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(strings.ToUpper(pattern), name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// inherit filters environ, a list of KEY=value entries, through the policy.
+func (p *EnvPolicy) inherit(environ []string) []string {
+	env := make([]string, 0, len(environ))
+	for _, kv := range environ {
+		name, _, _ := strings.Cut(kv, "=")
+		if matchEnv(DefaultEnvDeny, name) || matchEnv(p.Deny, name) {
+			continue
+		}
+		if len(p.Allow) > 0 && !matchEnv(p.Allow, name) {
+			continue
+		}
+		env = append(env, kv)
+	}
+	return env
+}
+
+// inject returns the workspace variables that apply to a terminal started
+// in cwd.
+func (p *EnvPolicy) inject(cwd string) []string {
+	var dirs []string
+	for dir := range p.Workspaces {
+		rel, err := filepath.Rel(filepath.Clean(dir), filepath.Clean(cwd))
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, "../") {
+			dirs = append(dirs, dir)
+		}
+	}
+	sort.Slice(dirs, func(i, j int) bool { return len(filepath.Clean(dirs[i])) < len(filepath.Clean(dirs[j])) })
+
+	vars := make(map[string]string)
+	for _, dir := range dirs {
+		for k, v := range p.Workspaces[dir] {
+			vars[k] = v
+		}
+	}
+	return envList(vars)
+}
@@ -0,0 +1,112 @@
+package terminal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEnvPolicy(t *testing.T) {
+	environ := []string{"PATH=/bin", "HOME=/root", "VG_TOKEN=t", "GITHUB_TOKEN=g", "db_password=p", "LANG=C", "EDITOR=vi"}
+
+	policy := &EnvPolicy{Deny: []string{"EDITOR"}}
+	if got := policy.inherit(environ); !reflect.DeepEqual(got, []string{"PATH=/bin", "HOME=/root", "LANG=C"}) {
+		t.Errorf("unexpected denylist result %v", got)
+	}
+	policy = &EnvPolicy{Allow: []string{"PATH", "L*", "VG_TOKEN"}}
+	if got := policy.inherit(environ); !reflect.DeepEqual(got, []string{"PATH=/bin", "LANG=C"}) {
+		t.Errorf("unexpected allowlist result %v", got)
+	}
+
+	policy = &EnvPolicy{Workspaces: map[string]map[string]string{
+		"/srv":         {"STAGE": "dev", "REGION": "eu"},
+		"/srv/app/":    {"STAGE": "prod"},
+		"/srv/appdata": {"STAGE": "data"},
+	}}
+	if got := policy.inject("/srv/app/web"); !reflect.DeepEqual(got, []string{"REGION=eu", "STAGE=prod"}) {
+		t.Errorf("expected the deepest workspace to win, got %v", got)
+	}
+	if got := policy.inject("/tmp"); len(got) != 0 {
+		t.Errorf("expected no variables outside workspaces, got %v", got)
+	}
+}
+
+func TestManager_EnvTokenNeverReachesChild(t *testing.T) {
+	t.Setenv("VG_TOKEN", "vg-secret-token")
+	t.Setenv("OPENAI_API_KEY", "vg-secret-key")
+	workspace := t.TempDir()
+
+	for _, persistent := range []bool{false, true} {
+		t.Run(fmt.Sprintf("persistent=%v", persistent), func(t *testing.T) {
+			// Unix socket paths are short, so the subtest's TempDir won't do.
+			supervisorDir, err := os.MkdirTemp("", "vg")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(supervisorDir)
+
+			db := setupTestDB(t)
+			manager := NewManager(db, &ManagerConfig{
+				Shell:         "/bin/sh",
+				Persistent:    persistent,
+				SupervisorDir: supervisorDir,
+				Env: EnvPolicy{Workspaces: map[string]map[string]string{
+					workspace: {"PROJECT_STAGE": "staging"},
+				}},
+			})
+
+			info, err := manager.Create(CreateOptions{
+				Cwd:     workspace,
+				Command: "/bin/sh",
+				Args:    []string{"-c", "env; echo ENV_DONE; sleep 30"},
+				Env:     map[string]string{"EXPLICIT_VAR": "kept"},
+			})
+			if err != nil {
+				t.Fatalf("failed to create terminal: %v", err)
+			}
+			defer manager.Close(info.ID)
+			waitScreen(t, manager, info.ID, "ENV_DONE")
+
+			for query, want := range map[string]int{
+				"vg-secret":               0,
+				"^VG_":                    0,
+				"^PATH=":                  1,
+				"^PROJECT_STAGE=staging$": 1,
+				"^EXPLICIT_VAR=kept$":     1,
+			} {
+				result, err := manager.Search(info.ID, SearchOptions{Query: query, Regex: true})
+				if err != nil {
+					t.Fatalf("Search failed: %v", err)
+				}
+				if len(result.Matches) != want {
+					t.Errorf("expected %d matches for %q in the child's environment, got %d", want, query, len(result.Matches))
+				}
+			}
+
+			if !persistent {
+				return
+			}
+			at, _ := manager.getActive(info.ID)
+			status, err := os.ReadFile(filepath.Join("/proc", fmt.Sprint(at.PTY.(*supervisedCommand).Pid()), "status"))
+			if err != nil {
+				t.Skip("/proc not available")
+			}
+			var ppid int
+			for _, line := range strings.Split(string(status), "\n") {
+				if strings.HasPrefix(line, "PPid:") {
+					fmt.Sscan(strings.TrimPrefix(line, "PPid:"), &ppid)
+				}
+			}
+			environ, err := os.ReadFile(filepath.Join("/proc", fmt.Sprint(ppid), "environ"))
+			if err != nil {
+				t.Fatalf("failed to read the supervisor's environment: %v", err)
+			}
+			if strings.Contains(string(environ), "vg-secret") {
+				t.Error("expected the supervisor not to inherit the token")
+			}
+		})
+	}
+}
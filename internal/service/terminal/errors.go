@@ -3,8 +3,31 @@ package terminal
 import "errors"
 
 var (
-	ErrSlaveClosed           = errors.New("slave closed")
-	ErrMasterClosed          = errors.New("master closed")
-	ErrTerminalNotFound      = errors.New("terminal not found")
-	ErrMaxConnectionsReached = errors.New("max connections reached")
+	ErrSlaveClosed            = errors.New("slave closed")
+	ErrMasterClosed           = errors.New("master closed")
+	ErrTerminalNotFound       = errors.New("terminal not found")
+	ErrMaxConnectionsReached  = errors.New("max connections reached")
+	ErrProfileNotFound        = errors.New("profile not found")
+	ErrProfileReadOnly        = errors.New("built-in profiles cannot be modified")
+	ErrRecordingNotFound      = errors.New("recording not found")
+	ErrRecordingActive        = errors.New("recording is still in progress")
+	ErrTerminalExited         = errors.New("terminal process has exited")
+	ErrTerminalBusy           = errors.New("terminal is running another command")
+	ErrInvalidSignal          = errors.New("unsupported signal")
+	ErrNoProcess              = errors.New("terminal has no local process")
+	ErrProcessTreeUnsupported = errors.New("process tree requires /proc")
+	ErrInvalidResizePolicy    = errors.New("unknown resize policy")
+	ErrClientNotFound         = errors.New("client not found")
+	ErrInvalidQuery           = errors.New("invalid search query")
+	ErrUnknownBackend         = errors.New("unknown terminal backend")
+	ErrSSHHostNotFound        = errors.New("ssh host not found")
+	ErrInvalidSSHHost         = errors.New("invalid ssh host")
+	ErrSSHHostKey             = errors.New("ssh host key verification failed")
+	ErrSSHAgentUnavailable    = errors.New("ssh agent unavailable")
+	ErrInvalidLimits          = errors.New("invalid resource limits")
+	ErrLimitsUnsupported      = errors.New("terminal backend does not support resource limits")
+	ErrCgroupUnavailable      = errors.New("cgroup limits are not available")
+	ErrGroupNotFound          = errors.New("broadcast group not found")
+	ErrTriggerNotFound        = errors.New("trigger not found")
+	ErrInvalidTrigger         = errors.New("invalid trigger")
 )
@@ -0,0 +1,303 @@
+package terminal
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/xxnuo/vibego/internal/model"
+)
+
+// GroupInfo describes a broadcast group. Clients counts the WebSocket
+// clients attached to the group.
+type GroupInfo struct {
+	ID        string   `json:"id"`
+	Name      string   `json:"name"`
+	Members   []string `json:"members"`
+	Clients   int      `json:"clients"`
+	CreatedAt int64    `json:"created_at"`
+}
+
+// broadcastGroup fans its clients' input out to every member terminal.
+type broadcastGroup struct {
+	id        string
+	name      string
+	createdAt int64
+	mu        sync.Mutex
+	members   []string
+	clients   sync.Map // client ID -> *groupClient
+}
+
+type groupClient struct {
+	queue  *clientQueue
+	cancel context.CancelFunc
+}
+
+func (g *broadcastGroup) info() *GroupInfo {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	info := &GroupInfo{
+		ID:        g.id,
+		Name:      g.name,
+		Members:   slices.Clone(g.members),
+		CreatedAt: g.createdAt,
+	}
+	g.clients.Range(func(key, value any) bool {
+		info.Clients++
+		return true
+	})
+	return info
+}
+
+func (g *broadcastGroup) memberIDs() []string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return slices.Clone(g.members)
+}
+
+// announceMembers pushes the current members to every client of the group.
+func (g *broadcastGroup) announceMembers() {
+	msgData := g.membersMessage()
+	g.clients.Range(func(key, value any) bool {
+		value.(*groupClient).queue.Write(msgData)
+		return true
+	})
+}
+
+func (g *broadcastGroup) membersMessage() []byte {
+	msgData, _ := json.Marshal(GroupMessage{
+		Type:    MsgTypeGroup,
+		Members: g.memberIDs(),
+	})
+	return msgData
+}
+
+// groupSlave is the slave of a group client. Input is written to every
+// member that is still running; there is no output to read.
+type groupSlave struct {
+	m     *Manager
+	group *broadcastGroup
+}
+
+func (gs *groupSlave) Read(p []byte) (int, error) {
+	return 0, io.EOF
+}
+
+func (gs *groupSlave) Write(p []byte) (int, error) {
+	for _, id := range gs.group.memberIDs() {
+		at, ok := gs.m.getActive(id)
+		if !ok || at.ptyStatus.Load() == model.PTYStatusExited {
+			continue
+		}
+		at.PTY.Write(p)
+		at.touch()
+	}
+	return len(p), nil
+}
+
+func (gs *groupSlave) ResizeTerminal(cols, rows int) error {
+	return nil
+}
+
+func (gs *groupSlave) WindowTitleVariables() map[string]interface{} {
+	return nil
+}
+
+func (gs *groupSlave) Close() error {
+	return nil
+}
+
+func (m *Manager) getGroup(id string) (*broadcastGroup, bool) {
+	val, ok := m.groups.Load(id)
+	if !ok {
+		return nil, false
+	}
+	return val.(*broadcastGroup), true
+}
+
+// checkMembers makes sure every terminal to be added to a group is
+// running and drops duplicates.
+func (m *Manager) checkMembers(members []string) ([]string, error) {
+	result := make([]string, 0, len(members))
+	for _, id := range members {
+		if _, ok := m.getActive(id); !ok {
+			return nil, ErrTerminalNotFound
+		}
+		if !slices.Contains(result, id) {
+			result = append(result, id)
+		}
+	}
+	return result, nil
+}
+
+func (m *Manager) CreateGroup(name string, members []string) (*GroupInfo, error) {
+	members, err := m.checkMembers(members)
+	if err != nil {
+		return nil, err
+	}
+	g := &broadcastGroup{
+		id:        uuid.New().String(),
+		name:      name,
+		createdAt: time.Now().Unix(),
+		members:   members,
+	}
+	m.groups.Store(g.id, g)
+	return g.info(), nil
+}
+
+func (m *Manager) ListGroups() []GroupInfo {
+	result := []GroupInfo{}
+	m.groups.Range(func(key, value any) bool {
+		result = append(result, *value.(*broadcastGroup).info())
+		return true
+	})
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].CreatedAt != result[j].CreatedAt {
+			return result[i].CreatedAt < result[j].CreatedAt
+		}
+		return result[i].ID < result[j].ID
+	})
+	return result
+}
+
+func (m *Manager) GetGroup(id string) (*GroupInfo, error) {
+	g, ok := m.getGroup(id)
+	if !ok {
+		return nil, ErrGroupNotFound
+	}
+	return g.info(), nil
+}
+
+// DeleteGroup removes a group and disconnects its clients. The member
+// terminals are left alone.
+func (m *Manager) DeleteGroup(id string) error {
+	val, ok := m.groups.LoadAndDelete(id)
+	if !ok {
+		return ErrGroupNotFound
+	}
+	g := val.(*broadcastGroup)
+	g.clients.Range(func(key, value any) bool {
+		value.(*groupClient).cancel()
+		return true
+	})
+	return nil
+}
+
+// AddGroupMembers adds running terminals to a group. Terminals that are
+// already members are skipped.
+func (m *Manager) AddGroupMembers(id string, members []string) (*GroupInfo, error) {
+	g, ok := m.getGroup(id)
+	if !ok {
+		return nil, ErrGroupNotFound
+	}
+	members, err := m.checkMembers(members)
+	if err != nil {
+		return nil, err
+	}
+	g.mu.Lock()
+	for _, member := range members {
+		if !slices.Contains(g.members, member) {
+			g.members = append(g.members, member)
+		}
+	}
+	g.mu.Unlock()
+	g.announceMembers()
+	return g.info(), nil
+}
+
+func (m *Manager) RemoveGroupMember(id, member string) (*GroupInfo, error) {
+	g, ok := m.getGroup(id)
+	if !ok {
+		return nil, ErrGroupNotFound
+	}
+	g.mu.Lock()
+	i := slices.Index(g.members, member)
+	if i >= 0 {
+		g.members = slices.Delete(g.members, i, i+1)
+	}
+	g.mu.Unlock()
+	if i < 0 {
+		return nil, ErrTerminalNotFound
+	}
+	g.announceMembers()
+	return g.info(), nil
+}
+
+// leaveGroups removes a closed terminal from every group it belongs to.
+func (m *Manager) leaveGroups(member string) {
+	m.groups.Range(func(key, value any) bool {
+		g := value.(*broadcastGroup)
+		g.mu.Lock()
+		i := slices.Index(g.members, member)
+		if i >= 0 {
+			g.members = slices.Delete(g.members, i, i+1)
+		}
+		g.mu.Unlock()
+		if i >= 0 {
+			g.announceMembers()
+		}
+		return true
+	})
+}
+
+// AttachGroup broadcasts a client's input to the group's members and sends
+// it the member list, but no output.
+func (m *Manager) AttachGroup(id string, conn *websocket.Conn) (*Connection, error) {
+	g, ok := m.getGroup(id)
+	if !ok {
+		return nil, ErrGroupNotFound
+	}
+
+	if m.maxConnections > 0 && int(m.activeConns.Load()) >= m.maxConnections {
+		return nil, ErrMaxConnectionsReached
+	}
+
+	clientID := uuid.New().String()
+	wsm := newWSMaster(conn, m.keepalive)
+	queue := newClientQueue(wsm, m.clientQueueSize)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	doneCh := make(chan struct{})
+
+	queue.onError = cancel
+	go queue.run()
+
+	wt := newWebTTY(
+		queue,
+		&groupSlave{m: m, group: g},
+		withBufferSize(m.bufferSize),
+		withBinary(conn.Subprotocol() == SubprotocolBinary),
+		withSkipSlaveReadLoop(true),
+		withOnResize(func(cols, rows int) {}),
+		withOnReady(func() {
+			g.clients.Store(clientID, &groupClient{queue: queue, cancel: cancel})
+			queue.Write(g.membersMessage())
+			m.activeConns.Add(1)
+			// The group may have been deleted while the client attached.
+			if _, ok := m.getGroup(id); !ok {
+				cancel()
+			}
+		}),
+		withOnClosed(func() {
+			g.clients.Delete(clientID)
+			m.activeConns.Add(-1)
+			queue.close(false)
+			wsm.Close()
+			close(doneCh)
+		}),
+	)
+
+	go func() {
+		if err := wt.Run(ctx); err != nil {
+			cancel()
+		}
+	}()
+
+	return &Connection{Done: doneCh}, nil
+}
@@ -0,0 +1,130 @@
+package terminal
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// readGroupMessage reads until a group message arrives.
+func readGroupMessage(t *testing.T, conn *websocket.Conn) GroupMessage {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("failed to read group message: %v", err)
+		}
+		var msg GroupMessage
+		if json.Unmarshal(data, &msg) == nil && msg.Type == MsgTypeGroup {
+			return msg
+		}
+	}
+}
+
+func TestManager_BroadcastGroup(t *testing.T) {
+	db := setupTestDB(t)
+	manager := NewManager(db, &ManagerConfig{Shell: "/bin/sh"})
+
+	var ids []string
+	for i := 0; i < 3; i++ {
+		info, err := manager.Create(CreateOptions{Cwd: os.TempDir(), Command: "/bin/sh"})
+		if err != nil {
+			t.Fatalf("failed to create terminal: %v", err)
+		}
+		defer manager.Close(info.ID)
+		ids = append(ids, info.ID)
+	}
+
+	if _, err := manager.CreateGroup("bad", []string{"missing"}); err != ErrTerminalNotFound {
+		t.Errorf("expected ErrTerminalNotFound, got %v", err)
+	}
+	group, err := manager.CreateGroup("worktrees", []string{ids[0], ids[1], ids[0]})
+	if err != nil {
+		t.Fatalf("CreateGroup failed: %v", err)
+	}
+	if !slices.Equal(group.Members, ids[:2]) {
+		t.Errorf("expected members without duplicates, got %v", group.Members)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upgrader := websocket.Upgrader{}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		if _, err := manager.AttachGroup(group.ID, conn); err != nil {
+			conn.Close()
+		}
+	}))
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	defer conn.Close()
+	if msg := readGroupMessage(t, conn); !slices.Equal(msg.Members, ids[:2]) {
+		t.Errorf("expected the members on attach, got %v", msg.Members)
+	}
+	if info, _ := manager.GetGroup(group.ID); info.Clients != 1 {
+		t.Errorf("expected 1 group client, got %d", info.Clients)
+	}
+
+	send := func(input string) {
+		msg, _ := json.Marshal(WSMessage{Type: MsgTypeCmd, Data: base64.StdEncoding.EncodeToString([]byte(input))})
+		if err := conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+			t.Fatalf("failed to send input: %v", err)
+		}
+	}
+	send("echo FAN$((40+2))\n")
+	waitScreen(t, manager, ids[0], "FAN42")
+	waitScreen(t, manager, ids[1], "FAN42")
+
+	if _, err := manager.AddGroupMembers(group.ID, []string{ids[2]}); err != nil {
+		t.Fatalf("AddGroupMembers failed: %v", err)
+	}
+	if msg := readGroupMessage(t, conn); len(msg.Members) != 3 {
+		t.Errorf("expected 3 members after adding one, got %v", msg.Members)
+	}
+	if _, err := manager.RemoveGroupMember(group.ID, ids[0]); err != nil {
+		t.Fatalf("RemoveGroupMember failed: %v", err)
+	}
+	readGroupMessage(t, conn)
+	send("echo NEXT$((40+3))\n")
+	waitScreen(t, manager, ids[1], "NEXT43")
+	waitScreen(t, manager, ids[2], "NEXT43")
+	time.Sleep(100 * time.Millisecond)
+	if dump, _ := manager.Screen(ids[0], false); strings.Contains(strings.Join(dump.Lines, "\n"), "NEXT43") {
+		t.Error("expected the removed member to get no input")
+	}
+
+	manager.Close(ids[1])
+	if msg := readGroupMessage(t, conn); !slices.Equal(msg.Members, ids[2:]) {
+		t.Errorf("expected a closed terminal to leave the group, got %v", msg.Members)
+	}
+
+	if err := manager.DeleteGroup(group.ID); err != nil {
+		t.Fatalf("DeleteGroup failed: %v", err)
+	}
+	conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			break
+		}
+	}
+	if len(manager.ListGroups()) != 0 {
+		t.Error("expected no groups after delete")
+	}
+	if _, err := manager.GetGroup(group.ID); err != ErrGroupNotFound {
+		t.Errorf("expected ErrGroupNotFound, got %v", err)
+	}
+}
@@ -1,19 +1,46 @@
 package terminal
 
 import (
+	"slices"
 	"time"
 
 	"github.com/xxnuo/vibego/internal/model"
 )
 
+// appendHistory records PTY output in the replay buffer and queues it for the
+// next database flush. Callers must hold historyMu.
+func (m *Manager) appendHistory(at *activeTerminal, data []byte) {
+	at.historyBuffer.Write(data)
+
+	at.historyPending = append(at.historyPending, data...)
+	// Output that could not be flushed in time is dropped oldest-first so an
+	// unreachable database cannot grow memory without bound.
+	if over := len(at.historyPending) - m.historyBufferSize; over > 0 {
+		at.historyPending = append(at.historyPending[:0], at.historyPending[over:]...)
+		at.historyPendingOffset += int64(over)
+	}
+}
+
+// flushHistoryToDB appends the output produced since the previous flush as a
+// new chunk; on failure it stays pending for the next flush.
 func (m *Manager) flushHistoryToDB(at *activeTerminal) error {
-	data := at.historyBuffer.Read()
+	at.flushMu.Lock()
+	defer at.flushMu.Unlock()
+
+	// appendHistory may shift the pending bytes while the chunk is stored.
+	at.historyMu.Lock()
+	data := slices.Clone(at.historyPending)
+	offset := at.historyPendingOffset
+	at.historyMu.Unlock()
+
 	if len(data) == 0 {
 		return nil
 	}
 
 	history := &model.TerminalHistory{
 		SessionID: at.ID,
+		Sequence:  at.historySeq,
+		Offset:    offset,
 		Data:      data,
 		CreatedAt: time.Now().Unix(),
 	}
@@ -21,63 +48,127 @@ func (m *Manager) flushHistoryToDB(at *activeTerminal) error {
 	if err := m.db.Create(history).Error; err != nil {
 		return err
 	}
-
-	m.db.Model(&model.TerminalSession{}).Where("id = ?", at.ID).Update("history_size", int64(len(data)))
-
-	if m.historyMaxRecords > 0 {
-		m.pruneOldHistoryRecords(at.ID)
+	at.historyMu.Lock()
+	if flushed := offset + int64(len(data)) - at.historyPendingOffset; flushed > 0 {
+		flushed = min(flushed, int64(len(at.historyPending)))
+		at.historyPending = at.historyPending[flushed:]
+		at.historyPendingOffset += flushed
+	}
+	at.historyMu.Unlock()
+	at.historySeq++
+	at.historyStored += int64(len(data))
+
+	if at.historyStored > m.historyMaxBytes {
+		stored, err := m.pruneHistoryChunks(at.ID)
+		if err != nil {
+			return err
+		}
+		at.historyStored = stored
 	}
 
+	m.db.Model(&model.TerminalSession{}).Where("id = ?", at.ID).Update("history_size", at.historyStored)
+
 	return nil
 }
 
-func (m *Manager) pruneOldHistoryRecords(sessionID string) error {
-	var count int64
-	m.db.Model(&model.TerminalHistory{}).Where("session_id = ?", sessionID).Count(&count)
+type historyChunkSize struct {
+	ID   int64
+	Size int64
+}
 
-	if count <= int64(m.historyMaxRecords) {
-		return nil
+// pruneHistoryChunks deletes the oldest chunks until the session fits
+// historyMaxBytes and returns the bytes left.
+func (m *Manager) pruneHistoryChunks(sessionID string) (int64, error) {
+	var chunks []historyChunkSize
+	if err := m.db.Model(&model.TerminalHistory{}).
+		Select("id, length(data) AS size").
+		Where("session_id = ?", sessionID).
+		Order("sequence ASC, id ASC").
+		Scan(&chunks).Error; err != nil {
+		return 0, err
 	}
 
-	toDelete := count - int64(m.historyMaxRecords)
-	return m.db.Where("session_id = ? AND id IN (SELECT id FROM terminal_history WHERE session_id = ? ORDER BY created_at ASC LIMIT ?)",
-		sessionID, sessionID, toDelete).
-		Delete(&model.TerminalHistory{}).Error
+	var total int64
+	for _, c := range chunks {
+		total += c.Size
+	}
+
+	var ids []int64
+	for i := 0; i < len(chunks)-1 && total > m.historyMaxBytes; i++ {
+		ids = append(ids, chunks[i].ID)
+		total -= chunks[i].Size
+	}
+	if len(ids) == 0 {
+		return total, nil
+	}
+
+	if err := m.db.Where("id IN ?", ids).Delete(&model.TerminalHistory{}).Error; err != nil {
+		return 0, err
+	}
+	return total, nil
 }
 
+// CleanupExpiredHistory deletes history older than historyMaxAge. The output
+// of sessions that are still open is kept whatever its age.
 func (m *Manager) CleanupExpiredHistory() error {
 	if m.historyMaxAge <= 0 {
 		return nil
 	}
 
 	cutoff := time.Now().Add(-m.historyMaxAge).Unix()
-	return m.db.Where("created_at < ?", cutoff).Delete(&model.TerminalHistory{}).Error
+	open := m.db.Model(&model.TerminalSession{}).Select("id").Where("status <> ?", model.StatusClosed)
+	return m.db.Where("created_at < ? AND session_id NOT IN (?)", cutoff, open).Delete(&model.TerminalHistory{}).Error
 }
 
 func (m *Manager) flushHistory(at *activeTerminal) {
 	for {
 		select {
 		case <-at.flushTicker.C:
-			at.historyMu.Lock()
 			m.flushHistoryToDB(at)
-			at.historyMu.Unlock()
 		case <-at.Done:
 			return
 		}
 	}
 }
 
-func (m *Manager) loadHistoryFromDB(sessionID string) ([]byte, error) {
+// loadHistoryFromDB returns the stored output after the last gap, capped at
+// historyMaxBytes, with the stream offset of its first byte.
+func (m *Manager) loadHistoryFromDB(sessionID string) ([]byte, int64, error) {
 	var histories []model.TerminalHistory
 	if err := m.db.Where("session_id = ?", sessionID).
-		Order("created_at ASC").
+		Order("sequence ASC, id ASC").
 		Find(&histories).Error; err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
-	if len(histories) == 0 {
-		return nil, nil
+	// Sessions stored before history was chunked hold a full snapshot per
+	// flush, all with sequence 0. The newest snapshot replaces the others.
+	var chunks []model.TerminalHistory
+	for _, h := range histories {
+		if n := len(chunks); n > 0 && chunks[n-1].Sequence == h.Sequence {
+			chunks[n-1] = h
+			continue
+		}
+		chunks = append(chunks, h)
+	}
+	if len(chunks) == 0 {
+		return nil, 0, nil
 	}
 
-	return histories[len(histories)-1].Data, nil
+	var data []byte
+	start := chunks[0].Offset
+	for _, h := range chunks {
+		end := start + int64(len(data))
+		if h.Offset > end {
+			data, start, end = data[:0], h.Offset, h.Offset
+		}
+		if skip := end - h.Offset; skip < int64(len(h.Data)) {
+			data = append(data, h.Data[skip:]...)
+		}
+	}
+	if over := int64(len(data)) - m.historyMaxBytes; over > 0 {
+		data = data[over:]
+		start += over
+	}
+	return data, start, nil
 }
@@ -9,7 +9,10 @@ type historyBuffer struct {
 	capacity int
 	start    int
 	length   int
-	mu       sync.RWMutex
+	// total counts every byte ever written and serves as the stream offset
+	// of the next byte.
+	total int64
+	mu    sync.RWMutex
 }
 
 func newHistoryBuffer(capacity int) *historyBuffer {
@@ -30,6 +33,7 @@ func (hb *historyBuffer) Write(data []byte) (int, error) {
 	defer hb.mu.Unlock()
 
 	n := len(data)
+	hb.total += int64(n)
 	if n >= hb.capacity {
 		copy(hb.buf, data[n-hb.capacity:])
 		hb.start = 0
@@ -53,7 +57,10 @@ func (hb *historyBuffer) Write(data []byte) (int, error) {
 func (hb *historyBuffer) Read() []byte {
 	hb.mu.RLock()
 	defer hb.mu.RUnlock()
+	return hb.read()
+}
 
+func (hb *historyBuffer) read() []byte {
 	if hb.length == 0 {
 		return nil
 	}
@@ -70,6 +77,26 @@ func (hb *historyBuffer) Read() []byte {
 	return result
 }
 
+// ReadSince returns the bytes from stream offset since and the offset of the
+// first one; gap reports that some were already overwritten.
+func (hb *historyBuffer) ReadSince(since int64) (data []byte, start int64, gap bool) {
+	hb.mu.RLock()
+	defer hb.mu.RUnlock()
+
+	oldest := hb.total - int64(hb.length)
+	if since < oldest || since > hb.total {
+		return hb.read(), oldest, true
+	}
+	return hb.read()[since-oldest:], since, false
+}
+
+// Offset returns the stream offset of the next byte to be written.
+func (hb *historyBuffer) Offset() int64 {
+	hb.mu.RLock()
+	defer hb.mu.RUnlock()
+	return hb.total
+}
+
 func (hb *historyBuffer) Reset() {
 	hb.mu.Lock()
 	defer hb.mu.Unlock()
@@ -152,3 +152,33 @@ func TestHistoryBufferWrapMultipleTimes(t *testing.T) {
 		t.Errorf("expected %q, got %q", expected, read)
 	}
 }
+
+func TestHistoryBufferReadSince(t *testing.T) {
+	hb := newHistoryBuffer(8)
+	hb.Write([]byte("abcdef"))
+
+	data, start, gap := hb.ReadSince(2)
+	if gap || start != 2 || string(data) != "cdef" {
+		t.Errorf("expected 'cdef' at 2 without gap, got %q at %d (gap=%v)", data, start, gap)
+	}
+
+	data, _, gap = hb.ReadSince(6)
+	if gap || len(data) != 0 {
+		t.Errorf("expected no data when caught up, got %q (gap=%v)", data, gap)
+	}
+
+	hb.Write([]byte("ghijk"))
+	if hb.Offset() != 11 {
+		t.Errorf("expected offset 11, got %d", hb.Offset())
+	}
+
+	data, start, gap = hb.ReadSince(1)
+	if !gap || start != 3 || string(data) != "defghijk" {
+		t.Errorf("expected gap with 'defghijk' at 3, got %q at %d (gap=%v)", data, start, gap)
+	}
+
+	_, _, gap = hb.ReadSince(20)
+	if !gap {
+		t.Error("expected gap for offset beyond the stream")
+	}
+}
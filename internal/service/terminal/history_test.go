@@ -0,0 +1,183 @@
+package terminal
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/xxnuo/vibego/internal/model"
+	"gorm.io/gorm"
+)
+
+// newTestHistoryTerminal returns a terminal with a unique ID, so its history
+// rows never meet those of an earlier run on the shared test database.
+func newTestHistoryTerminal(prefix string) *activeTerminal {
+	return &activeTerminal{
+		ID:            prefix + "-" + uuid.New().String(),
+		historyBuffer: newHistoryBuffer(1024),
+		screen:        newScreen(80, 24, 100),
+	}
+}
+
+func TestManager_FlushHistoryAppendsChunks(t *testing.T) {
+	db := setupTestDB(t)
+	manager := NewManager(db, &ManagerConfig{Shell: "/bin/sh", HistoryBufferSize: 1024})
+	at := newTestHistoryTerminal("history-append")
+
+	manager.appendHistory(at, []byte("hello "))
+	if err := manager.flushHistoryToDB(at); err != nil {
+		t.Fatalf("flush failed: %v", err)
+	}
+	if err := manager.flushHistoryToDB(at); err != nil {
+		t.Fatalf("empty flush failed: %v", err)
+	}
+	manager.appendHistory(at, []byte("world"))
+	if err := manager.flushHistoryToDB(at); err != nil {
+		t.Fatalf("flush failed: %v", err)
+	}
+
+	var chunks []model.TerminalHistory
+	db.Where("session_id = ?", at.ID).Order("sequence ASC").Find(&chunks)
+	if len(chunks) != 2 {
+		t.Fatalf("expected 2 chunks, got %d", len(chunks))
+	}
+	if chunks[0].Sequence != 0 || chunks[1].Sequence != 1 {
+		t.Errorf("expected sequences 0 and 1, got %d and %d", chunks[0].Sequence, chunks[1].Sequence)
+	}
+	if string(chunks[1].Data) != "world" {
+		t.Errorf("expected second chunk to hold only new bytes, got %q", chunks[1].Data)
+	}
+
+	data, start, err := manager.loadHistoryFromDB(at.ID)
+	if err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+	if string(data) != "hello world" {
+		t.Errorf("expected reassembled history 'hello world', got %q", data)
+	}
+	if start != 0 {
+		t.Errorf("expected history to start at offset 0, got %d", start)
+	}
+}
+
+func TestManager_FlushHistoryRetention(t *testing.T) {
+	db := setupTestDB(t)
+	manager := NewManager(db, &ManagerConfig{Shell: "/bin/sh", HistoryBufferSize: 1024, HistoryMaxBytes: 10})
+	at := newTestHistoryTerminal("history-retention")
+
+	for _, chunk := range []string{"aaaa", "bbbb", "cccc", "dddd"} {
+		manager.appendHistory(at, []byte(chunk))
+		if err := manager.flushHistoryToDB(at); err != nil {
+			t.Fatalf("flush failed: %v", err)
+		}
+	}
+
+	var count int64
+	db.Model(&model.TerminalHistory{}).Where("session_id = ?", at.ID).Count(&count)
+	if count != 2 {
+		t.Errorf("expected 2 chunks after pruning, got %d", count)
+	}
+	if at.historyStored != 8 {
+		t.Errorf("expected 8 stored bytes, got %d", at.historyStored)
+	}
+
+	data, start, _ := manager.loadHistoryFromDB(at.ID)
+	if !bytes.Equal(data, []byte("ccccdddd")) {
+		t.Errorf("expected 'ccccdddd', got %q", data)
+	}
+	if start != 8 {
+		t.Errorf("expected history to start at offset 8, got %d", start)
+	}
+}
+
+func TestManager_AppendHistoryBoundsPending(t *testing.T) {
+	db := setupTestDB(t)
+	manager := NewManager(db, &ManagerConfig{Shell: "/bin/sh", HistoryBufferSize: 8})
+	at := newTestHistoryTerminal("history-pending")
+
+	manager.appendHistory(at, []byte("0123456789"))
+	if string(at.historyPending) != "23456789" {
+		t.Errorf("expected pending to keep the newest 8 bytes, got %q", at.historyPending)
+	}
+}
+
+func TestManager_FlushHistoryKeepsPendingOnError(t *testing.T) {
+	db := setupTestDB(t)
+	manager := NewManager(db, &ManagerConfig{Shell: "/bin/sh", HistoryBufferSize: 1024})
+	at := newTestHistoryTerminal("history-retry")
+
+	failing := true
+	db.Callback().Create().Before("gorm:create").Register("test:fail_history", func(tx *gorm.DB) {
+		if failing {
+			tx.AddError(errors.New("database unavailable"))
+		}
+	})
+
+	manager.appendHistory(at, []byte("hello "))
+	if err := manager.flushHistoryToDB(at); err == nil {
+		t.Fatal("expected the flush to fail")
+	}
+	if string(at.historyPending) != "hello " || at.historyPendingOffset != 0 {
+		t.Errorf("expected a failed flush to keep the output pending, got %q at %d", at.historyPending, at.historyPendingOffset)
+	}
+
+	failing = false
+	manager.appendHistory(at, []byte("world"))
+	if err := manager.flushHistoryToDB(at); err != nil {
+		t.Fatalf("flush failed: %v", err)
+	}
+	if len(at.historyPending) != 0 || at.historyPendingOffset != 11 {
+		t.Errorf("expected nothing pending after the flush, got %q at %d", at.historyPending, at.historyPendingOffset)
+	}
+	if data, start, _ := manager.loadHistoryFromDB(at.ID); string(data) != "hello world" || start != 0 {
+		t.Errorf("expected 'hello world' at 0, got %q at %d", data, start)
+	}
+}
+
+func TestManager_LoadHistoryFromOffsets(t *testing.T) {
+	db := setupTestDB(t)
+	manager := NewManager(db, &ManagerConfig{Shell: "/bin/sh"})
+
+	for _, tc := range []struct {
+		id     string
+		chunks []model.TerminalHistory
+		want   string
+		start  int64
+	}{
+		{
+			id: "history-gap",
+			chunks: []model.TerminalHistory{
+				{Sequence: 0, Offset: 0, Data: []byte("lost ")},
+				{Sequence: 1, Offset: 9, Data: []byte("kept ")},
+				{Sequence: 2, Offset: 14, Data: []byte("tail")},
+			},
+			want:  "kept tail",
+			start: 9,
+		},
+		{
+			id: "history-legacy",
+			chunks: []model.TerminalHistory{
+				{Sequence: 0, Data: []byte("old")},
+				{Sequence: 0, Data: []byte("old snapshot")},
+				{Sequence: 1, Offset: 12, Data: []byte(" more")},
+			},
+			want:  "old snapshot more",
+			start: 0,
+		},
+	} {
+		for _, chunk := range tc.chunks {
+			chunk.SessionID = tc.id
+			db.Create(&chunk)
+		}
+		defer db.Where("session_id = ?", tc.id).Delete(&model.TerminalHistory{})
+
+		data, start, err := manager.loadHistoryFromDB(tc.id)
+		if err != nil {
+			t.Fatalf("load failed: %v", err)
+		}
+		if string(data) != tc.want || start != tc.start {
+			t.Errorf("%s: expected %q at %d, got %q at %d", tc.id, tc.want, tc.start, data, start)
+		}
+	}
+}
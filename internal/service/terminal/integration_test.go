@@ -1,9 +1,12 @@
 package terminal
 
 import (
+	"encoding/base64"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -20,7 +23,7 @@ func setupTestDB(t *testing.T) *gorm.DB {
 		t.Fatalf("failed to open database: %v", err)
 	}
 
-	if err := db.AutoMigrate(&model.TerminalSession{}, &model.TerminalHistory{}); err != nil {
+	if err := db.AutoMigrate(&model.TerminalSession{}, &model.TerminalHistory{}, &model.TerminalProfile{}, &model.TerminalRecording{}, &model.KV{}, &model.PushSubscription{}, &model.SSHHost{}, &model.TerminalTrigger{}); err != nil {
 		t.Fatalf("failed to migrate: %v", err)
 	}
 
@@ -77,7 +80,7 @@ func TestManager_MultiClient_Sharing(t *testing.T) {
 			return
 		}
 
-		if _, err := manager.Attach(info.ID, conn); err != nil {
+		if _, err := manager.Attach(info.ID, conn, AttachOptions{}); err != nil {
 			t.Errorf("failed to attach: %v", err)
 			conn.Close()
 			return
@@ -127,7 +130,7 @@ func TestManager_MaxConnections(t *testing.T) {
 			return
 		}
 
-		if _, err := manager.Attach(info.ID, conn); err != nil {
+		if _, err := manager.Attach(info.ID, conn, AttachOptions{}); err != nil {
 			conn.Close()
 			return
 		}
@@ -177,7 +180,7 @@ func TestManager_WebTTY_Integration(t *testing.T) {
 			return
 		}
 
-		if _, err := manager.Attach(info.ID, conn); err != nil {
+		if _, err := manager.Attach(info.ID, conn, AttachOptions{}); err != nil {
 			conn.Close()
 			return
 		}
@@ -257,9 +260,10 @@ func TestManager_List(t *testing.T) {
 
 func TestManager_Delete(t *testing.T) {
 	db := setupTestDB(t)
-	manager := NewManager(db, &ManagerConfig{Shell: "/bin/sh"})
+	manager := NewManager(db, &ManagerConfig{Shell: "/bin/sh", RecordDir: t.TempDir()})
 
-	info, _ := manager.Create(CreateOptions{Name: "test", Cwd: os.TempDir(), Cols: 80, Rows: 24})
+	info, _ := manager.Create(CreateOptions{Name: "test", Cwd: os.TempDir(), Cols: 80, Rows: 24, Record: true})
+	recordings, _ := manager.ListRecordings(info.ID, "")
 
 	err := manager.Delete(info.ID)
 	if err != nil {
@@ -270,6 +274,15 @@ func TestManager_Delete(t *testing.T) {
 	if ok {
 		t.Error("expected terminal to be deleted")
 	}
+	if len(recordings) != 1 {
+		t.Fatalf("expected one recording, got %d", len(recordings))
+	}
+	if _, err := os.Stat(recordings[0].Path); !os.IsNotExist(err) {
+		t.Error("expected the recording file to be deleted")
+	}
+	if left, _ := manager.ListRecordings(info.ID, ""); len(left) != 0 {
+		t.Errorf("expected the recording to be deleted, got %+v", left)
+	}
 }
 
 func TestManager_CleanupOnStart(t *testing.T) {
@@ -329,7 +342,7 @@ func TestManager_AttachNonExistent(t *testing.T) {
 		}
 		defer conn.Close()
 
-		_, err = manager.Attach("nonexistent", conn)
+		_, err = manager.Attach("nonexistent", conn, AttachOptions{})
 		if err != nil && err != ErrTerminalNotFound {
 			t.Logf("attach returned: %v", err)
 		}
@@ -343,3 +356,299 @@ func TestManager_AttachNonExistent(t *testing.T) {
 	}
 	time.Sleep(100 * time.Millisecond)
 }
+
+func TestManager_AttachReadOnly(t *testing.T) {
+	db := setupTestDB(t)
+	manager := NewManager(db, &ManagerConfig{Shell: "/bin/sh"})
+
+	info, err := manager.Create(CreateOptions{Name: "test", Cwd: os.TempDir(), Cols: 80, Rows: 24})
+	if err != nil {
+		t.Fatalf("failed to create terminal: %v", err)
+	}
+	defer manager.Close(info.ID)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upgrader := websocket.Upgrader{}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+
+		opts := AttachOptions{ReadOnly: r.URL.Query().Get("mode") == "view"}
+		if _, err := manager.Attach(info.ID, conn, opts); err != nil {
+			conn.Close()
+			return
+		}
+
+		time.Sleep(500 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	writer, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatalf("writer failed to dial: %v", err)
+	}
+	defer writer.Close()
+
+	viewer, _, err := websocket.DefaultDialer.Dial(wsURL+"?mode=view", nil)
+	if err != nil {
+		t.Fatalf("viewer failed to dial: %v", err)
+	}
+	defer viewer.Close()
+
+	time.Sleep(100 * time.Millisecond)
+
+	got, ok := manager.Get(info.ID)
+	if !ok {
+		t.Fatal("failed to get terminal info")
+	}
+	if got.Writers != 1 || got.Viewers != 1 {
+		t.Errorf("expected 1 writer and 1 viewer, got %d writers and %d viewers", got.Writers, got.Viewers)
+	}
+}
+
+func TestManager_ExitEvent(t *testing.T) {
+	db := setupTestDB(t)
+	manager := NewManager(db, &ManagerConfig{Shell: "/bin/sh"})
+
+	info, err := manager.Create(CreateOptions{Name: "test", Cwd: os.TempDir(), Cols: 80, Rows: 24})
+	if err != nil {
+		t.Fatalf("failed to create terminal: %v", err)
+	}
+	defer manager.Close(info.ID)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upgrader := websocket.Upgrader{}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+
+		termConn, err := manager.Attach(info.ID, conn, AttachOptions{})
+		if err != nil {
+			conn.Close()
+			return
+		}
+		<-termConn.Done
+	}))
+	defer server.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http")
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	defer conn.Close()
+
+	time.Sleep(100 * time.Millisecond)
+
+	at, _ := manager.getActive(info.ID)
+	at.PTY.Write([]byte("exit 7\n"))
+
+	var exitMsg ExitMessage
+	conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("did not receive exit message: %v", err)
+		}
+		if err := json.Unmarshal(data, &exitMsg); err == nil && exitMsg.Type == MsgTypeExit {
+			break
+		}
+	}
+
+	if exitMsg.ExitCode != 7 {
+		t.Errorf("expected exit code 7, got %d", exitMsg.ExitCode)
+	}
+
+	got, _ := manager.Get(info.ID)
+	if got.PTYStatus != model.PTYStatusExited || got.ExitCode != 7 {
+		t.Errorf("expected exited with code 7, got %s with code %d", got.PTYStatus, got.ExitCode)
+	}
+
+	var session model.TerminalSession
+	db.First(&session, "id = ?", info.ID)
+	if session.ExitCode != 7 {
+		t.Errorf("expected persisted exit code 7, got %d", session.ExitCode)
+	}
+}
+
+func TestManager_CreateWithCommand(t *testing.T) {
+	db := setupTestDB(t)
+	manager := NewManager(db, &ManagerConfig{Shell: "/bin/sh"})
+
+	info, err := manager.Create(CreateOptions{
+		Name:    "cmd",
+		Cwd:     os.TempDir(),
+		Command: "/bin/sh",
+		Args:    []string{"-c", "echo $GREETING; sleep 5"},
+		Env:     map[string]string{"GREETING": "from-env"},
+	})
+	if err != nil {
+		t.Fatalf("failed to create terminal: %v", err)
+	}
+	defer manager.Close(info.ID)
+
+	if info.Shell != "/bin/sh" || len(info.Args) != 2 {
+		t.Errorf("expected command and args in info, got %s %v", info.Shell, info.Args)
+	}
+
+	var session model.TerminalSession
+	db.First(&session, "id = ?", info.ID)
+	if len(session.Args) != 2 || session.Args[0] != "-c" {
+		t.Errorf("expected persisted args, got %v", session.Args)
+	}
+
+	at, _ := manager.getActive(info.ID)
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		at.historyMu.RLock()
+		out := string(at.historyBuffer.Read())
+		at.historyMu.RUnlock()
+		if strings.Contains(out, "from-env") {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Error("expected command output to contain env value")
+}
+
+func TestManager_AttachResume(t *testing.T) {
+	db := setupTestDB(t)
+	manager := NewManager(db, &ManagerConfig{Shell: "/bin/sh", HistoryBufferSize: 16})
+
+	info, err := manager.Create(CreateOptions{Name: "test", Cwd: os.TempDir(), Command: "/bin/sh", Args: []string{"-c", "sleep 5"}})
+	if err != nil {
+		t.Fatalf("failed to create terminal: %v", err)
+	}
+	defer manager.Close(info.ID)
+
+	at, _ := manager.getActive(info.ID)
+	at.historyMu.Lock()
+	manager.appendHistory(at, []byte("0123456789"))
+	at.historyMu.Unlock()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upgrader := websocket.Upgrader{}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		var opts AttachOptions
+		if since := r.URL.Query().Get("since"); since != "" {
+			opts.Resume = true
+			opts.Since, _ = strconv.ParseInt(since, 10, 64)
+		}
+		termConn, err := manager.Attach(info.ID, conn, opts)
+		if err != nil {
+			conn.Close()
+			return
+		}
+		<-termConn.Done
+	}))
+	defer server.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL+"?since=6", nil)
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, data, err := conn.ReadMessage()
+	conn.Close()
+	if err != nil {
+		t.Fatalf("failed to read replay: %v", err)
+	}
+	var msg WSMessage
+	json.Unmarshal(data, &msg)
+	decoded, _ := base64.StdEncoding.DecodeString(msg.Data)
+	if msg.Type != MsgTypeCmd || string(decoded) != "6789" || msg.Offset != 10 {
+		t.Errorf("expected '6789' up to offset 10, got %q up to %d", decoded, msg.Offset)
+	}
+
+	at.historyMu.Lock()
+	manager.appendHistory(at, []byte("abcdefghijklmnop"))
+	at.historyMu.Unlock()
+
+	conn, _, err = websocket.DefaultDialer.Dial(wsURL+"?since=6", nil)
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, data, err = conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("failed to read gap notice: %v", err)
+	}
+	var gap GapMessage
+	json.Unmarshal(data, &gap)
+	if gap.Type != MsgTypeGap || gap.Since != 6 || gap.Offset != 26 {
+		t.Errorf("expected gap from 6 to 26, got %+v", gap)
+	}
+	_, data, err = conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("failed to read snapshot: %v", err)
+	}
+	json.Unmarshal(data, &msg)
+	decoded, _ = base64.StdEncoding.DecodeString(msg.Data)
+	if msg.Type != MsgTypeCmd || !strings.HasPrefix(string(decoded), "\x1bc") || msg.Offset != 26 {
+		t.Errorf("expected a screen snapshot up to offset 26, got %q up to %d", decoded, msg.Offset)
+	}
+}
+
+func TestManager_BinaryProtocol(t *testing.T) {
+	db := setupTestDB(t)
+	manager := NewManager(db, &ManagerConfig{Shell: "/bin/sh"})
+
+	info, err := manager.Create(CreateOptions{Name: "test", Cwd: os.TempDir(), Cols: 80, Rows: 24})
+	if err != nil {
+		t.Fatalf("failed to create terminal: %v", err)
+	}
+	defer manager.Close(info.ID)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upgrader := websocket.Upgrader{Subprotocols: []string{SubprotocolBinary, SubprotocolJSON}}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		termConn, err := manager.Attach(info.ID, conn, AttachOptions{})
+		if err != nil {
+			conn.Close()
+			return
+		}
+		<-termConn.Done
+	}))
+	defer server.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http")
+	dialer := websocket.Dialer{Subprotocols: []string{SubprotocolBinary}}
+	conn, _, err := dialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	defer conn.Close()
+
+	if conn.Subprotocol() != SubprotocolBinary {
+		t.Fatalf("expected subprotocol %s, got %q", SubprotocolBinary, conn.Subprotocol())
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	conn.WriteMessage(websocket.BinaryMessage, append([]byte{BinaryFrameInput}, []byte("echo binary-ok\n")...))
+
+	var output []byte
+	conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	for !strings.Contains(string(output), "binary-ok\r\n") {
+		msgType, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("did not receive echoed output: %v (got %q)", err, output)
+		}
+		if msgType != websocket.BinaryMessage || len(data) < 9 || data[0] != BinaryFrameOutput {
+			t.Fatalf("expected binary output frame, got type %d %q", msgType, data)
+		}
+		output = append(output, data[9:]...)
+	}
+}
@@ -0,0 +1,117 @@
+package terminal
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/xxnuo/vibego/internal/model"
+)
+
+// cgroupCPUPeriod is the cpu.max period in microseconds.
+const cgroupCPUPeriod = 100000
+
+// limited reports whether limits restrict anything, in which case the
+// terminal is started through the sandbox launcher.
+func limited(limits *model.TerminalLimits) bool {
+	return limits != nil && *limits != model.TerminalLimits{}
+}
+
+func usesCgroup(limits *model.TerminalLimits) bool {
+	return limits != nil && (limits.Memory > 0 || limits.CPU > 0)
+}
+
+func validateLimits(limits *model.TerminalLimits) error {
+	if limits == nil {
+		return nil
+	}
+	if limits.CPUTime < 0 || limits.AddressSpace < 0 || limits.OpenFiles < 0 ||
+		limits.Processes < 0 || limits.Memory < 0 || limits.CPU < 0 {
+		return ErrInvalidLimits
+	}
+	return nil
+}
+
+// createCgroup makes a child of the manager's cgroup root capped by the
+// memory and CPU limits, and returns its path.
+func (m *Manager) createCgroup(id string, limits *model.TerminalLimits) (string, error) {
+	if m.cgroupRoot == "" {
+		return "", ErrCgroupUnavailable
+	}
+	// Children can only use controllers enabled on their parent. Enabling
+	// one twice is harmless, and a missing one makes its limit fail below.
+	for _, controller := range []string{"+memory", "+cpu"} {
+		os.WriteFile(filepath.Join(m.cgroupRoot, "cgroup.subtree_control"), []byte(controller), 0644)
+	}
+
+	dir := filepath.Join(m.cgroupRoot, "vibego-"+id)
+	if err := os.Mkdir(dir, 0755); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrCgroupUnavailable, err)
+	}
+	var err error
+	if limits.Memory > 0 {
+		err = os.WriteFile(filepath.Join(dir, "memory.max"), []byte(strconv.FormatInt(limits.Memory, 10)), 0644)
+		// Without swap accounting the file is missing, otherwise swap
+		// would let the tree exceed its limit unnoticed.
+		os.WriteFile(filepath.Join(dir, "memory.swap.max"), []byte("0"), 0644)
+	}
+	if err == nil && limits.CPU > 0 {
+		quota := int64(limits.CPU * cgroupCPUPeriod)
+		err = os.WriteFile(filepath.Join(dir, "cpu.max"), []byte(fmt.Sprintf("%d %d", quota, cgroupCPUPeriod)), 0644)
+	}
+	if err != nil {
+		os.RemoveAll(dir)
+		return "", fmt.Errorf("%w: %v", ErrCgroupUnavailable, err)
+	}
+	return dir, nil
+}
+
+// removeCgroup kills what is left in a terminal's cgroup and removes it.
+func removeCgroup(dir string) {
+	if dir == "" {
+		return
+	}
+	os.WriteFile(filepath.Join(dir, "cgroup.kill"), []byte("1"), 0644)
+	var err error
+	for i := 0; i < 20; i++ {
+		if err = os.RemoveAll(dir); err == nil {
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	log.Warn().Err(err).Str("cgroup", dir).Msg("Failed to remove terminal cgroup")
+}
+
+// cgroupOOMKills returns how many processes of a cgroup were killed for
+// exceeding its memory limit.
+func cgroupOOMKills(dir string) int64 {
+	data, err := os.ReadFile(filepath.Join(dir, "memory.events"))
+	if err != nil {
+		return 0
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		if value, ok := strings.CutPrefix(scanner.Text(), "oom_kill "); ok {
+			n, _ := strconv.ParseInt(value, 10, 64)
+			return n
+		}
+	}
+	return 0
+}
+
+// exitReason reports the limit, if any, that ended a terminal's process.
+func exitReason(session *model.TerminalSession, signal string) string {
+	switch {
+	case signal == "SIGKILL" && session.Cgroup != "" && cgroupOOMKills(session.Cgroup) > 0:
+		return model.ExitReasonMemoryLimit
+	case signal == "SIGXCPU" && session.Limits != nil && session.Limits.CPUTime > 0:
+		return model.ExitReasonCPULimit
+	}
+	return ""
+}
@@ -0,0 +1,217 @@
+package terminal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xxnuo/vibego/internal/model"
+)
+
+// waitExitedWithin is waitExited for processes that take a while to hit
+// their limit.
+func waitExitedWithin(t *testing.T, manager *Manager, id string, timeout time.Duration) *TerminalInfo {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for {
+		info, ok := manager.Get(id)
+		if ok && info.PTYStatus == model.PTYStatusExited {
+			return info
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("terminal did not exit")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestManager_Rlimits(t *testing.T) {
+	for _, persistent := range []bool{false, true} {
+		t.Run(fmt.Sprintf("persistent=%v", persistent), func(t *testing.T) {
+			// Unix socket paths are short, so the subtest's TempDir won't do.
+			supervisorDir, err := os.MkdirTemp("", "vg")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(supervisorDir)
+
+			db := setupTestDB(t)
+			manager := NewManager(db, &ManagerConfig{Shell: "/bin/sh", Persistent: persistent, SupervisorDir: supervisorDir})
+
+			profile, err := manager.CreateProfile(ProfileOptions{
+				Name:    "limited",
+				Program: "/bin/sh",
+				Args:    []string{"-c", "echo files=$(ulimit -n) as=$(ulimit -v) cpu=$(ulimit -t); sleep 30"},
+				Limits:  &model.TerminalLimits{OpenFiles: 64, AddressSpace: 1 << 30, CPUTime: 5},
+			})
+			if err != nil {
+				t.Fatalf("CreateProfile failed: %v", err)
+			}
+			info, err := manager.Create(CreateOptions{ProfileID: profile.ID})
+			if err != nil {
+				t.Fatalf("failed to create terminal: %v", err)
+			}
+			defer manager.Close(info.ID)
+			if info.Limits == nil || info.Limits.OpenFiles != 64 {
+				t.Errorf("expected the profile's limits, got %+v", info.Limits)
+			}
+			waitScreen(t, manager, info.ID, "files=64 as=1048576 cpu=5")
+
+			info, err = manager.Create(CreateOptions{
+				Command: "/bin/sh",
+				Args:    []string{"-c", "while :; do :; done"},
+				Limits:  &model.TerminalLimits{CPUTime: 1},
+			})
+			if err != nil {
+				t.Fatalf("failed to create terminal: %v", err)
+			}
+			defer manager.Close(info.ID)
+			exited := waitExitedWithin(t, manager, info.ID, 10*time.Second)
+			if exited.ExitSignal != "SIGXCPU" || exited.ExitReason != model.ExitReasonCPULimit {
+				t.Errorf("expected a cpu limit exit, got %q (%q)", exited.ExitSignal, exited.ExitReason)
+			}
+			sessions, _ := manager.List()
+			for _, s := range sessions {
+				if s.ID == info.ID && s.ExitReason != model.ExitReasonCPULimit {
+					t.Errorf("expected the exit reason to be stored, got %q", s.ExitReason)
+				}
+			}
+		})
+	}
+
+	manager := NewManager(setupTestDB(t), &ManagerConfig{Shell: "/bin/sh"})
+	if _, err := manager.Create(CreateOptions{Limits: &model.TerminalLimits{OpenFiles: -1}}); err != ErrInvalidLimits {
+		t.Errorf("expected ErrInvalidLimits, got %v", err)
+	}
+	if _, err := manager.Create(CreateOptions{Limits: &model.TerminalLimits{Memory: 1 << 20}}); err != ErrCgroupUnavailable {
+		t.Errorf("expected ErrCgroupUnavailable without a cgroup root, got %v", err)
+	}
+}
+
+// TestManager_CgroupLimits checks the files written to the cgroup. Against a
+// plain directory nothing is enforced, so the out of memory kill is faked.
+func TestManager_CgroupLimits(t *testing.T) {
+	root := t.TempDir()
+	db := setupTestDB(t)
+	manager := NewManager(db, &ManagerConfig{Shell: "/bin/sh", CgroupRoot: root})
+
+	info, err := manager.Create(CreateOptions{
+		Command: "/bin/sh",
+		Args:    []string{"-c", "echo STARTED; sleep 30"},
+		Limits:  &model.TerminalLimits{Memory: 64 << 20, CPU: 0.5},
+	})
+	if err != nil {
+		t.Fatalf("failed to create terminal: %v", err)
+	}
+	defer manager.Close(info.ID)
+	waitScreen(t, manager, info.ID, "STARTED")
+
+	dir := filepath.Join(root, "vibego-"+info.ID)
+	for file, want := range map[string]string{
+		"memory.max": "67108864",
+		"cpu.max":    "50000 100000",
+	} {
+		if data, _ := os.ReadFile(filepath.Join(dir, file)); string(data) != want {
+			t.Errorf("expected %s to be %q, got %q", file, want, data)
+		}
+	}
+	at, _ := manager.getActive(info.ID)
+	if data, _ := os.ReadFile(filepath.Join(dir, "cgroup.procs")); strings.TrimSpace(string(data)) != fmt.Sprint(at.PTY.(*localCommand).Pid()) {
+		t.Errorf("expected the process to join the cgroup, got %q", data)
+	}
+
+	os.WriteFile(filepath.Join(dir, "memory.events"), []byte("low 0\nhigh 0\nmax 3\noom 1\noom_kill 1\n"), 0644)
+	if _, err := manager.Signal(info.ID, "SIGKILL"); err != nil {
+		t.Fatalf("Signal failed: %v", err)
+	}
+	if exited := waitExited(t, manager, info.ID); exited.ExitReason != model.ExitReasonMemoryLimit {
+		t.Errorf("expected a memory limit exit, got %q", exited.ExitReason)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Error("expected the cgroup to be removed")
+	}
+}
+
+func TestManager_Sandbox(t *testing.T) {
+	workspace := t.TempDir()
+	outside := t.TempDir()
+	os.WriteFile(filepath.Join(outside, "secret"), []byte("hidden"), 0644)
+
+	db := setupTestDB(t)
+	manager := NewManager(db, &ManagerConfig{Shell: "/bin/sh"})
+
+	script := strings.Join([]string{
+		"echo written > result",
+		"touch /etc/vg-sandbox-probe 2>/dev/null || echo ETC_READONLY",
+		"cat " + filepath.Join(outside, "secret") + " 2>/dev/null || echo OUTSIDE_HIDDEN",
+		fmt.Sprintf("kill -0 %d 2>/dev/null || echo SERVER_HIDDEN", os.Getpid()),
+		"test -e /dev/kmsg || echo DEV_MINIMAL",
+		"grep CapEff /proc/self/status",
+		"echo SANDBOX_DONE",
+		"sleep 30",
+	}, "; ")
+	info, err := manager.Create(CreateOptions{
+		Cwd:     workspace,
+		Command: "/bin/sh",
+		Args:    []string{"-c", script},
+		Limits:  &model.TerminalLimits{Sandbox: true},
+	})
+	if err != nil {
+		t.Fatalf("failed to create terminal: %v", err)
+	}
+	defer manager.Close(info.ID)
+
+	deadline := time.Now().Add(3 * time.Second)
+	var screen string
+	for !strings.Contains(screen, "SANDBOX_DONE") {
+		if strings.Contains(screen, "vibego: sandbox:") {
+			t.Skipf("namespaces not available: %s", screen)
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("sandboxed command did not finish: %q", screen)
+		}
+		time.Sleep(20 * time.Millisecond)
+		dump, _ := manager.Screen(info.ID, false)
+		screen = strings.Join(dump.Lines, "\n")
+	}
+
+	if !strings.Contains(screen, "ETC_READONLY") || !strings.Contains(screen, "OUTSIDE_HIDDEN") {
+		t.Errorf("expected only the workspace to be exposed, got %q", screen)
+	}
+	for _, want := range []string{"SERVER_HIDDEN", "DEV_MINIMAL", "0000000000000000"} {
+		if !strings.Contains(screen, want) {
+			t.Errorf("expected %q in the sandbox, got %q", want, screen)
+		}
+	}
+	if data, _ := os.ReadFile(filepath.Join(workspace, "result")); string(data) != "written\n" {
+		t.Errorf("expected the workspace to be writable, got %q", data)
+	}
+	if _, err := os.Stat("/etc/vg-sandbox-probe"); err == nil {
+		os.Remove("/etc/vg-sandbox-probe")
+		t.Error("expected /etc to be read-only")
+	}
+
+	// The launcher waits for the sandboxed process, which must not outlive
+	// it.
+	at, _ := manager.getActive(info.ID)
+	launcher := at.PTY.(*localCommand).Pid()
+	children, _ := os.ReadFile(fmt.Sprintf("/proc/%d/task/%d/children", launcher, launcher))
+	var child int
+	if _, err := fmt.Sscan(string(children), &child); err != nil {
+		t.Fatalf("expected the launcher to have a child, got %q", children)
+	}
+	manager.Close(info.ID)
+	for deadline := time.Now().Add(3 * time.Second); ; {
+		status, err := os.ReadFile(fmt.Sprintf("/proc/%d/status", child))
+		if err != nil || strings.Contains(string(status), "zombie") {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected the sandboxed process to be killed with the terminal")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
@@ -0,0 +1,107 @@
+package terminal
+
+import (
+	"os"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/xxnuo/vibego/internal/model"
+)
+
+// touch records activity for the idle policy. lastActivity and exitedAt
+// hold Unix nanoseconds.
+func (at *activeTerminal) touch() {
+	at.lastActivity.Store(time.Now().UnixNano())
+}
+
+func (m *Manager) maintenanceLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			m.Maintain()
+		case <-m.stop:
+			return
+		}
+	}
+}
+
+// Maintain applies the reaping and retention policies once.
+func (m *Manager) Maintain() {
+	now := time.Now()
+
+	var exited, idle []string
+	m.terminals.Range(func(key, value any) bool {
+		at := value.(*activeTerminal)
+		if at.ptyStatus.Load() == model.PTYStatusExited {
+			if m.exitedTimeout > 0 && now.Sub(time.Unix(0, at.exitedAt.Load())) >= m.exitedTimeout {
+				exited = append(exited, at.ID)
+			}
+			return true
+		}
+		if m.idleTimeout <= 0 || now.Sub(time.Unix(0, at.lastActivity.Load())) < m.idleTimeout {
+			return true
+		}
+		if writers, viewers := at.clientCounts(); writers+viewers == 0 {
+			idle = append(idle, at.ID)
+		}
+		return true
+	})
+
+	for _, id := range exited {
+		log.Info().Str("session", id).Msg("Closing exited terminal")
+		m.Close(id)
+	}
+	for _, id := range idle {
+		log.Info().Str("session", id).Msg("Killing idle terminal")
+		m.Close(id)
+	}
+
+	if err := m.purgeSessions(now); err != nil {
+		log.Warn().Err(err).Msg("Failed to purge closed terminal sessions")
+	}
+	if err := m.CleanupExpiredHistory(); err != nil {
+		log.Warn().Err(err).Msg("Failed to clean up expired terminal history")
+	}
+}
+
+// purgeSessions deletes closed sessions that have not been updated within
+// sessionRetention, together with their history and recordings.
+func (m *Manager) purgeSessions(now time.Time) error {
+	if m.sessionRetention <= 0 {
+		return nil
+	}
+
+	var ids []string
+	cutoff := now.Add(-m.sessionRetention).Unix()
+	if err := m.db.Model(&model.TerminalSession{}).
+		Where("status = ? AND updated_at < ?", model.StatusClosed, cutoff).
+		Pluck("id", &ids).Error; err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	return m.deleteSessions(ids)
+}
+
+// deleteSessions deletes sessions with their history and recordings.
+func (m *Manager) deleteSessions(ids []string) error {
+	if err := m.db.Where("session_id IN ?", ids).Delete(&model.TerminalHistory{}).Error; err != nil {
+		return err
+	}
+	var recordings []model.TerminalRecording
+	if err := m.db.Where("session_id IN ?", ids).Find(&recordings).Error; err != nil {
+		return err
+	}
+	for _, r := range recordings {
+		if err := os.Remove(r.Path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if err := m.db.Where("id = ?", r.ID).Delete(&model.TerminalRecording{}).Error; err != nil {
+			return err
+		}
+	}
+	return m.db.Where("id IN ?", ids).Delete(&model.TerminalSession{}).Error
+}
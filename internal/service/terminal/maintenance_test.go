@@ -0,0 +1,116 @@
+package terminal
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/xxnuo/vibego/internal/model"
+)
+
+func TestManager_MaintainReapsTerminals(t *testing.T) {
+	db := setupTestDB(t)
+	manager := NewManager(db, &ManagerConfig{
+		Shell:               "/bin/sh",
+		ExitedTimeout:       100 * time.Millisecond,
+		IdleTimeout:         200 * time.Millisecond,
+		MaintenanceInterval: time.Hour,
+	})
+
+	create := func(args ...string) string {
+		info, err := manager.Create(CreateOptions{Cwd: os.TempDir(), Command: "/bin/sh", Args: args})
+		if err != nil {
+			t.Fatalf("failed to create terminal: %v", err)
+		}
+		t.Cleanup(func() { manager.Close(info.ID) })
+		return info.ID
+	}
+	exited := create("-c", "true")
+	idle := create("-c", "sleep 30")
+	watched := create("-c", "sleep 30")
+	busy := create("-c", "while true; do echo tick; sleep 0.05; done")
+
+	at, _ := manager.getActive(watched)
+	_, cancel := context.WithCancel(context.Background())
+	viewer := newClientQueue(&mockMaster{}, 0)
+	go viewer.run()
+	at.WebTTYs.Store("viewer", &webTTYInstance{ID: "viewer", Queue: viewer, ReadOnly: true, Cancel: cancel})
+
+	waitExited(t, manager, exited)
+	manager.Maintain()
+	if _, ok := manager.getActive(exited); !ok {
+		t.Error("exited terminal closed before its timeout")
+	}
+
+	time.Sleep(300 * time.Millisecond)
+	manager.Maintain()
+
+	for id, want := range map[string]bool{exited: false, idle: false, watched: true, busy: true} {
+		if _, ok := manager.getActive(id); ok != want {
+			t.Errorf("terminal %s: expected active=%v", id, want)
+		}
+	}
+	var session model.TerminalSession
+	db.First(&session, "id = ?", idle)
+	if session.Status != model.StatusClosed || session.PTYStatus != model.PTYStatusExited {
+		t.Errorf("expected idle terminal to be closed, got %+v", session)
+	}
+}
+
+func TestManager_MaintainPurgesSessions(t *testing.T) {
+	db := setupTestDB(t)
+	manager := NewManager(db, &ManagerConfig{
+		Shell:               "/bin/sh",
+		SessionRetention:    time.Hour,
+		HistoryMaxAge:       time.Minute,
+		MaintenanceInterval: time.Hour,
+	})
+	dir := t.TempDir()
+
+	old := time.Now().Add(-2 * time.Hour).Unix()
+	sessions := []*model.TerminalSession{
+		{ID: "purge-old", Status: model.StatusClosed, PTYStatus: model.PTYStatusExited},
+		{ID: "purge-recent", Status: model.StatusClosed, PTYStatus: model.PTYStatusExited},
+		{ID: "purge-active", Status: model.StatusActive, PTYStatus: model.PTYStatusRunning},
+	}
+	for _, s := range sessions {
+		db.Save(s)
+		if s.ID != "purge-recent" {
+			// UpdateColumn keeps gorm from stamping the current time.
+			db.Model(s).UpdateColumn("updated_at", old)
+		}
+		// History outlives HistoryMaxAge as long as its session is open.
+		db.Create(&model.TerminalHistory{SessionID: s.ID, Data: []byte("x"), CreatedAt: old})
+		path := filepath.Join(dir, s.ID+".cast")
+		os.WriteFile(path, []byte("{}\n"), 0644)
+		db.Create(&model.TerminalRecording{ID: "recording-" + s.ID, SessionID: s.ID, Path: path})
+	}
+	defer func() {
+		for _, s := range sessions {
+			manager.Delete(s.ID)
+			db.Where("session_id = ?", s.ID).Delete(&model.TerminalRecording{})
+		}
+	}()
+
+	manager.Maintain()
+
+	for id, want := range map[string]int64{"purge-old": 0, "purge-recent": 1, "purge-active": 1} {
+		var count, history, recordings int64
+		db.Model(&model.TerminalSession{}).Where("id = ?", id).Count(&count)
+		db.Model(&model.TerminalHistory{}).Where("session_id = ?", id).Count(&history)
+		db.Model(&model.TerminalRecording{}).Where("session_id = ?", id).Count(&recordings)
+		wantHistory := want
+		if id == "purge-recent" {
+			wantHistory = 0
+		}
+		if count != want || history != wantHistory || recordings != want {
+			t.Errorf("session %s: expected %d rows and %d history chunks, got %d sessions, %d history chunks and %d recordings", id, want, wantHistory, count, history, recordings)
+		}
+		_, err := os.Stat(filepath.Join(dir, id+".cast"))
+		if exists := err == nil; exists != (want == 1) {
+			t.Errorf("session %s: expected the recording file to exist: %v", id, want == 1)
+		}
+	}
+}
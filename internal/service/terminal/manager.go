@@ -2,52 +2,124 @@ package terminal
 
 import (
 	"context"
-	"encoding/base64"
 	"encoding/json"
 	"os"
+	"regexp"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
+	"github.com/rs/zerolog/log"
 	"github.com/xxnuo/vibego/internal/model"
 	"gorm.io/gorm"
 )
 
 type webTTYInstance struct {
-	ID     string
-	WebTTY *webTTY
-	Master master
-	Ctx    context.Context
-	Cancel context.CancelFunc
+	ID       string
+	WebTTY   *webTTY
+	Queue    *clientQueue
+	ReadOnly bool
+	Binary   bool
+	Ctx      context.Context
+	Cancel   context.CancelFunc
+	// cols and rows are the size the client asked for, guarded by the
+	// terminal's resizeMu.
+	cols       int
+	rows       int
+	lastActive atomic.Int64
+	User       string
+	RemoteAddr string
+	UserAgent  string
+	AttachedAt time.Time
+	reason     atomic.Value
+	// token is the identity the client keeps across reconnects.
+	token string
 }
 
 type activeTerminal struct {
-	ID            string
-	PTY           slave
-	Session       *model.TerminalSession
-	WebTTYs       sync.Map
-	Done          chan struct{}
-	historyBuffer *historyBuffer
-	historyMu     sync.RWMutex
-	ptyStatus     atomic.Value
-	flushTicker   *time.Ticker
-	bufferSize    int
-	encoder       *base64.Encoding
+	ID                   string
+	PTY                  slave
+	Session              *model.TerminalSession
+	WebTTYs              sync.Map
+	Done                 chan struct{}
+	historyBuffer        *historyBuffer
+	screen               *screen
+	recorder             *recorder
+	shell                shellState
+	notify               notifyState
+	triggers             triggerState
+	replies              chan []byte
+	taps                 sync.Map
+	runMu                sync.Mutex
+	resizeMu             sync.Mutex
+	cols                 int
+	rows                 int
+	resizePolicy         string
+	pinnedClient         string
+	pinnedToken          string
+	historyPending       []byte
+	historyPendingOffset int64
+	historyMu            sync.RWMutex
+	historySeq           int64
+	historyStored        int64
+	flushMu              sync.Mutex
+	ptyStatus            atomic.Value
+	exitStatus           atomic.Pointer[exitStatus]
+	lastActivity         atomic.Int64
+	exitedAt             atomic.Int64
+	readDone             chan struct{}
+	flushTicker          *time.Ticker
+	bufferSize           int
+}
+
+type exitStatus struct {
+	code   int
+	signal string
+	reason string
 }
 
 type Manager struct {
 	db                   *gorm.DB
 	terminals            sync.Map
+	groups               sync.Map
 	shell                string
 	bufferSize           int
 	maxConnections       int
 	activeConns          atomic.Int64
 	historyBufferSize    int
 	historyFlushInterval time.Duration
-	historyMaxRecords    int
+	historyMaxBytes      int64
 	historyMaxAge        time.Duration
+	recordDir            string
+	recordAll            bool
+	scrollbackLines      int
+	clientQueueSize      int
+	overflowPolicy       string
+	notifyStream         *StreamSink
+	notifySinks          []NotificationSink
+	awaitingInput        *regexp.Regexp
+	longCommand          time.Duration
+	notifyCooldown       time.Duration
+	triggerBurst         int
+	triggerWindow        time.Duration
+	persistent           bool
+	supervisorDir        string
+	exitedTimeout        time.Duration
+	idleTimeout          time.Duration
+	sessionRetention     time.Duration
+	resizePolicy         string
+	keepalive            keepalive
+	env                  EnvPolicy
+	backends             map[string]backend
+	knownHostsFile       string
+	sshAgentSocket       string
+	secrets              *secretBox
+	cgroupRoot           string
+	stop                 chan struct{}
+	stopOnce             sync.Once
 }
 
 func NewManager(db *gorm.DB, cfg *ManagerConfig) *Manager {
@@ -56,22 +128,76 @@ func NewManager(db *gorm.DB, cfg *ManagerConfig) *Manager {
 	}
 	cfg.applyDefaults()
 
-	return &Manager{
+	awaitingInput, err := regexp.Compile(cfg.AwaitingInputPattern)
+	if err != nil {
+		log.Warn().Err(err).Msg("Invalid awaiting input pattern, prompt notifications disabled")
+	}
+
+	m := &Manager{
 		db:                   db,
 		shell:                cfg.Shell,
 		bufferSize:           cfg.BufferSize,
 		maxConnections:       cfg.MaxConnections,
 		historyBufferSize:    cfg.HistoryBufferSize,
 		historyFlushInterval: cfg.HistoryFlushInterval,
-		historyMaxRecords:    cfg.HistoryMaxRecords,
+		historyMaxBytes:      cfg.HistoryMaxBytes,
 		historyMaxAge:        cfg.HistoryMaxAge,
+		recordDir:            cfg.RecordDir,
+		recordAll:            cfg.RecordAll,
+		scrollbackLines:      cfg.ScrollbackLines,
+		clientQueueSize:      cfg.ClientQueueSize,
+		overflowPolicy:       cfg.OverflowPolicy,
+		notifyStream:         NewStreamSink(),
+		notifySinks:          cfg.NotifySinks,
+		awaitingInput:        awaitingInput,
+		longCommand:          cfg.LongCommandThreshold,
+		notifyCooldown:       cfg.NotifyCooldown,
+		triggerBurst:         cfg.TriggerBurst,
+		triggerWindow:        cfg.TriggerWindow,
+		persistent:           cfg.Persistent,
+		supervisorDir:        cfg.SupervisorDir,
+		exitedTimeout:        cfg.ExitedTimeout,
+		idleTimeout:          cfg.IdleTimeout,
+		sessionRetention:     cfg.SessionRetention,
+		resizePolicy:         cfg.ResizePolicy,
+		keepalive:            cfg.keepalive(),
+		env:                  cfg.Env,
+		knownHostsFile:       cfg.KnownHostsFile,
+		sshAgentSocket:       cfg.SSHAgentSocket,
+		secrets:              &secretBox{path: cfg.SecretKeyFile},
+		cgroupRoot:           cfg.CgroupRoot,
+		stop:                 make(chan struct{}),
 	}
+	m.registerBackends()
+	go m.maintenanceLoop(cfg.MaintenanceInterval)
+	return m
 }
 
 func (m *Manager) Create(opts CreateOptions) (*TerminalInfo, error) {
+	if err := m.applyProfile(&opts); err != nil {
+		return nil, err
+	}
+	if opts.ResizePolicy == "" {
+		opts.ResizePolicy = m.resizePolicy
+	} else if !validResizePolicy(opts.ResizePolicy) {
+		return nil, ErrInvalidResizePolicy
+	}
+	if err := validateLimits(opts.Limits); err != nil {
+		return nil, err
+	}
+
+	backend, err := m.backendFor(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// Remote backends resolve an empty directory and command on their side.
+	remote := backend == model.BackendSSH
+	if limited(opts.Limits) && (remote || !sandboxSupported) {
+		return nil, ErrLimitsUnsupported
+	}
 	cwd := opts.Cwd
-	if cwd == "" {
-		var err error
+	if cwd == "" && !remote {
 		cwd, err = os.Getwd()
 		if err != nil {
 			cwd = os.Getenv("HOME")
@@ -86,50 +212,123 @@ func (m *Manager) Create(opts CreateOptions) (*TerminalInfo, error) {
 		rows = 24
 	}
 
-	pty, err := newLocalCommand(m.shell, nil, cwd, cols, rows)
-	if err != nil {
-		return nil, err
+	command := opts.Command
+	if command == "" && !remote {
+		command = m.shell
 	}
 
 	now := time.Now().Unix()
 	session := &model.TerminalSession{
-		ID:        uuid.New().String(),
-		UserID:    opts.UserID,
-		Name:      opts.Name,
-		Shell:     m.shell,
-		Cwd:       cwd,
-		Cols:      cols,
-		Rows:      rows,
-		Status:    model.StatusActive,
-		PTYStatus: model.PTYStatusRunning,
-		CreatedAt: now,
-		UpdatedAt: now,
+		ID:             uuid.New().String(),
+		UserID:         opts.UserID,
+		Name:           opts.Name,
+		Shell:          command,
+		Args:           opts.Args,
+		StartupCommand: opts.StartupCommand,
+		ProfileID:      opts.ProfileID,
+		Cwd:            cwd,
+		Cols:           cols,
+		Rows:           rows,
+		Status:         model.StatusActive,
+		PTYStatus:      model.PTYStatusRunning,
+		Backend:        backend,
+		HostID:         opts.HostID,
+		ResizePolicy:   opts.ResizePolicy,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	if limited(opts.Limits) {
+		session.Limits = opts.Limits
+	}
+	if usesCgroup(opts.Limits) {
+		if session.Cgroup, err = m.createCgroup(session.ID, opts.Limits); err != nil {
+			return nil, err
+		}
+	}
+
+	pty, err := m.backends[backend](session, opts)
+	if err != nil {
+		removeCgroup(session.Cgroup)
+		return nil, err
 	}
 
 	if err := m.db.Create(session).Error; err != nil {
 		pty.Close()
+		removeCgroup(session.Cgroup)
 		return nil, err
 	}
 
+	var rec *recorder
+	if opts.Record || m.recordAll {
+		if rec, err = m.startRecording(session, opts.Term); err != nil {
+			pty.Close()
+			removeCgroup(session.Cgroup)
+			m.markClosed(session.ID)
+			return nil, err
+		}
+	}
+
+	active := m.newActiveTerminal(session, pty)
+	active.recorder = rec
+	m.start(active, pty)
+
+	if opts.StartupCommand != "" {
+		pty.Write([]byte(opts.StartupCommand + "\n"))
+	}
+
+	return sessionToInfo(session), nil
+}
+
+func (m *Manager) newActiveTerminal(session *model.TerminalSession, pty process) *activeTerminal {
 	active := &activeTerminal{
 		ID:            session.ID,
 		PTY:           pty,
 		Session:       session,
 		Done:          make(chan struct{}),
+		readDone:      make(chan struct{}),
+		replies:       make(chan []byte, triggerReplyQueue),
 		historyBuffer: newHistoryBuffer(m.historyBufferSize),
+		screen:        newScreen(session.Cols, session.Rows, m.scrollbackLines),
 		flushTicker:   time.NewTicker(m.historyFlushInterval),
 		bufferSize:    m.bufferSize,
-		encoder:       base64.StdEncoding,
+		cols:          session.Cols,
+		rows:          session.Rows,
+		resizePolicy:  session.ResizePolicy,
 	}
+	if !validResizePolicy(active.resizePolicy) {
+		active.resizePolicy = m.resizePolicy
+	}
+	active.triggers.set(m.loadTriggers(session))
 	active.ptyStatus.Store(model.PTYStatusRunning)
+	active.touch()
+	return active
+}
 
-	m.terminals.Store(session.ID, active)
+func (m *Manager) start(active *activeTerminal, pty process) {
+	m.terminals.Store(active.ID, active)
 
 	go m.ptyReadLoop(active)
 	go m.monitorPTY(active, pty)
 	go m.flushHistory(active)
+	go m.writeReplies(active)
+}
 
-	return sessionToInfo(session), nil
+// terminalEnv returns the variables set on top of the inherited environment:
+// the workspace's, then the terminal's own.
+func (m *Manager) terminalEnv(cwd string, env map[string]string) []string {
+	return append(m.env.inject(cwd), envList(env)...)
+}
+
+func envList(env map[string]string) []string {
+	if len(env) == 0 {
+		return nil
+	}
+	list := make([]string, 0, len(env))
+	for k, v := range env {
+		list = append(list, k+"="+v)
+	}
+	sort.Strings(list)
+	return list
 }
 
 func (m *Manager) markClosed(id string) {
@@ -147,23 +346,42 @@ func (m *Manager) getActive(id string) (*activeTerminal, bool) {
 	return val.(*activeTerminal), true
 }
 
+// ownedBy limits a query to the rows of userID and those shared by every
+// user.
+func (m *Manager) ownedBy(userID string) *gorm.DB {
+	return m.db.Where("(user_id = ? OR user_id = '')", userID)
+}
+
 func (m *Manager) Get(id string) (*TerminalInfo, bool) {
 	at, ok := m.getActive(id)
 	if !ok {
 		return nil, false
 	}
-	return &TerminalInfo{
-		ID:        at.Session.ID,
-		Name:      at.Session.Name,
-		Shell:     at.Session.Shell,
-		Cwd:       at.Session.Cwd,
-		Cols:      at.Session.Cols,
-		Rows:      at.Session.Rows,
-		Status:    at.Session.Status,
-		PTYStatus: at.ptyStatus.Load().(string),
-		CreatedAt: at.Session.CreatedAt,
-		UpdatedAt: at.Session.UpdatedAt,
-	}, true
+	// The stored session is overlaid with the live state.
+	info := sessionToInfo(at.Session)
+	info.Cols, info.Rows = at.size()
+	info.PTYStatus = at.ptyStatus.Load().(string)
+	info.ResizePolicy, info.PinnedClient = at.resizeState()
+	info.Writers, info.Viewers = at.clientCounts()
+	if es := at.exitStatus.Load(); es != nil {
+		info.ExitCode = es.code
+		info.ExitSignal = es.signal
+		info.ExitReason = es.reason
+	}
+	at.shell.fill(info)
+	return info, true
+}
+
+func (at *activeTerminal) clientCounts() (writers, viewers int) {
+	at.WebTTYs.Range(func(key, value any) bool {
+		if value.(*webTTYInstance).ReadOnly {
+			viewers++
+		} else {
+			writers++
+		}
+		return true
+	})
+	return writers, viewers
 }
 
 func (m *Manager) Resize(id string, cols, rows int) error {
@@ -172,17 +390,9 @@ func (m *Manager) Resize(id string, cols, rows int) error {
 		return ErrTerminalNotFound
 	}
 
-	if err := at.PTY.ResizeTerminal(cols, rows); err != nil {
-		return err
-	}
-
-	m.db.Model(&model.TerminalSession{}).Where("id = ?", id).Updates(map[string]any{
-		"cols":       cols,
-		"rows":       rows,
-		"updated_at": time.Now().Unix(),
-	})
-
-	return nil
+	at.resizeMu.Lock()
+	defer at.resizeMu.Unlock()
+	return m.applySize(at, cols, rows)
 }
 
 func (m *Manager) Close(id string) error {
@@ -191,6 +401,7 @@ func (m *Manager) Close(id string) error {
 		return nil
 	}
 	at := val.(*activeTerminal)
+	m.leaveGroups(id)
 
 	at.WebTTYs.Range(func(key, value any) bool {
 		instance := value.(*webTTYInstance)
@@ -200,12 +411,11 @@ func (m *Manager) Close(id string) error {
 
 	at.flushTicker.Stop()
 
-	at.historyMu.Lock()
 	m.flushHistoryToDB(at)
-	at.historyMu.Unlock()
 
 	at.PTY.Close()
 	close(at.Done)
+	m.finishRecording(at)
 
 	m.db.Model(&model.TerminalSession{}).Where("id = ?", id).Updates(map[string]any{
 		"status":     model.StatusClosed,
@@ -218,12 +428,12 @@ func (m *Manager) Close(id string) error {
 
 func (m *Manager) Delete(id string) error {
 	m.Close(id)
-	m.db.Where("session_id = ?", id).Delete(&model.TerminalHistory{})
-	m.db.Where("id = ?", id).Delete(&model.TerminalSession{})
-	return nil
+	return m.deleteSessions([]string{id})
 }
 
 func (m *Manager) ptyReadLoop(at *activeTerminal) {
+	defer close(at.readDone)
+
 	maxRawSize := (at.bufferSize - 1) / 4 * 3
 	buf := make([]byte, maxRawSize)
 
@@ -240,38 +450,105 @@ func (m *Manager) ptyReadLoop(at *activeTerminal) {
 		}
 
 		if n > 0 {
+			// Holding historyMu across the broadcast keeps output ordered
+			// with respect to clients replaying history in Attach.
+			at.touch()
 			at.historyMu.Lock()
-			at.historyBuffer.Write(buf[:n])
+			m.appendHistory(at, buf[:n])
+			at.screen.Write(buf[:n])
+			if at.recorder != nil {
+				at.recorder.Output(buf[:n])
+			}
+			offset := at.historyBuffer.Offset()
+			events, bell := at.shell.Feed(buf[:n])
+			at.broadcastOutput(buf[:n], offset)
+			at.broadcastCommandEvents(events, offset)
+			at.feedTaps(buf[:n])
 			at.historyMu.Unlock()
 
-			msg := WSMessage{
-				Type: MsgTypeCmd,
-				Data: at.encoder.EncodeToString(buf[:n]),
+			m.detectNotifications(at, buf[:n], events, bell)
+			m.runTriggers(at, buf[:n], offset)
+		}
+	}
+}
+
+// broadcastOutput sends PTY output to every client, encoding it at most once
+// per wire format.
+func (at *activeTerminal) broadcastOutput(data []byte, offset int64) {
+	var jsonFrame, binaryFrame []byte
+	at.WebTTYs.Range(func(key, value any) bool {
+		instance := value.(*webTTYInstance)
+		if instance.Binary {
+			if binaryFrame == nil {
+				binaryFrame = encodeOutputBinary(data, offset)
 			}
-			msgData, _ := json.Marshal(msg)
+			instance.Queue.WriteOutput(binaryFrame, true, offset)
+		} else {
+			if jsonFrame == nil {
+				jsonFrame = encodeOutputJSON(data, offset)
+			}
+			instance.Queue.WriteOutput(jsonFrame, false, offset)
+		}
+		return true
+	})
+}
 
-			at.WebTTYs.Range(func(key, value any) bool {
-				instance := value.(*webTTYInstance)
-				instance.Master.Write(msgData)
-				return true
-			})
+func (at *activeTerminal) broadcastCommandEvents(events []shellEvent, offset int64) {
+	for _, ev := range events {
+		msg := CommandMessage{
+			Type:     MsgTypeCommandEnd,
+			Command:  ev.command,
+			ExitCode: ev.exitCode,
+			Cwd:      ev.cwd,
+			Offset:   offset,
 		}
+		if ev.start {
+			msg.Type = MsgTypeCommandStart
+		}
+		msgData, _ := json.Marshal(msg)
+		at.broadcast(msgData)
 	}
 }
 
-func (m *Manager) monitorPTY(at *activeTerminal, pty *localCommand) {
-	<-pty.ptyClosed
+func (at *activeTerminal) broadcast(data []byte) {
+	at.WebTTYs.Range(func(key, value any) bool {
+		instance := value.(*webTTYInstance)
+		instance.Queue.Write(data)
+		return true
+	})
+}
+
+func (m *Manager) monitorPTY(at *activeTerminal, pty process) {
+	<-pty.done()
+	// Let the read loop drain remaining output so the exit event is the
+	// last thing clients see.
+	<-at.readDone
 
+	code, signal := pty.exitStatus()
+	reason := exitReason(at.Session, signal)
+	removeCgroup(at.Session.Cgroup)
+	at.exitStatus.Store(&exitStatus{code: code, signal: signal, reason: reason})
 	at.ptyStatus.Store(model.PTYStatusExited)
+	at.exitedAt.Store(time.Now().UnixNano())
 
 	m.db.Model(&model.TerminalSession{}).Where("id = ?", at.ID).Updates(map[string]any{
-		"pty_status": model.PTYStatusExited,
-		"updated_at": time.Now().Unix(),
+		"pty_status":  model.PTYStatusExited,
+		"exit_code":   code,
+		"exit_signal": signal,
+		"exit_reason": reason,
+		"updated_at":  time.Now().Unix(),
 	})
 
-	at.historyMu.Lock()
 	m.flushHistoryToDB(at)
-	at.historyMu.Unlock()
+	m.finishRecording(at)
+
+	msgData, _ := json.Marshal(ExitMessage{
+		Type:     MsgTypeExit,
+		ExitCode: code,
+		Signal:   signal,
+		Reason:   reason,
+	})
+	at.broadcast(msgData)
 }
 
 func (m *Manager) List() ([]TerminalInfo, error) {
@@ -282,14 +559,18 @@ func (m *Manager) List() ([]TerminalInfo, error) {
 	result := make([]TerminalInfo, len(sessions))
 	for i, s := range sessions {
 		result[i] = *sessionToInfo(&s)
+		if at, ok := m.getActive(s.ID); ok {
+			result[i].Writers, result[i].Viewers = at.clientCounts()
+			at.shell.fill(&result[i])
+		}
 	}
 	return result, nil
 }
 
-func (m *Manager) Attach(id string, conn *websocket.Conn) (*Connection, error) {
+func (m *Manager) Attach(id string, conn *websocket.Conn, opts AttachOptions) (*Connection, error) {
 	at, ok := m.getActive(id)
 	if !ok {
-		return m.sendHistoryOnly(id, conn)
+		return m.sendHistoryOnly(id, conn, opts)
 	}
 
 	if m.maxConnections > 0 && int(m.activeConns.Load()) >= m.maxConnections {
@@ -297,32 +578,66 @@ func (m *Manager) Attach(id string, conn *websocket.Conn) (*Connection, error) {
 	}
 
 	clientID := uuid.New().String()
-	mst := newWSMaster(conn)
+	binary := conn.Subprotocol() == SubprotocolBinary
+	wsm := newWSMaster(conn, m.keepalive)
+	queue := newClientQueue(wsm, m.clientQueueSize)
 
 	ctx, cancel := context.WithCancel(context.Background())
 	doneCh := make(chan struct{})
 
 	instance := &webTTYInstance{
-		ID:     clientID,
-		Master: mst,
-		Ctx:    ctx,
-		Cancel: cancel,
+		ID:         clientID,
+		Queue:      queue,
+		ReadOnly:   opts.ReadOnly,
+		Binary:     binary,
+		Ctx:        ctx,
+		Cancel:     cancel,
+		User:       opts.User,
+		RemoteAddr: opts.RemoteAddr,
+		UserAgent:  opts.UserAgent,
+		AttachedAt: time.Now(),
+		token:      opts.ClientToken,
 	}
+	queue.onOverflow = func() { m.handleOverflow(at, instance) }
+	queue.onError = cancel
+	go queue.run()
 
 	wt := newWebTTY(
-		mst,
+		queue,
 		at.PTY,
 		withBufferSize(m.bufferSize),
+		withPermitWrite(!opts.ReadOnly),
+		withBinary(binary),
 		withSkipSlaveReadLoop(true),
+		withOnResize(func(cols, rows int) {
+			m.clientResize(at, instance, cols, rows)
+		}),
+		withOnInput(func() {
+			m.clientInput(at, instance)
+		}),
 		withOnReady(func() {
-			m.replayHistory(at, mst)
-			at.WebTTYs.Store(clientID, instance)
+			at.historyMu.Lock()
+			m.replayHistory(at, queue, binary, opts)
+			at.resizeMu.Lock()
+			at.addClient(instance)
+			at.resizeMu.Unlock()
+			at.historyMu.Unlock()
+			at.touch()
 			m.activeConns.Add(1)
+			at.announceClient(instance, MsgTypeClientJoin, "")
 		}),
 		withOnClosed(func() {
-			at.WebTTYs.Delete(clientID)
+			m.removeClient(at, clientID)
+			at.touch()
 			m.activeConns.Add(-1)
-			conn.Close()
+			reason := instance.leaveReason()
+			at.announceClient(instance, MsgTypeClientLeave, reason)
+			if reason == LeaveKicked {
+				at.closeKicked(instance)
+			} else {
+				queue.close(false)
+			}
+			wsm.Close()
 			close(doneCh)
 		}),
 	)
@@ -338,49 +653,185 @@ func (m *Manager) Attach(id string, conn *websocket.Conn) (*Connection, error) {
 	return &Connection{Done: doneCh}, nil
 }
 
-func (m *Manager) sendHistoryOnly(id string, conn *websocket.Conn) (*Connection, error) {
-	historyData, err := m.loadHistoryFromDB(id)
+func (m *Manager) sendHistoryOnly(id string, conn *websocket.Conn, opts AttachOptions) (*Connection, error) {
+	var session model.TerminalSession
+	if err := m.db.First(&session, "id = ?", id).Error; err != nil {
+		return nil, ErrTerminalNotFound
+	}
+	historyData, start, err := m.loadHistoryFromDB(id)
 	if err != nil {
 		return nil, ErrTerminalNotFound
 	}
 
-	mst := newWSMaster(conn)
+	queue := newClientQueue(newWSMaster(conn, keepalive{writeTimeout: m.keepalive.writeTimeout}), 0)
+	go queue.run()
 
-	if len(historyData) > 0 {
-		msg := WSMessage{
-			Type: MsgTypeCmd,
-			Data: base64.StdEncoding.EncodeToString(historyData),
+	binary := conn.Subprotocol() == SubprotocolBinary
+	end := start + int64(len(historyData))
+	if opts.Resume && opts.Since >= start && opts.Since <= end {
+		writeOutput(queue, binary, historyData[opts.Since-start:], end)
+	} else {
+		if opts.Resume {
+			writeGap(queue, opts.Since, end)
 		}
-		msgData, _ := json.Marshal(msg)
-		mst.Write(msgData)
+		scr := newScreen(session.Cols, session.Rows, m.scrollbackLines)
+		scr.Write(historyData)
+		writeOutput(queue, binary, scr.Snapshot(), end)
 	}
+	queue.close(true)
+	<-queue.done
 
 	doneCh := make(chan struct{})
 	close(doneCh)
 	return &Connection{Done: doneCh}, nil
 }
 
-func (m *Manager) replayHistory(at *activeTerminal, mst master) error {
-	at.historyMu.RLock()
-	historyData := at.historyBuffer.Read()
-	at.historyMu.RUnlock()
-
-	if len(historyData) > 0 {
-		msg := WSMessage{
-			Type: MsgTypeCmd,
-			Data: base64.StdEncoding.EncodeToString(historyData),
+// replayHistory brings a newly attached client up to date. Callers must hold
+// historyMu.
+func (m *Manager) replayHistory(at *activeTerminal, q *clientQueue, binary bool, opts AttachOptions) {
+	offset := at.historyBuffer.Offset()
+	if opts.Resume {
+		data, start, gap := at.historyBuffer.ReadSince(opts.Since)
+		if !gap {
+			writeOutput(q, binary, data, start+int64(len(data)))
+			return
 		}
-		msgData, _ := json.Marshal(msg)
-		mst.Write(msgData)
+		writeGap(q, opts.Since, offset)
 	}
+	writeOutput(q, binary, at.screen.Snapshot(), offset)
+}
 
-	return nil
+// writeGap tells a client that the output between since and offset is lost
+// and that the next frame restores the terminal state as of offset.
+func writeGap(q *clientQueue, since, offset int64) {
+	msgData, _ := json.Marshal(GapMessage{
+		Type:   MsgTypeGap,
+		Since:  since,
+		Offset: offset,
+	})
+	q.Write(msgData)
+}
+
+// writeOutput queues data as a single output frame ending at stream offset
+// end. Replayed output bypasses the queue limit.
+func writeOutput(q *clientQueue, binary bool, data []byte, end int64) {
+	if len(data) == 0 {
+		return
+	}
+	if binary {
+		q.push(queuedFrame{data: encodeOutputBinary(data, end), binary: true, offset: end}, false)
+	} else {
+		q.push(queuedFrame{data: encodeOutputJSON(data, end), offset: end}, false)
+	}
+}
+
+// Screen returns a plain-text dump of a terminal's screen. Closed sessions
+// are rendered by replaying their stored history.
+func (m *Manager) Screen(id string, scrollback bool) (*ScreenDump, error) {
+	if at, ok := m.getActive(id); ok {
+		return at.screen.Dump(scrollback), nil
+	}
+
+	var session model.TerminalSession
+	if err := m.db.First(&session, "id = ?", id).Error; err != nil {
+		return nil, ErrTerminalNotFound
+	}
+	data, _, err := m.loadHistoryFromDB(id)
+	if err != nil {
+		return nil, err
+	}
+	scr := newScreen(session.Cols, session.Rows, m.scrollbackLines)
+	scr.Write(data)
+	return scr.Dump(scrollback), nil
 }
 
+// CleanupOnStart re-adopts live persistent sessions and marks the others left
+// running as exited.
 func (m *Manager) CleanupOnStart() {
-	m.db.Model(&model.TerminalSession{}).Where("pty_status = ?", model.PTYStatusRunning).Updates(map[string]any{
+	var sessions []model.TerminalSession
+	m.db.Where("pty_status = ? AND backend = ?", model.PTYStatusRunning, model.BackendSupervisor).Find(&sessions)
+
+	var adopted []string
+	for i := range sessions {
+		session := &sessions[i]
+		if _, ok := m.getActive(session.ID); !ok {
+			if err := m.adopt(session); err != nil {
+				log.Warn().Err(err).Str("session", session.ID).Msg("Failed to re-adopt terminal session")
+				continue
+			}
+		}
+		adopted = append(adopted, session.ID)
+	}
+
+	query := m.db.Model(&model.TerminalSession{}).Where("pty_status = ?", model.PTYStatusRunning)
+	if len(adopted) > 0 {
+		query = query.Where("id NOT IN ?", adopted)
+	}
+	var stale []model.TerminalSession
+	query.Session(&gorm.Session{}).Where("cgroup <> ''").Find(&stale)
+	for i := range stale {
+		removeCgroup(stale[i].Cgroup)
+	}
+	query.Updates(map[string]any{
 		"status":     model.StatusClosed,
 		"pty_status": model.PTYStatusExited,
 		"updated_at": time.Now().Unix(),
 	})
 }
+
+// adopt reconnects to the supervisor of a persistent session.
+func (m *Manager) adopt(session *model.TerminalSession) error {
+	data, start, err := m.loadHistoryFromDB(session.ID)
+	if err != nil {
+		return err
+	}
+	var last model.TerminalHistory
+	if err := m.db.Where("session_id = ?", session.ID).Order("sequence DESC").Limit(1).Find(&last).Error; err != nil {
+		return err
+	}
+
+	pty, err := dialSupervisor(session.Socket)
+	if err != nil {
+		return err
+	}
+	pty.command, pty.argv, pty.cwd = session.Shell, session.Args, session.Cwd
+
+	active := m.newActiveTerminal(session, pty)
+	active.historyBuffer.total = start
+	active.historyBuffer.Write(data)
+	active.historyPendingOffset = start + int64(len(data))
+	active.historySeq = last.Sequence + 1
+	active.historyStored = session.HistorySize
+	active.screen.Write(data)
+	m.start(active, pty)
+	return nil
+}
+
+// Shutdown detaches from persistent sessions, leaving them to be re-adopted
+// on the next start, and closes all other sessions.
+func (m *Manager) Shutdown() {
+	m.stopOnce.Do(func() { close(m.stop) })
+	m.terminals.Range(func(key, value any) bool {
+		at := value.(*activeTerminal)
+		sc, ok := at.PTY.(*supervisedCommand)
+		if !ok {
+			m.Close(at.ID)
+			return true
+		}
+
+		m.terminals.Delete(at.ID)
+		at.WebTTYs.Range(func(key, value any) bool {
+			value.(*webTTYInstance).Cancel()
+			return true
+		})
+		at.flushTicker.Stop()
+		sc.detach()
+		// Everything read from the supervisor is stored before the next
+		// server resumes from the end of the stored history.
+		<-at.readDone
+		m.flushHistoryToDB(at)
+		close(at.Done)
+		m.finishRecording(at)
+		return true
+	})
+}
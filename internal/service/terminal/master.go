@@ -2,6 +2,7 @@ package terminal
 
 import (
 	"sync"
+	"time"
 
 	"github.com/gorilla/websocket"
 )
@@ -9,15 +10,69 @@ import (
 type master interface {
 	Read(p []byte) (n int, err error)
 	Write(p []byte) (n int, err error)
+	WriteBinary(p []byte) (n int, err error)
+}
+
+// keepalive configures WebSocket liveness checks. A zero duration disables
+// the check.
+type keepalive struct {
+	pingInterval time.Duration
+	pongTimeout  time.Duration
+	writeTimeout time.Duration
 }
 
 type wsMaster struct {
-	conn *websocket.Conn
-	mu   sync.Mutex
+	conn      *websocket.Conn
+	mu        sync.Mutex
+	keepalive keepalive
+	stop      chan struct{}
+	closeOnce sync.Once
 }
 
-func newWSMaster(conn *websocket.Conn) *wsMaster {
-	return &wsMaster{conn: conn}
+func newWSMaster(conn *websocket.Conn, ka keepalive) *wsMaster {
+	m := &wsMaster{conn: conn, keepalive: ka, stop: make(chan struct{})}
+	if ka.pongTimeout > 0 {
+		m.extendReadDeadline()
+		conn.SetPongHandler(func(string) error {
+			m.extendReadDeadline()
+			return nil
+		})
+	}
+	if ka.pingInterval > 0 {
+		go m.ping()
+	}
+	return m
+}
+
+func (m *wsMaster) extendReadDeadline() {
+	if m.keepalive.pongTimeout > 0 {
+		m.conn.SetReadDeadline(time.Now().Add(m.keepalive.pongTimeout))
+	}
+}
+
+func (m *wsMaster) writeDeadline() time.Time {
+	if m.keepalive.writeTimeout <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(m.keepalive.writeTimeout)
+}
+
+// ping sends WebSocket pings until the master is closed. A failed ping
+// closes the connection so the pending Read returns.
+func (m *wsMaster) ping() {
+	ticker := time.NewTicker(m.keepalive.pingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.stop:
+			return
+		case <-ticker.C:
+			if err := m.conn.WriteControl(websocket.PingMessage, nil, m.writeDeadline()); err != nil {
+				m.Close()
+				return
+			}
+		}
+	}
 }
 
 func (m *wsMaster) Read(p []byte) (int, error) {
@@ -26,6 +81,7 @@ func (m *wsMaster) Read(p []byte) (int, error) {
 		if err != nil {
 			return 0, err
 		}
+		m.extendReadDeadline()
 		if msgType == websocket.TextMessage || msgType == websocket.BinaryMessage {
 			return copy(p, data), nil
 		}
@@ -33,12 +89,31 @@ func (m *wsMaster) Read(p []byte) (int, error) {
 }
 
 func (m *wsMaster) Write(p []byte) (int, error) {
+	return m.writeMessage(websocket.TextMessage, p)
+}
+
+func (m *wsMaster) WriteBinary(p []byte) (int, error) {
+	return m.writeMessage(websocket.BinaryMessage, p)
+}
+
+func (m *wsMaster) writeMessage(messageType int, p []byte) (int, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	err := m.conn.WriteMessage(websocket.TextMessage, p)
+	m.conn.SetWriteDeadline(m.writeDeadline())
+	err := m.conn.WriteMessage(messageType, p)
 	if err != nil {
 		return 0, err
 	}
 	return len(p), nil
 }
+
+// Close stops the pinger and closes the connection.
+func (m *wsMaster) Close() error {
+	var err error
+	m.closeOnce.Do(func() {
+		close(m.stop)
+		err = m.conn.Close()
+	})
+	return err
+}
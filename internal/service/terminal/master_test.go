@@ -3,8 +3,10 @@ package terminal
 import (
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/gorilla/websocket"
 )
@@ -19,7 +21,7 @@ func TestWSMaster_ReadWrite(t *testing.T) {
 		}
 		defer conn.Close()
 
-		master := newWSMaster(conn)
+		master := newWSMaster(conn, keepalive{})
 
 		n, err := master.Write([]byte("hello"))
 		if err != nil {
@@ -72,7 +74,7 @@ func TestWSMaster_ConcurrentWrite(t *testing.T) {
 		}
 		defer conn.Close()
 
-		master := newWSMaster(conn)
+		master := newWSMaster(conn, keepalive{})
 
 		done := make(chan bool, 10)
 		for i := 0; i < 10; i++ {
@@ -111,3 +113,72 @@ func TestWSMaster_ConcurrentWrite(t *testing.T) {
 		t.Errorf("expected 10 messages, got %d", count)
 	}
 }
+
+func TestManager_PingDetachesDeadPeer(t *testing.T) {
+	db := setupTestDB(t)
+	manager := NewManager(db, &ManagerConfig{
+		Shell:        "/bin/sh",
+		PingInterval: 50 * time.Millisecond,
+		PongTimeout:  200 * time.Millisecond,
+	})
+
+	info, err := manager.Create(CreateOptions{Cwd: os.TempDir(), Command: "/bin/sh"})
+	if err != nil {
+		t.Fatalf("failed to create terminal: %v", err)
+	}
+	defer manager.Close(info.ID)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upgrader := websocket.Upgrader{}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		if _, err := manager.Attach(info.ID, conn, AttachOptions{User: r.URL.Query().Get("user")}); err != nil {
+			conn.Close()
+		}
+	}))
+	defer server.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http")
+	alive, _, err := websocket.DefaultDialer.Dial(wsURL+"?user=alive", nil)
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	defer alive.Close()
+	// Reading answers the server's pings.
+	go func() {
+		for {
+			if _, _, err := alive.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	// The dead peer never reads, so its pings go unanswered.
+	dead, _, err := websocket.DefaultDialer.Dial(wsURL+"?user=dead", nil)
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	defer dead.Close()
+
+	time.Sleep(100 * time.Millisecond)
+	if clients, _ := manager.Clients(info.ID); len(clients) != 2 {
+		t.Fatalf("expected 2 clients, got %d", len(clients))
+	}
+
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		clients, _ := manager.Clients(info.ID)
+		if len(clients) == 1 && clients[0].User == "alive" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected only the live client to remain, got %+v", clients)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if n := manager.activeConns.Load(); n != 1 {
+		t.Errorf("expected the dead peer to stop counting as a connection, got %d", n)
+	}
+}
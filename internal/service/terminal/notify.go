@@ -0,0 +1,213 @@
+package terminal
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+// Notification kinds.
+const (
+	NotifyBell            = "bell"
+	NotifyAwaitingInput   = "awaiting_input"
+	NotifyCommandFinished = "command_finished"
+	NotifyTrigger         = "trigger"
+)
+
+// DefaultAwaitingInputPattern matches common confirmation prompts, including
+// coding agents asking for approval.
+const DefaultAwaitingInputPattern = `(?i)(\[y/n\]|\(y/n\)|\[yes/no\]|\(yes/no\)|do you want to (proceed|continue|make this edit|create|run)|press (enter|any key) to continue|waiting for (your )?input)`
+
+const (
+	maxNotifyTail   = 2048
+	maxNotifyLine   = 200
+	notifySendLimit = 10 * time.Second
+)
+
+// Notification tells the user that a terminal needs attention.
+type Notification struct {
+	ID        string  `json:"id"`
+	Kind      string  `json:"kind"`
+	SessionID string  `json:"session_id"`
+	UserID    string  `json:"user_id,omitempty"`
+	Title     string  `json:"title"`
+	Message   string  `json:"message"`
+	Command   string  `json:"command,omitempty"`
+	ExitCode  int     `json:"exit_code"`
+	Duration  float64 `json:"duration,omitempty"`
+	Trigger   string  `json:"trigger,omitempty"`
+	CreatedAt int64   `json:"created_at"`
+}
+
+// NotificationSink delivers notifications to one destination.
+type NotificationSink interface {
+	Notify(ctx context.Context, n *Notification) error
+}
+
+// StreamSink fans notifications out to in-app subscribers. Subscribers that
+// fall behind miss notifications instead of blocking delivery.
+type StreamSink struct {
+	mu   sync.Mutex
+	subs map[chan *Notification]struct{}
+}
+
+func NewStreamSink() *StreamSink {
+	return &StreamSink{subs: make(map[chan *Notification]struct{})}
+}
+
+// Subscribe returns a channel receiving every later notification and a
+// function that ends the subscription.
+func (s *StreamSink) Subscribe() (<-chan *Notification, func()) {
+	ch := make(chan *Notification, 16)
+	s.mu.Lock()
+	s.subs[ch] = struct{}{}
+	s.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			s.mu.Lock()
+			delete(s.subs, ch)
+			s.mu.Unlock()
+		})
+	}
+}
+
+func (s *StreamSink) Notify(ctx context.Context, n *Notification) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for ch := range s.subs {
+		select {
+		case ch <- n:
+		default:
+		}
+	}
+	return nil
+}
+
+// notifyState holds the per-terminal notification detection state.
+type notifyState struct {
+	mu           sync.Mutex
+	tail         []byte
+	last         map[string]time.Time
+	commandStart time.Time
+}
+
+// allow reports whether a notification of kind may fire now, and records it
+// if so.
+func (ns *notifyState) allow(kind string, now time.Time, cooldown time.Duration) bool {
+	if last, ok := ns.last[kind]; ok && now.Sub(last) < cooldown {
+		return false
+	}
+	if ns.last == nil {
+		ns.last = make(map[string]time.Time)
+	}
+	ns.last[kind] = now
+	return true
+}
+
+// Notifications returns the in-app notification stream.
+func (m *Manager) Notifications() *StreamSink {
+	return m.notifyStream
+}
+
+// detectNotifications checks a chunk of PTY output for a bell, a prompt
+// waiting for input and the end of a long-running command.
+func (m *Manager) detectNotifications(at *activeTerminal, data []byte, events []shellEvent, bell bool) {
+	ns := &at.notify
+	now := time.Now()
+	var pending []*Notification
+
+	ns.mu.Lock()
+	if bell && ns.allow(NotifyBell, now, m.notifyCooldown) {
+		pending = append(pending, at.newNotification(NotifyBell, "Bell"))
+	}
+
+	if m.awaitingInput != nil {
+		ns.tail = append(ns.tail, stripANSI(data)...)
+		if len(ns.tail) > maxNotifyTail {
+			ns.tail = ns.tail[len(ns.tail)-maxNotifyTail:]
+		}
+		if loc := m.awaitingInput.FindIndex(ns.tail); loc != nil {
+			line := matchedLine(ns.tail, loc)
+			ns.tail = ns.tail[:0]
+			if ns.allow(NotifyAwaitingInput, now, m.notifyCooldown) {
+				pending = append(pending, at.newNotification(NotifyAwaitingInput, line))
+			}
+		}
+	}
+
+	for _, ev := range events {
+		if ev.start {
+			ns.commandStart = now
+			continue
+		}
+		if !ns.commandStart.IsZero() {
+			if elapsed := now.Sub(ns.commandStart); elapsed >= m.longCommand {
+				n := at.newNotification(NotifyCommandFinished,
+					fmt.Sprintf("%s finished in %s (exit %d)", ev.command, elapsed.Round(time.Second), ev.exitCode))
+				n.Command = ev.command
+				n.ExitCode = ev.exitCode
+				n.Duration = elapsed.Seconds()
+				pending = append(pending, n)
+			}
+		}
+		ns.commandStart = time.Time{}
+	}
+	ns.mu.Unlock()
+
+	for _, n := range pending {
+		m.notify(n)
+	}
+}
+
+func (at *activeTerminal) newNotification(kind, message string) *Notification {
+	title := at.Session.Name
+	if title == "" {
+		title = "Terminal"
+	}
+	return &Notification{
+		Kind:      kind,
+		SessionID: at.ID,
+		UserID:    at.Session.UserID,
+		Title:     title,
+		Message:   message,
+	}
+}
+
+// matchedLine returns the line of text containing the match at loc.
+func matchedLine(text []byte, loc []int) string {
+	start := bytes.LastIndexByte(text[:loc[0]], '\n') + 1
+	end := len(text)
+	if i := bytes.IndexByte(text[loc[1]:], '\n'); i >= 0 {
+		end = loc[1] + i
+	}
+	line := bytes.TrimSpace(text[start:end])
+	if len(line) > maxNotifyLine {
+		line, _ = splitUTF8(line[:maxNotifyLine])
+	}
+	return string(line)
+}
+
+// notify stamps n and hands it to the in-app stream and, in the background,
+// to every configured sink.
+func (m *Manager) notify(n *Notification) {
+	n.ID = uuid.New().String()
+	n.CreatedAt = time.Now().Unix()
+
+	m.notifyStream.Notify(context.Background(), n)
+	for _, sink := range m.notifySinks {
+		go func(sink NotificationSink) {
+			ctx, cancel := context.WithTimeout(context.Background(), notifySendLimit)
+			defer cancel()
+			if err := sink.Notify(ctx, n); err != nil {
+				log.Warn().Err(err).Str("id", n.SessionID).Str("kind", n.Kind).Msg("Failed to deliver terminal notification")
+			}
+		}(sink)
+	}
+}
@@ -0,0 +1,200 @@
+package terminal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	webpush "github.com/SherClockHolmes/webpush-go"
+	"github.com/google/uuid"
+	"github.com/xxnuo/vibego/internal/model"
+	"github.com/xxnuo/vibego/internal/service/kv"
+	"gorm.io/gorm"
+)
+
+// WebhookSink posts every notification as JSON to URL.
+type WebhookSink struct {
+	URL    string
+	Header http.Header
+	Client *http.Client
+}
+
+func (s *WebhookSink) Notify(ctx context.Context, n *Notification) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for key, values := range s.Header {
+		req.Header[key] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+const (
+	vapidKeysKey      = "terminal.webpush.vapid"
+	defaultSubscriber = "vibego@localhost"
+	webPushTTL        = int(time.Hour / time.Second)
+)
+
+type vapidKeys struct {
+	Public  string `json:"public"`
+	Private string `json:"private"`
+}
+
+// WebPushSink delivers notifications through the browser Push API.
+type WebPushSink struct {
+	db         *gorm.DB
+	subscriber string
+
+	mu   sync.Mutex
+	keys *vapidKeys
+}
+
+// NewWebPushSink returns a sink storing its subscriptions in db. subscriber
+// is the contact e-mail or https URL sent to push services.
+func NewWebPushSink(db *gorm.DB, subscriber string) *WebPushSink {
+	if subscriber == "" {
+		subscriber = defaultSubscriber
+	}
+	return &WebPushSink{db: db, subscriber: subscriber}
+}
+
+// vapidKeys loads the VAPID keys, creating them when none are stored yet.
+func (s *WebPushSink) vapidKeys() (*vapidKeys, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.keys != nil {
+		return s.keys, nil
+	}
+
+	var keys vapidKeys
+	store := kv.New(s.db)
+	value, err := store.Get(vapidKeysKey)
+	switch {
+	case err == nil:
+		if err := json.Unmarshal([]byte(value), &keys); err != nil {
+			return nil, err
+		}
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return nil, err
+	default:
+		private, public, err := webpush.GenerateVAPIDKeys()
+		if err != nil {
+			return nil, err
+		}
+		keys = vapidKeys{Public: public, Private: private}
+		value, _ := json.Marshal(keys)
+		if err := store.Set(vapidKeysKey, string(value)); err != nil {
+			return nil, err
+		}
+	}
+	s.keys = &keys
+	return s.keys, nil
+}
+
+// PublicKey returns the VAPID application server key browsers subscribe
+// with.
+func (s *WebPushSink) PublicKey() (string, error) {
+	keys, err := s.vapidKeys()
+	if err != nil {
+		return "", err
+	}
+	return keys.Public, nil
+}
+
+// Subscribe stores a push subscription for userID. Subscriptions without a
+// user receive every user's notifications.
+func (s *WebPushSink) Subscribe(userID, endpoint, p256dh, auth string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("endpoint = ?", endpoint).Delete(&model.PushSubscription{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&model.PushSubscription{
+			ID:        uuid.New().String(),
+			UserID:    userID,
+			Endpoint:  endpoint,
+			P256dh:    p256dh,
+			Auth:      auth,
+			CreatedAt: time.Now().Unix(),
+		}).Error
+	})
+}
+
+func (s *WebPushSink) Unsubscribe(endpoint string) error {
+	return s.db.Where("endpoint = ?", endpoint).Delete(&model.PushSubscription{}).Error
+}
+
+// Notify pushes n to the subscriptions of its owner and removes those the
+// push service reports as gone.
+func (s *WebPushSink) Notify(ctx context.Context, n *Notification) error {
+	var subs []model.PushSubscription
+	query := s.db.WithContext(ctx)
+	if n.UserID != "" {
+		query = query.Where("user_id = ? OR user_id = ''", n.UserID)
+	}
+	if err := query.Find(&subs).Error; err != nil || len(subs) == 0 {
+		return err
+	}
+	keys, err := s.vapidKeys()
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	urgency := webpush.UrgencyNormal
+	if n.Kind == NotifyAwaitingInput {
+		urgency = webpush.UrgencyHigh
+	}
+
+	var errs []error
+	for _, sub := range subs {
+		resp, err := webpush.SendNotificationWithContext(ctx, payload, &webpush.Subscription{
+			Endpoint: sub.Endpoint,
+			Keys:     webpush.Keys{Auth: sub.Auth, P256dh: sub.P256dh},
+		}, &webpush.Options{
+			Subscriber:      s.subscriber,
+			TTL:             webPushTTL,
+			Urgency:         urgency,
+			VAPIDPublicKey:  keys.Public,
+			VAPIDPrivateKey: keys.Private,
+		})
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		resp.Body.Close()
+		switch {
+		case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+			s.db.Delete(&sub)
+		case resp.StatusCode >= 300:
+			errs = append(errs, fmt.Errorf("push to %s returned %s", sub.Endpoint, resp.Status))
+		}
+	}
+	return errors.Join(errs...)
+}
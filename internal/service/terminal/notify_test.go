@@ -0,0 +1,227 @@
+package terminal
+
+import (
+	"context"
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xxnuo/vibego/internal/model"
+	"github.com/xxnuo/vibego/internal/service/kv"
+	"gorm.io/gorm"
+)
+
+type chanSink chan *Notification
+
+func (s chanSink) Notify(ctx context.Context, n *Notification) error {
+	s <- n
+	return nil
+}
+
+func waitNotification(t *testing.T, ch <-chan *Notification) *Notification {
+	t.Helper()
+	select {
+	case n := <-ch:
+		return n
+	case <-time.After(3 * time.Second):
+		t.Fatal("timed out waiting for notification")
+		return nil
+	}
+}
+
+func TestStripANSI(t *testing.T) {
+	in := "\x1b[1;31mred\x1b[0m\r\n\x1b]0;title\x07\x1b(Bplain\ttab\x1b]8;;http://x\x1b\\link"
+	if got := string(stripANSI([]byte(in))); got != "red\nplain\ttablink" {
+		t.Errorf("unexpected stripped output %q", got)
+	}
+}
+
+func TestManager_NotifyBellAndPrompt(t *testing.T) {
+	db := setupTestDB(t)
+	sink := make(chanSink, 8)
+	manager := NewManager(db, &ManagerConfig{Shell: "/bin/sh", NotifySinks: []NotificationSink{sink}})
+	stream, cancel := manager.Notifications().Subscribe()
+	defer cancel()
+
+	info, err := manager.Create(CreateOptions{
+		Name:    "agent",
+		Cwd:     os.TempDir(),
+		Command: "/bin/sh",
+		Args:    []string{"-c", `printf 'x\a\a\r\n'; sleep 0.1; printf '\033[1mDo you want to proceed?\033[0m [y/n] '; sleep 0.5`},
+	})
+	if err != nil {
+		t.Fatalf("failed to create terminal: %v", err)
+	}
+	defer manager.Close(info.ID)
+
+	bell := waitNotification(t, stream)
+	if bell.Kind != NotifyBell || bell.SessionID != info.ID || bell.Title != "agent" || bell.ID == "" {
+		t.Errorf("unexpected bell notification %+v", bell)
+	}
+	prompt := waitNotification(t, stream)
+	if prompt.Kind != NotifyAwaitingInput || prompt.Message != "Do you want to proceed? [y/n]" {
+		t.Errorf("unexpected prompt notification %+v", prompt)
+	}
+
+	if n := waitNotification(t, sink); n != bell {
+		t.Errorf("expected sink to receive the bell first, got %+v", n)
+	}
+	waitNotification(t, sink)
+	select {
+	case n := <-sink:
+		t.Errorf("expected repeated bell to be rate limited, got %+v", n)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestManager_NotifyLongCommand(t *testing.T) {
+	if _, err := os.Stat("/bin/bash"); err != nil {
+		t.Skip("bash not available")
+	}
+
+	db := setupTestDB(t)
+	manager := NewManager(db, &ManagerConfig{Shell: "/bin/sh", LongCommandThreshold: 300 * time.Millisecond})
+	stream, cancel := manager.Notifications().Subscribe()
+	defer cancel()
+
+	info, err := manager.Create(CreateOptions{
+		Cwd:              os.TempDir(),
+		Command:          "/bin/bash",
+		Env:              map[string]string{"HOME": t.TempDir()},
+		ShellIntegration: true,
+	})
+	if err != nil {
+		t.Fatalf("failed to create terminal: %v", err)
+	}
+	defer manager.Close(info.ID)
+
+	at, _ := manager.getActive(info.ID)
+	time.Sleep(300 * time.Millisecond)
+	at.PTY.Write([]byte("true\n"))
+	time.Sleep(200 * time.Millisecond)
+	at.PTY.Write([]byte("sleep 0.4; false\n"))
+
+	n := waitNotification(t, stream)
+	if n.Kind != NotifyCommandFinished || n.Command != "sleep 0.4; false" || n.ExitCode != 1 || n.Duration < 0.3 {
+		t.Errorf("unexpected notification %+v", n)
+	}
+}
+
+func TestWebhookSink(t *testing.T) {
+	var received Notification
+	var token string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token = r.Header.Get("Authorization")
+		json.NewDecoder(r.Body).Decode(&received)
+		if received.Kind == NotifyBell {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	sink := &WebhookSink{URL: server.URL, Header: http.Header{"Authorization": {"Bearer secret"}}}
+	n := &Notification{ID: "n1", Kind: NotifyAwaitingInput, SessionID: "s1", Message: "Continue? [y/n]"}
+	if err := sink.Notify(context.Background(), n); err != nil {
+		t.Fatalf("Notify failed: %v", err)
+	}
+	if received != *n || token != "Bearer secret" {
+		t.Errorf("unexpected request %+v with token %q", received, token)
+	}
+
+	if err := sink.Notify(context.Background(), &Notification{Kind: NotifyBell}); err == nil {
+		t.Error("expected error for a failing webhook")
+	}
+}
+
+func TestWebPushSink(t *testing.T) {
+	db := setupTestDB(t)
+	db.Where("1 = 1").Delete(&model.PushSubscription{})
+	db.Where("key = ?", vapidKeysKey).Delete(&model.KV{})
+	sink := NewWebPushSink(db, "")
+	if err := sink.Notify(context.Background(), &Notification{Kind: NotifyBell}); err != nil {
+		t.Fatalf("Notify without subscriptions failed: %v", err)
+	}
+	if _, err := kv.New(db).Get(vapidKeysKey); err == nil {
+		t.Error("expected VAPID keys to be generated only when needed")
+	}
+	key, err := sink.PublicKey()
+	if err != nil {
+		t.Fatalf("PublicKey failed: %v", err)
+	}
+	if again, _ := NewWebPushSink(db, "").PublicKey(); key == "" || again != key {
+		t.Error("expected VAPID keys to be generated once and reused")
+	}
+
+	failing := true
+	db.Callback().Query().Before("gorm:query").Register("test:fail_kv", func(tx *gorm.DB) {
+		if failing {
+			tx.AddError(errors.New("database unavailable"))
+		}
+	})
+	if _, err := NewWebPushSink(db, "").PublicKey(); err == nil {
+		t.Error("expected a failed key lookup to be returned")
+	}
+	failing = false
+	if again, _ := NewWebPushSink(db, "").PublicKey(); again != key {
+		t.Error("expected a failed key lookup to keep the stored keys")
+	}
+
+	type push struct {
+		path, auth, encoding, urgency string
+		size                          int
+	}
+	pushes := make(chan push, 4)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		pushes <- push{r.URL.Path, r.Header.Get("Authorization"), r.Header.Get("Content-Encoding"), r.Header.Get("Urgency"), len(body)}
+		if r.URL.Path == "/gone" {
+			w.WriteHeader(http.StatusGone)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	browserKey, _ := ecdh.P256().GenerateKey(rand.Reader)
+	p256dh := base64.RawURLEncoding.EncodeToString(browserKey.PublicKey().Bytes())
+	secret := make([]byte, 16)
+	rand.Read(secret)
+	auth := base64.RawURLEncoding.EncodeToString(secret)
+
+	if err := sink.Subscribe("", server.URL+"/live", p256dh, auth); err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	sink.Subscribe("", server.URL+"/gone", p256dh, auth)
+	sink.Subscribe("someone-else", server.URL+"/other", p256dh, auth)
+
+	if err := sink.Notify(context.Background(), &Notification{Kind: NotifyAwaitingInput, UserID: "owner"}); err != nil {
+		t.Fatalf("Notify failed: %v", err)
+	}
+	close(pushes)
+
+	paths := map[string]bool{}
+	for p := range pushes {
+		paths[p.path] = true
+		if !strings.HasPrefix(p.auth, "vapid t=") || p.encoding != "aes128gcm" || p.urgency != "high" || p.size == 0 {
+			t.Errorf("unexpected push request %+v", p)
+		}
+	}
+	if !paths["/live"] || !paths["/gone"] || paths["/other"] {
+		t.Errorf("expected pushes to the owner's and shared subscriptions only, got %v", paths)
+	}
+
+	var count int64
+	db.Model(&model.PushSubscription{}).Count(&count)
+	if count != 2 {
+		t.Errorf("expected gone subscription to be removed, %d left", count)
+	}
+}
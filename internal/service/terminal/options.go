@@ -3,6 +3,8 @@ package terminal
 import (
 	"io"
 	"time"
+
+	"github.com/xxnuo/vibego/internal/model"
 )
 
 type webTTYOption func(*webTTY)
@@ -37,6 +39,26 @@ func withHistoryWriter(writer io.Writer) webTTYOption {
 	}
 }
 
+func withBinary(binary bool) webTTYOption {
+	return func(wt *webTTY) {
+		wt.binary = binary
+	}
+}
+
+// withOnResize hands client resize requests to callback instead of
+// resizing the slave directly.
+func withOnResize(callback func(cols, rows int)) webTTYOption {
+	return func(wt *webTTY) {
+		wt.onResize = callback
+	}
+}
+
+func withOnInput(callback func()) webTTYOption {
+	return func(wt *webTTY) {
+		wt.onInput = callback
+	}
+}
+
 func withSkipSlaveReadLoop(skip bool) webTTYOption {
 	return func(wt *webTTY) {
 		wt.skipSlaveReadLoop = skip
@@ -50,3 +72,41 @@ func withCloseTimeout(timeout time.Duration) localCommandOption {
 		lc.closeTimeout = timeout
 	}
 }
+
+// withBaseEnv sets the inherited environment. Without it the server's
+// environment minus DefaultEnvDeny is used.
+func withBaseEnv(env []string) localCommandOption {
+	return func(lc *localCommand) {
+		lc.baseEnv = env
+	}
+}
+
+func withEnv(env []string) localCommandOption {
+	return func(lc *localCommand) {
+		lc.env = env
+	}
+}
+
+// withLimits starts the process through the sandbox launcher when limits
+// restrict anything. The process joins cgroup if it is set.
+func withLimits(limits *model.TerminalLimits, cgroup string) localCommandOption {
+	return func(lc *localCommand) {
+		if limited(limits) {
+			lc.sandbox = &sandboxSpec{Limits: *limits, Cgroup: cgroup}
+		}
+	}
+}
+
+func withShellIntegration(enabled bool) localCommandOption {
+	return func(lc *localCommand) {
+		lc.shellIntegration = enabled
+	}
+}
+
+func withTerm(term string) localCommandOption {
+	return func(lc *localCommand) {
+		if term != "" {
+			lc.term = term
+		}
+	}
+}
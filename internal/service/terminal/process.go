@@ -0,0 +1,199 @@
+package terminal
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/xxnuo/vibego/internal/model"
+)
+
+// clockTicks is USER_HZ, the unit of the times in /proc/<pid>/stat. It is
+// fixed at 100 on every Linux architecture.
+const clockTicks = 100
+
+const procDir = "/proc"
+
+// ProcessInfo describes a process in a terminal's process tree. CPUPercent
+// is averaged over the lifetime of the process.
+type ProcessInfo struct {
+	PID        int            `json:"pid"`
+	PPID       int            `json:"ppid"`
+	PGID       int            `json:"pgid"`
+	Command    string         `json:"command"`
+	Cmdline    []string       `json:"cmdline"`
+	State      string         `json:"state"`
+	Foreground bool           `json:"foreground"`
+	CPUTime    float64        `json:"cpu_time"`
+	CPUPercent float64        `json:"cpu_percent"`
+	RSS        int64          `json:"rss"`
+	StartTime  int64          `json:"start_time"`
+	Children   []*ProcessInfo `json:"children,omitempty"`
+}
+
+// processOwner is implemented by slaves backed by a local process.
+type processOwner interface {
+	Pid() int
+	ForegroundProcessGroup() (int, error)
+}
+
+// parseSignal accepts signal names with or without the SIG prefix.
+func parseSignal(name string) (syscall.Signal, error) {
+	name = strings.ToUpper(strings.TrimSpace(name))
+	if !strings.HasPrefix(name, "SIG") {
+		name = "SIG" + name
+	}
+	sig, ok := terminalSignals[name]
+	if !ok {
+		return 0, ErrInvalidSignal
+	}
+	return sig, nil
+}
+
+func (m *Manager) processOwner(id string) (processOwner, error) {
+	at, ok := m.getActive(id)
+	if !ok {
+		return nil, ErrTerminalNotFound
+	}
+	if at.ptyStatus.Load() == model.PTYStatusExited {
+		return nil, ErrTerminalExited
+	}
+	owner, ok := at.PTY.(processOwner)
+	if !ok {
+		return nil, ErrNoProcess
+	}
+	return owner, nil
+}
+
+// Signal sends the named signal to a terminal's foreground process group and
+// returns the group.
+func (m *Manager) Signal(id, name string) (int, error) {
+	sig, err := parseSignal(name)
+	if err != nil {
+		return 0, err
+	}
+	owner, err := m.processOwner(id)
+	if err != nil {
+		return 0, err
+	}
+
+	return signalForeground(owner, sig)
+}
+
+// Processes returns the process tree rooted at a terminal's process.
+func (m *Manager) Processes(id string) (*ProcessInfo, error) {
+	owner, err := m.processOwner(id)
+	if err != nil {
+		return nil, err
+	}
+	foreground, _ := owner.ForegroundProcessGroup()
+	return readProcessTree(owner.Pid(), foreground)
+}
+
+func readProcessTree(root, foreground int) (*ProcessInfo, error) {
+	entries, err := os.ReadDir(procDir)
+	if err != nil {
+		return nil, ErrProcessTreeUnsupported
+	}
+	bootTime := readBootTime()
+	now := time.Now()
+
+	procs := make(map[int]*ProcessInfo)
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		// Processes may exit while the directory is scanned.
+		p, err := readProcess(pid, bootTime, now)
+		if err != nil {
+			continue
+		}
+		p.Foreground = foreground > 0 && p.PGID == foreground
+		procs[pid] = p
+	}
+
+	rootInfo, ok := procs[root]
+	if !ok {
+		return nil, ErrTerminalExited
+	}
+	for pid, p := range procs {
+		if parent, ok := procs[p.PPID]; ok && pid != root {
+			parent.Children = append(parent.Children, p)
+		}
+	}
+	for _, p := range procs {
+		sort.Slice(p.Children, func(i, j int) bool { return p.Children[i].PID < p.Children[j].PID })
+	}
+	return rootInfo, nil
+}
+
+func readProcess(pid int, bootTime int64, now time.Time) (*ProcessInfo, error) {
+	dir := filepath.Join(procDir, strconv.Itoa(pid))
+	stat, err := os.ReadFile(filepath.Join(dir, "stat"))
+	if err != nil {
+		return nil, err
+	}
+
+	// The command name is in parentheses and may itself contain spaces or
+	// parentheses, so the fields are split after the last one.
+	open, end := bytes.IndexByte(stat, '('), bytes.LastIndexByte(stat, ')')
+	if open < 0 || end < open {
+		return nil, ErrProcessTreeUnsupported
+	}
+	fields := strings.Fields(string(stat[end+1:]))
+	if len(fields) < 22 {
+		return nil, ErrProcessTreeUnsupported
+	}
+	field := func(i int) int64 {
+		v, _ := strconv.ParseInt(fields[i], 10, 64)
+		return v
+	}
+
+	p := &ProcessInfo{
+		PID:     pid,
+		PPID:    int(field(1)),
+		PGID:    int(field(2)),
+		Command: string(stat[open+1 : end]),
+		State:   fields[0],
+		CPUTime: float64(field(11)+field(12)) / clockTicks,
+		RSS:     field(21) * int64(os.Getpagesize()),
+	}
+
+	started := float64(bootTime) + float64(field(19))/clockTicks
+	p.StartTime = int64(started)
+	if elapsed := float64(now.UnixNano())/1e9 - started; elapsed > 0 {
+		p.CPUPercent = p.CPUTime / elapsed * 100
+	}
+
+	if cmdline, err := os.ReadFile(filepath.Join(dir, "cmdline")); err == nil {
+		cmdline = bytes.TrimRight(cmdline, "\x00")
+		if len(cmdline) > 0 {
+			p.Cmdline = strings.Split(string(cmdline), "\x00")
+		}
+	}
+	return p, nil
+}
+
+func readBootTime() int64 {
+	file, err := os.Open(filepath.Join(procDir, "stat"))
+	if err != nil {
+		return 0
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if v, ok := strings.CutPrefix(scanner.Text(), "btime "); ok {
+			bootTime, _ := strconv.ParseInt(v, 10, 64)
+			return bootTime
+		}
+	}
+	return 0
+}
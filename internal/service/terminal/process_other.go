@@ -0,0 +1,13 @@
+//go:build !unix
+
+package terminal
+
+import "syscall"
+
+// terminalSignals is empty: process groups cannot be signaled on this
+// platform.
+var terminalSignals = map[string]syscall.Signal{}
+
+func signalForeground(owner processOwner, sig syscall.Signal) (int, error) {
+	return 0, ErrInvalidSignal
+}
@@ -0,0 +1,182 @@
+package terminal
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func findProcess(p *ProcessInfo, command string) *ProcessInfo {
+	if p.Command == command {
+		return p
+	}
+	for _, child := range p.Children {
+		if found := findProcess(child, command); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+func TestParseSignal(t *testing.T) {
+	for _, name := range []string{"SIGINT", "int", " sigtstp "} {
+		if _, err := parseSignal(name); err != nil {
+			t.Errorf("expected %q to be accepted: %v", name, err)
+		}
+	}
+	if _, err := parseSignal("SIGUSR1"); err != ErrInvalidSignal {
+		t.Errorf("expected ErrInvalidSignal, got %v", err)
+	}
+}
+
+func TestManager_Processes(t *testing.T) {
+	if _, err := os.Stat("/proc/self/stat"); err != nil {
+		t.Skip("/proc not available")
+	}
+
+	db := setupTestDB(t)
+	manager := NewManager(db, &ManagerConfig{Shell: "/bin/sh"})
+
+	info, err := manager.Create(CreateOptions{
+		Cwd:     os.TempDir(),
+		Command: "/bin/sh",
+		Args:    []string{"-c", "sleep 30 & sleep 31; wait"},
+	})
+	if err != nil {
+		t.Fatalf("failed to create terminal: %v", err)
+	}
+	defer manager.Close(info.ID)
+
+	var tree *ProcessInfo
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		tree, err = manager.Processes(info.ID)
+		if err != nil {
+			t.Fatalf("Processes failed: %v", err)
+		}
+		// A child is caught as sh until it has executed sleep.
+		if len(tree.Children) == 2 && tree.Children[0].Command == "sleep" && tree.Children[1].Command == "sleep" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected two children, got %+v", tree.Children)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	if tree.Command != "sh" || tree.Cmdline[0] != "/bin/sh" || tree.RSS <= 0 || tree.StartTime <= 0 {
+		t.Errorf("unexpected root process %+v", tree)
+	}
+	if !tree.Foreground {
+		t.Error("expected the shell's group to own the terminal")
+	}
+	for i, child := range tree.Children {
+		if child.Command != "sleep" || child.PPID != tree.PID || len(child.Cmdline) != 2 {
+			t.Errorf("unexpected child %d: %+v", i, child)
+		}
+	}
+
+	if _, err := manager.Processes("missing"); err != ErrTerminalNotFound {
+		t.Errorf("expected ErrTerminalNotFound, got %v", err)
+	}
+}
+
+func TestManager_SignalInterrupt(t *testing.T) {
+	db := setupTestDB(t)
+	manager := NewManager(db, &ManagerConfig{Shell: "/bin/sh"})
+
+	info, err := manager.Create(CreateOptions{
+		Cwd:     os.TempDir(),
+		Command: "/bin/sh",
+		Args:    []string{"-c", "sleep 30; true"},
+	})
+	if err != nil {
+		t.Fatalf("failed to create terminal: %v", err)
+	}
+	defer manager.Close(info.ID)
+	time.Sleep(100 * time.Millisecond)
+
+	if _, err := manager.Signal(info.ID, "SIGUSR1"); err != ErrInvalidSignal {
+		t.Errorf("expected ErrInvalidSignal, got %v", err)
+	}
+	if _, err := manager.Signal(info.ID, "INT"); err != nil {
+		t.Fatalf("Signal failed: %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		term, _ := manager.Get(info.ID)
+		if term.PTYStatus == "exited" {
+			if term.ExitSignal != "SIGINT" {
+				t.Errorf("expected exit by SIGINT, got code %d signal %q", term.ExitCode, term.ExitSignal)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("process did not exit after SIGINT")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	if _, err := manager.Signal(info.ID, "SIGTERM"); err != ErrTerminalExited {
+		t.Errorf("expected ErrTerminalExited, got %v", err)
+	}
+}
+
+func TestManager_SignalForegroundJob(t *testing.T) {
+	if _, err := os.Stat("/bin/bash"); err != nil {
+		t.Skip("bash not available")
+	}
+	if _, err := os.Stat("/proc/self/stat"); err != nil {
+		t.Skip("/proc not available")
+	}
+
+	db := setupTestDB(t)
+	manager := NewManager(db, &ManagerConfig{Shell: "/bin/sh"})
+
+	info, err := manager.Create(CreateOptions{
+		Cwd:     os.TempDir(),
+		Command: "/bin/bash",
+		Args:    []string{"--norc", "-i"},
+	})
+	if err != nil {
+		t.Fatalf("failed to create terminal: %v", err)
+	}
+	defer manager.Close(info.ID)
+	time.Sleep(200 * time.Millisecond)
+	manager.WriteInput(info.ID, []byte("sleep 30\n"))
+
+	var sleep *ProcessInfo
+	deadline := time.Now().Add(2 * time.Second)
+	for sleep == nil || !sleep.Foreground {
+		if time.Now().After(deadline) {
+			t.Fatalf("sleep did not become the foreground job: %+v", sleep)
+		}
+		time.Sleep(20 * time.Millisecond)
+		tree, _ := manager.Processes(info.ID)
+		sleep = findProcess(tree, "sleep")
+	}
+
+	pgid, err := manager.Signal(info.ID, "SIGTSTP")
+	if err != nil {
+		t.Fatalf("Signal failed: %v", err)
+	}
+	if pgid != sleep.PGID {
+		t.Errorf("expected signal to reach the job's group %d, got %d", sleep.PGID, pgid)
+	}
+
+	deadline = time.Now().Add(2 * time.Second)
+	for {
+		tree, _ := manager.Processes(info.ID)
+		if tree.Foreground {
+			if stopped := findProcess(tree, "sleep"); stopped == nil || stopped.State != "T" {
+				t.Errorf("expected sleep to be stopped, got %+v", stopped)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("shell did not regain the terminal after SIGTSTP")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
@@ -0,0 +1,31 @@
+//go:build unix
+
+package terminal
+
+import "syscall"
+
+// terminalSignals are the signals that may be sent to a terminal's
+// foreground process group.
+var terminalSignals = map[string]syscall.Signal{
+	"SIGINT":  syscall.SIGINT,
+	"SIGTERM": syscall.SIGTERM,
+	"SIGHUP":  syscall.SIGHUP,
+	"SIGKILL": syscall.SIGKILL,
+	"SIGTSTP": syscall.SIGTSTP,
+}
+
+// signalForeground sends sig to owner's foreground process group, falling
+// back to the group of owner's process.
+func signalForeground(owner processOwner, sig syscall.Signal) (int, error) {
+	pgid, err := owner.ForegroundProcessGroup()
+	if err != nil || pgid <= 0 {
+		pgid, err = syscall.Getpgid(owner.Pid())
+		if err != nil {
+			return 0, err
+		}
+	}
+	if err := syscall.Kill(-pgid, sig); err != nil {
+		return 0, err
+	}
+	return pgid, nil
+}
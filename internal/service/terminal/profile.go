@@ -0,0 +1,269 @@
+package terminal
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/xxnuo/vibego/internal/model"
+	"gorm.io/gorm"
+)
+
+const (
+	builtinShellPrefix = "builtin-shell-"
+	builtinAIPrefix    = "builtin-ai-"
+)
+
+// aiCLIs lists the agent CLIs offered as built-in profiles when found on PATH.
+var aiCLIs = []struct {
+	Name    string
+	Program string
+}{
+	{"Claude Code", "claude"},
+	{"Gemini CLI", "gemini"},
+	{"Codex", "codex"},
+	{"Aider", "aider"},
+	{"OpenCode", "opencode"},
+	{"Qwen Code", "qwen"},
+}
+
+type ProfileInfo struct {
+	ID        string                `json:"id"`
+	Name      string                `json:"name"`
+	Program   string                `json:"program"`
+	Args      []string              `json:"args"`
+	Env       map[string]string     `json:"env"`
+	Cwd       string                `json:"cwd"`
+	Icon      string                `json:"icon"`
+	Limits    *model.TerminalLimits `json:"limits,omitempty"`
+	Builtin   bool                  `json:"builtin"`
+	CreatedAt int64                 `json:"created_at"`
+	UpdatedAt int64                 `json:"updated_at"`
+}
+
+type ProfileOptions struct {
+	Name    string
+	Program string
+	Args    []string
+	Env     map[string]string
+	Cwd     string
+	Icon    string
+	Limits  *model.TerminalLimits
+	UserID  string
+}
+
+func profileToInfo(p *model.TerminalProfile) *ProfileInfo {
+	return &ProfileInfo{
+		ID:        p.ID,
+		Name:      p.Name,
+		Program:   p.Program,
+		Args:      p.Args,
+		Env:       p.Env,
+		Cwd:       p.Cwd,
+		Icon:      p.Icon,
+		Limits:    p.Limits,
+		CreatedAt: p.CreatedAt,
+		UpdatedAt: p.UpdatedAt,
+	}
+}
+
+// builtinProfiles detects the shells in /etc/shells and the AI CLIs on PATH.
+func builtinProfiles() []ProfileInfo {
+	var profiles []ProfileInfo
+	seen := make(map[string]bool)
+
+	if f, err := os.Open("/etc/shells"); err == nil {
+		defer f.Close()
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			name := filepath.Base(line)
+			if seen[name] {
+				continue
+			}
+			if st, err := os.Stat(line); err != nil || st.IsDir() {
+				continue
+			}
+			seen[name] = true
+			profiles = append(profiles, ProfileInfo{
+				ID:      builtinShellPrefix + name,
+				Name:    name,
+				Program: line,
+				Icon:    name,
+				Builtin: true,
+			})
+		}
+	}
+
+	for _, cli := range aiCLIs {
+		path, err := exec.LookPath(cli.Program)
+		if err != nil {
+			continue
+		}
+		profiles = append(profiles, ProfileInfo{
+			ID:      builtinAIPrefix + cli.Program,
+			Name:    cli.Name,
+			Program: path,
+			Icon:    cli.Program,
+			Builtin: true,
+		})
+	}
+
+	return profiles
+}
+
+func isBuiltinProfile(id string) bool {
+	return strings.HasPrefix(id, builtinShellPrefix) || strings.HasPrefix(id, builtinAIPrefix)
+}
+
+// ListProfiles returns the built-in profiles followed by the saved profiles
+// of userID and those shared by every user.
+func (m *Manager) ListProfiles(userID string) ([]ProfileInfo, error) {
+	var profiles []model.TerminalProfile
+	if err := m.ownedBy(userID).Order("created_at ASC").Find(&profiles).Error; err != nil {
+		return nil, err
+	}
+	result := builtinProfiles()
+	for i := range profiles {
+		result = append(result, *profileToInfo(&profiles[i]))
+	}
+	return result, nil
+}
+
+// GetProfile returns a profile userID may use. Profiles of other users are
+// not found.
+func (m *Manager) GetProfile(id, userID string) (*ProfileInfo, error) {
+	if isBuiltinProfile(id) {
+		for _, p := range builtinProfiles() {
+			if p.ID == id {
+				return &p, nil
+			}
+		}
+		return nil, ErrProfileNotFound
+	}
+
+	var profile model.TerminalProfile
+	if err := m.ownedBy(userID).First(&profile, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrProfileNotFound
+		}
+		return nil, err
+	}
+	return profileToInfo(&profile), nil
+}
+
+func (m *Manager) CreateProfile(opts ProfileOptions) (*ProfileInfo, error) {
+	if err := validateLimits(opts.Limits); err != nil {
+		return nil, err
+	}
+	now := time.Now().Unix()
+	profile := &model.TerminalProfile{
+		ID:        uuid.New().String(),
+		UserID:    opts.UserID,
+		Name:      opts.Name,
+		Program:   opts.Program,
+		Args:      opts.Args,
+		Env:       opts.Env,
+		Cwd:       opts.Cwd,
+		Icon:      opts.Icon,
+		Limits:    opts.Limits,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := m.db.Create(profile).Error; err != nil {
+		return nil, err
+	}
+	return profileToInfo(profile), nil
+}
+
+func (m *Manager) UpdateProfile(id string, opts ProfileOptions) (*ProfileInfo, error) {
+	if isBuiltinProfile(id) {
+		return nil, ErrProfileReadOnly
+	}
+	if err := validateLimits(opts.Limits); err != nil {
+		return nil, err
+	}
+
+	var profile model.TerminalProfile
+	if err := m.ownedBy(opts.UserID).First(&profile, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrProfileNotFound
+		}
+		return nil, err
+	}
+
+	profile.Name = opts.Name
+	profile.Program = opts.Program
+	profile.Args = opts.Args
+	profile.Env = opts.Env
+	profile.Cwd = opts.Cwd
+	profile.Icon = opts.Icon
+	profile.Limits = opts.Limits
+	profile.UpdatedAt = time.Now().Unix()
+
+	if err := m.db.Save(&profile).Error; err != nil {
+		return nil, err
+	}
+	return profileToInfo(&profile), nil
+}
+
+func (m *Manager) DeleteProfile(id, userID string) error {
+	if isBuiltinProfile(id) {
+		return ErrProfileReadOnly
+	}
+	result := m.ownedBy(userID).Where("id = ?", id).Delete(&model.TerminalProfile{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrProfileNotFound
+	}
+	return nil
+}
+
+// applyProfile fills unset create options from the given profile. Values set
+// explicitly on the request take precedence.
+func (m *Manager) applyProfile(opts *CreateOptions) error {
+	if opts.ProfileID == "" {
+		return nil
+	}
+	p, err := m.GetProfile(opts.ProfileID, opts.UserID)
+	if err != nil {
+		return err
+	}
+
+	if opts.Name == "" {
+		opts.Name = p.Name
+	}
+	if opts.Command == "" {
+		opts.Command = p.Program
+		if opts.Args == nil {
+			opts.Args = p.Args
+		}
+	}
+	if opts.Cwd == "" {
+		opts.Cwd = p.Cwd
+	}
+	if opts.Limits == nil {
+		opts.Limits = p.Limits
+	}
+	if len(p.Env) > 0 {
+		env := make(map[string]string, len(p.Env)+len(opts.Env))
+		for k, v := range p.Env {
+			env[k] = v
+		}
+		for k, v := range opts.Env {
+			env[k] = v
+		}
+		opts.Env = env
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package terminal
+
+import (
+	"os"
+	"testing"
+
+	"github.com/xxnuo/vibego/internal/model"
+)
+
+func TestBuiltinProfiles_DetectsShells(t *testing.T) {
+	if _, err := os.Stat("/etc/shells"); err != nil {
+		t.Skip("/etc/shells not available")
+	}
+
+	profiles := builtinProfiles()
+	if len(profiles) == 0 {
+		t.Fatal("expected at least one built-in profile")
+	}
+
+	seen := make(map[string]bool)
+	for _, p := range profiles {
+		if !p.Builtin {
+			t.Errorf("expected profile %s to be marked built-in", p.ID)
+		}
+		if !isBuiltinProfile(p.ID) {
+			t.Errorf("unexpected built-in profile ID %s", p.ID)
+		}
+		if seen[p.ID] {
+			t.Errorf("duplicate built-in profile ID %s", p.ID)
+		}
+		seen[p.ID] = true
+	}
+}
+
+func TestManager_ProfileCRUD(t *testing.T) {
+	db := setupTestDB(t)
+	manager := NewManager(db, &ManagerConfig{Shell: "/bin/sh"})
+
+	created, err := manager.CreateProfile(ProfileOptions{
+		Name:    "project",
+		Program: "/bin/sh",
+		Args:    []string{"-l"},
+		Env:     map[string]string{"PROJECT": "vibego"},
+		Cwd:     os.TempDir(),
+		Icon:    "folder",
+		UserID:  "alice",
+	})
+	if err != nil {
+		t.Fatalf("failed to create profile: %v", err)
+	}
+	defer manager.DeleteProfile(created.ID, "alice")
+
+	if _, err := manager.GetProfile(created.ID, "bob"); err != ErrProfileNotFound {
+		t.Errorf("expected another user's profile to be hidden, got %v", err)
+	}
+	if _, err := manager.UpdateProfile(created.ID, ProfileOptions{Name: "stolen", UserID: "bob"}); err != ErrProfileNotFound {
+		t.Errorf("expected another user's profile to be read-only, got %v", err)
+	}
+	if _, err := manager.Create(CreateOptions{ProfileID: created.ID, UserID: "bob"}); err != ErrProfileNotFound {
+		t.Errorf("expected another user's profile not to launch, got %v", err)
+	}
+
+	got, err := manager.GetProfile(created.ID, "alice")
+	if err != nil {
+		t.Fatalf("failed to get profile: %v", err)
+	}
+	if got.Env["PROJECT"] != "vibego" || len(got.Args) != 1 {
+		t.Errorf("unexpected profile contents: %+v", got)
+	}
+
+	updated, err := manager.UpdateProfile(created.ID, ProfileOptions{Name: "renamed", Program: "/bin/sh", UserID: "alice"})
+	if err != nil {
+		t.Fatalf("failed to update profile: %v", err)
+	}
+	if updated.Name != "renamed" {
+		t.Errorf("expected name 'renamed', got %s", updated.Name)
+	}
+
+	if _, err := manager.UpdateProfile(builtinShellPrefix+"sh", ProfileOptions{Name: "x"}); err != ErrProfileReadOnly {
+		t.Errorf("expected ErrProfileReadOnly, got %v", err)
+	}
+
+	if err := manager.DeleteProfile(created.ID, "bob"); err != ErrProfileNotFound {
+		t.Errorf("expected another user's profile to be kept, got %v", err)
+	}
+	if err := manager.DeleteProfile(created.ID, "alice"); err != nil {
+		t.Errorf("failed to delete profile: %v", err)
+	}
+	if _, err := manager.GetProfile(created.ID, "alice"); err != ErrProfileNotFound {
+		t.Errorf("expected ErrProfileNotFound, got %v", err)
+	}
+}
+
+func TestManager_CreateFromProfile(t *testing.T) {
+	db := setupTestDB(t)
+	manager := NewManager(db, &ManagerConfig{Shell: "/bin/sh"})
+
+	profile, err := manager.CreateProfile(ProfileOptions{
+		Name:    "sleeper",
+		Program: "/bin/sh",
+		Args:    []string{"-c", "sleep 5"},
+		Cwd:     os.TempDir(),
+	})
+	if err != nil {
+		t.Fatalf("failed to create profile: %v", err)
+	}
+	defer manager.DeleteProfile(profile.ID, "")
+
+	info, err := manager.Create(CreateOptions{ProfileID: profile.ID})
+	if err != nil {
+		t.Fatalf("failed to create terminal: %v", err)
+	}
+	defer manager.Close(info.ID)
+
+	if info.Name != "sleeper" || info.Cwd != os.TempDir() || len(info.Args) != 2 {
+		t.Errorf("expected profile values to be applied, got %+v", info)
+	}
+
+	var session model.TerminalSession
+	db.First(&session, "id = ?", info.ID)
+	if session.ProfileID != profile.ID {
+		t.Errorf("expected profile ID %s, got %s", profile.ID, session.ProfileID)
+	}
+
+	if _, err := manager.Create(CreateOptions{ProfileID: "missing"}); err != ErrProfileNotFound {
+		t.Errorf("expected ErrProfileNotFound, got %v", err)
+	}
+}
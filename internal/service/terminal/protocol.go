@@ -1,9 +1,41 @@
 package terminal
 
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+)
+
+// Clients pick a wire format through the WebSocket subprotocol. The binary
+// one carries terminal data in binary frames, control messages stay JSON.
+const (
+	SubprotocolJSON   = "vibego.json"
+	SubprotocolBinary = "vibego.binary"
+)
+
+// Binary frame types. Output frames carry the 8-byte big-endian stream
+// offset just past the data after the type byte.
+const (
+	BinaryFrameInput  byte = 0x00
+	BinaryFrameOutput byte = 0x01
+)
+
 const (
 	MsgTypeCmd       = "cmd"
 	MsgTypeResize    = "resize"
 	MsgTypeHeartbeat = "heartbeat"
+	MsgTypeExit      = "exit"
+	MsgTypeGap       = "gap"
+
+	MsgTypeCommandStart = "command_start"
+	MsgTypeCommandEnd   = "command_end"
+
+	MsgTypeClientJoin  = "client_join"
+	MsgTypeClientLeave = "client_leave"
+
+	MsgTypeGroup = "group"
+
+	MsgTypeTrigger = "trigger"
 )
 
 type WSMessage struct {
@@ -12,9 +44,84 @@ type WSMessage struct {
 	Cols      int    `json:"cols,omitempty"`
 	Rows      int    `json:"rows,omitempty"`
 	Timestamp int64  `json:"timestamp,omitempty"`
+	// Offset is set on output frames to the stream offset just past the
+	// frame's data. Clients resume by attaching with since=<offset>.
+	Offset int64 `json:"offset,omitempty"`
 }
 
+// ResizeMessage is sent to clients when the effective terminal size changes
+// and to a client whose own resize request was overruled.
 type ResizeMessage struct {
-	Cols int `json:"cols"`
-	Rows int `json:"rows"`
+	Type string `json:"type,omitempty"`
+	Cols int    `json:"cols"`
+	Rows int    `json:"rows"`
+}
+
+// ExitMessage is pushed to every attached client once the terminal process
+// has exited. Signal is set when the process was terminated by a signal.
+type ExitMessage struct {
+	Type     string `json:"type"`
+	ExitCode int    `json:"exit_code"`
+	Signal   string `json:"signal,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// GapMessage tells a client that the bytes between Since and Offset are gone.
+type GapMessage struct {
+	Type   string `json:"type"`
+	Since  int64  `json:"since"`
+	Offset int64  `json:"offset"`
+}
+
+// CommandMessage reports a command lifecycle change detected through shell
+// integration.
+type CommandMessage struct {
+	Type     string `json:"type"`
+	Command  string `json:"command"`
+	ExitCode int    `json:"exit_code"`
+	Cwd      string `json:"cwd,omitempty"`
+	Offset   int64  `json:"offset"`
+}
+
+// ClientMessage tells the other clients that a client joined or left.
+type ClientMessage struct {
+	Type   string     `json:"type"`
+	Client ClientInfo `json:"client"`
+	Reason string     `json:"reason,omitempty"`
+}
+
+// GroupMessage is sent to the clients of a broadcast group when they attach
+// and whenever the group's members change.
+type GroupMessage struct {
+	Type    string   `json:"type"`
+	Members []string `json:"members"`
+}
+
+// TriggerMessage reports an action taken by an output trigger.
+type TriggerMessage struct {
+	Type      string `json:"type"`
+	TriggerID string `json:"trigger_id"`
+	Name      string `json:"name,omitempty"`
+	Action    string `json:"action"`
+	Text      string `json:"text"`
+	Line      string `json:"line"`
+	URL       string `json:"url,omitempty"`
+	Offset    int64  `json:"offset"`
+}
+
+func encodeOutputJSON(data []byte, offset int64) []byte {
+	msgData, _ := json.Marshal(WSMessage{
+		Type:   MsgTypeCmd,
+		Data:   base64.StdEncoding.EncodeToString(data),
+		Offset: offset,
+	})
+	return msgData
+}
+
+func encodeOutputBinary(data []byte, offset int64) []byte {
+	frame := make([]byte, 9+len(data))
+	frame[0] = BinaryFrameOutput
+	binary.BigEndian.PutUint64(frame[1:9], uint64(offset))
+	copy(frame[9:], data)
+	return frame
 }
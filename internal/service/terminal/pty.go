@@ -2,31 +2,83 @@ package terminal
 
 import (
 	"context"
+	"errors"
 	"os"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/KennethanCeyer/ptyx"
 )
 
-const DefaultCloseTimeout = 10 * time.Second
+const (
+	DefaultCloseTimeout = 10 * time.Second
+	DefaultTerm         = "xterm-256color"
+)
 
 type localCommand struct {
-	command      string
-	argv         []string
-	cwd          string
-	session      ptyx.Session
-	ptyClosed    chan struct{}
-	closeTimeout time.Duration
-	mu           sync.Mutex
+	command          string
+	argv             []string
+	cwd              string
+	baseEnv          []string
+	env              []string
+	term             string
+	shellIntegration bool
+	sandbox          *sandboxSpec
+	session          ptyx.Session
+	ptyClosed        chan struct{}
+	closeTimeout     time.Duration
+	exitCode         int
+	exitSignal       string
+	mu               sync.Mutex
 }
 
 func newLocalCommand(shell string, args []string, cwd string, cols, rows int, opts ...localCommandOption) (*localCommand, error) {
+	lcmd := &localCommand{
+		command:      shell,
+		argv:         args,
+		cwd:          cwd,
+		term:         DefaultTerm,
+		ptyClosed:    make(chan struct{}),
+		closeTimeout: DefaultCloseTimeout,
+	}
+
+	for _, opt := range opts {
+		opt(lcmd)
+	}
+	if lcmd.shellIntegration {
+		if err := lcmd.injectShellIntegration(); err != nil {
+			return nil, err
+		}
+	}
+
+	base := lcmd.baseEnv
+	if base == nil {
+		base = (&EnvPolicy{}).inherit(os.Environ())
+	}
 	// Set PROMPT_EOL_MARK to empty to avoid the '%' char on Lines ending without newline (zsh feature)
-	env := append(os.Environ(), "TERM=xterm-256color", "PROMPT_EOL_MARK=")
+	env := append(base[:len(base):len(base)], "TERM="+lcmd.term, "PROMPT_EOL_MARK=")
+	// Later entries win, so per-terminal variables override the inherited ones.
+	env = append(env, lcmd.env...)
+
+	prog, args := lcmd.command, lcmd.argv
+	if spec := lcmd.sandbox; spec != nil {
+		spec.Command, spec.Args, spec.Workspace = lcmd.command, lcmd.argv, cwd
+		if lcmd.shellIntegration {
+			if dir, err := shellIntegrationDir(); err == nil {
+				spec.ReadOnly = append(spec.ReadOnly, dir)
+			}
+		}
+		exe, entry, err := spec.launcher()
+		if err != nil {
+			return nil, err
+		}
+		prog, args = exe, nil
+		env = append(env, entry)
+	}
 
 	spawnOpts := ptyx.SpawnOpts{
-		Prog: shell,
+		Prog: prog,
 		Args: args,
 		Env:  env,
 		Dir:  cwd,
@@ -38,31 +90,44 @@ func newLocalCommand(shell string, args []string, cwd string, cols, rows int, op
 	if err != nil {
 		return nil, err
 	}
+	lcmd.session = session
 
-	ptyClosed := make(chan struct{})
+	go func() {
+		lcmd.exitCode, lcmd.exitSignal = waitStatus(lcmd.session.Wait())
+		lcmd.mu.Lock()
+		defer lcmd.mu.Unlock()
+		lcmd.session.Close()
+		close(lcmd.ptyClosed)
+	}()
 
-	lcmd := &localCommand{
-		command:      shell,
-		argv:         args,
-		cwd:          cwd,
-		session:      session,
-		ptyClosed:    ptyClosed,
-		closeTimeout: DefaultCloseTimeout,
-	}
+	return lcmd, nil
+}
 
-	for _, opt := range opts {
-		opt(lcmd)
+// waitStatus converts the error of ptyx.Session.Wait into a shell style exit
+// code and signal name.
+func waitStatus(err error) (int, string) {
+	if err == nil {
+		return 0, ""
 	}
+	var exitErr *ptyx.ExitError
+	if !errors.As(err, &exitErr) {
+		return -1, ""
+	}
+	if ws, ok := exitErr.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		sig := ws.Signal()
+		return 128 + int(sig), signalName(sig)
+	}
+	return exitErr.ExitCode, ""
+}
 
-	go func() {
-		defer func() {
-			lcmd.session.Close()
-			close(lcmd.ptyClosed)
-		}()
-		lcmd.session.Wait()
-	}()
+func (lc *localCommand) done() <-chan struct{} {
+	return lc.ptyClosed
+}
 
-	return lcmd, nil
+// exitStatus reports the exit code and terminating signal of the process.
+// It is only meaningful once done has been closed.
+func (lc *localCommand) exitStatus() (int, string) {
+	return lc.exitCode, lc.exitSignal
 }
 
 func (lc *localCommand) Read(p []byte) (int, error) {
@@ -70,13 +135,30 @@ func (lc *localCommand) Read(p []byte) (int, error) {
 }
 
 func (lc *localCommand) Write(p []byte) (int, error) {
+	if lc.exited() {
+		return 0, os.ErrClosed
+	}
 	return lc.session.PtyWriter().Write(p)
 }
 
 func (lc *localCommand) Resize(cols, rows int) error {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	if lc.exited() {
+		return os.ErrClosed
+	}
 	return lc.session.Resize(cols, rows)
 }
 
+func (lc *localCommand) exited() bool {
+	select {
+	case <-lc.ptyClosed:
+		return true
+	default:
+		return false
+	}
+}
+
 func (lc *localCommand) ResizeTerminal(cols, rows int) error {
 	return lc.Resize(cols, rows)
 }
@@ -85,16 +167,21 @@ func (lc *localCommand) WindowTitleVariables() map[string]interface{} {
 	return map[string]interface{}{
 		"command": lc.command,
 		"argv":    lc.argv,
-		"pid":     lc.session.Pid(),
+		"pid":     lc.Pid(),
 		"cwd":     lc.cwd,
 	}
 }
 
+func (lc *localCommand) Pid() int {
+	return lc.session.Pid()
+}
+
 func (lc *localCommand) Close() error {
 	lc.mu.Lock()
-	defer lc.mu.Unlock()
-
-	lc.session.Kill()
+	if !lc.exited() {
+		lc.session.Kill()
+	}
+	lc.mu.Unlock()
 
 	select {
 	case <-lc.ptyClosed:
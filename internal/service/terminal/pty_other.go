@@ -0,0 +1,8 @@
+//go:build !unix
+
+package terminal
+
+// ForegroundProcessGroup is not available without job control.
+func (lc *localCommand) ForegroundProcessGroup() (int, error) {
+	return 0, ErrNoProcess
+}
@@ -2,6 +2,7 @@ package terminal
 
 import (
 	"os"
+	"strings"
 	"testing"
 	"time"
 )
@@ -103,3 +104,67 @@ func TestLocalCommand_ProcessExit(t *testing.T) {
 		t.Error("expected error when reading from closed PTY")
 	}
 }
+
+func TestLocalCommand_ExitStatus(t *testing.T) {
+	lc, err := newLocalCommand("/bin/sh", []string{"-c", "exit 3"}, os.TempDir(), 80, 24)
+	if err != nil {
+		t.Fatalf("failed to create LocalCommand: %v", err)
+	}
+
+	select {
+	case <-lc.ptyClosed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("process did not exit")
+	}
+
+	code, signal := lc.exitStatus()
+	if code != 3 {
+		t.Errorf("expected exit code 3, got %d", code)
+	}
+	if signal != "" {
+		t.Errorf("expected no signal, got %s", signal)
+	}
+}
+
+func TestLocalCommand_ExitStatusSignal(t *testing.T) {
+	lc, err := newLocalCommand("/bin/sh", nil, os.TempDir(), 80, 24, withCloseTimeout(time.Second))
+	if err != nil {
+		t.Fatalf("failed to create LocalCommand: %v", err)
+	}
+
+	lc.Close()
+	<-lc.ptyClosed
+
+	code, signal := lc.exitStatus()
+	if code != 137 {
+		t.Errorf("expected exit code 137, got %d", code)
+	}
+	if signal != "SIGKILL" {
+		t.Errorf("expected SIGKILL, got %s", signal)
+	}
+}
+
+func TestLocalCommand_EnvAndTerm(t *testing.T) {
+	lc, err := newLocalCommand("/bin/sh", []string{"-c", "echo \"$TERM:$VG_TEST_VAR\""}, os.TempDir(), 80, 24,
+		withEnv([]string{"VG_TEST_VAR=hello"}),
+		withTerm("vt100"),
+	)
+	if err != nil {
+		t.Fatalf("failed to create LocalCommand: %v", err)
+	}
+	defer lc.Close()
+
+	var out []byte
+	buf := make([]byte, 1024)
+	for {
+		n, err := lc.Read(buf)
+		out = append(out, buf[:n]...)
+		if err != nil || strings.Contains(string(out), "\n") {
+			break
+		}
+	}
+
+	if !strings.Contains(string(out), "vt100:hello") {
+		t.Errorf("expected output to contain 'vt100:hello', got %q", out)
+	}
+}
@@ -0,0 +1,30 @@
+//go:build unix
+
+package terminal
+
+import (
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// ForegroundProcessGroup returns the process group that currently owns the
+// terminal, e.g. the job a shell is running.
+func (lc *localCommand) ForegroundProcessGroup() (int, error) {
+	conn, ok := lc.session.PtyReader().(syscall.Conn)
+	if !ok {
+		return 0, ErrNoProcess
+	}
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+	var pgid int
+	var ioctlErr error
+	if err := raw.Control(func(fd uintptr) {
+		pgid, ioctlErr = unix.IoctlGetInt(int(fd), unix.TIOCGPGRP)
+	}); err != nil {
+		return 0, err
+	}
+	return pgid, ioctlErr
+}
@@ -0,0 +1,183 @@
+package terminal
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Overflow policies applied when a client's send queue is full.
+const (
+	// OverflowDrop disconnects the slow client.
+	OverflowDrop = "drop"
+	// OverflowSkip discards the queued output and resyncs the client with a
+	// gap notice followed by a snapshot of the current screen.
+	OverflowSkip = "skip"
+)
+
+type queuedFrame struct {
+	data   []byte
+	binary bool
+	// offset is the stream offset just past an output frame, 0 for control
+	// messages.
+	offset int64
+}
+
+// clientQueue decouples a client's WebSocket writes from the PTY read loop.
+type clientQueue struct {
+	master     master
+	maxBytes   int
+	mu         sync.Mutex
+	cond       *sync.Cond
+	frames     []queuedFrame
+	size       int
+	inflight   int64
+	closed     bool
+	drain      bool
+	done       chan struct{}
+	sent       atomic.Int64
+	dropped    atomic.Int64
+	onOverflow func()
+	onError    func()
+}
+
+func newClientQueue(mst master, maxBytes int) *clientQueue {
+	q := &clientQueue{
+		master:   mst,
+		maxBytes: maxBytes,
+		done:     make(chan struct{}),
+	}
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+func (q *clientQueue) Read(p []byte) (int, error) {
+	return q.master.Read(p)
+}
+
+// Write queues a control message. Control messages are small and never count
+// against the queue limit, so they are not lost to an overflow.
+func (q *clientQueue) Write(p []byte) (int, error) {
+	q.push(queuedFrame{data: p}, false)
+	return len(p), nil
+}
+
+func (q *clientQueue) WriteBinary(p []byte) (int, error) {
+	q.push(queuedFrame{data: p, binary: true}, false)
+	return len(p), nil
+}
+
+// WriteOutput queues an encoded output frame ending at stream offset offset.
+// The frame may be shared between clients and must not be modified.
+func (q *clientQueue) WriteOutput(frame []byte, binary bool, offset int64) {
+	if !q.push(queuedFrame{data: frame, binary: binary, offset: offset}, true) && q.onOverflow != nil {
+		q.onOverflow()
+	}
+}
+
+// push appends a frame. When limit is set and the frame would exceed
+// maxBytes it is counted as dropped and false is returned.
+func (q *clientQueue) push(f queuedFrame, limit bool) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return true
+	}
+	if limit && q.maxBytes > 0 && q.size+len(f.data) > q.maxBytes {
+		q.dropped.Add(int64(len(f.data)))
+		return false
+	}
+	q.frames = append(q.frames, f)
+	q.size += len(f.data)
+	q.cond.Signal()
+	return true
+}
+
+// reset discards every queued frame and returns the stream offset the client
+// will have received once the frame currently being written completes.
+func (q *clientQueue) reset() int64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.dropped.Add(int64(q.size))
+	q.frames = nil
+	q.size = 0
+	return max(q.sent.Load(), q.inflight)
+}
+
+func (q *clientQueue) run() {
+	defer close(q.done)
+
+	for {
+		q.mu.Lock()
+		for len(q.frames) == 0 && !q.closed {
+			q.cond.Wait()
+		}
+		if q.closed && (!q.drain || len(q.frames) == 0) {
+			q.mu.Unlock()
+			return
+		}
+		f := q.frames[0]
+		q.frames[0] = queuedFrame{}
+		q.frames = q.frames[1:]
+		q.size -= len(f.data)
+		q.inflight = f.offset
+		q.mu.Unlock()
+
+		var err error
+		if f.binary {
+			_, err = q.master.WriteBinary(f.data)
+		} else {
+			_, err = q.master.Write(f.data)
+		}
+		if err != nil {
+			q.close(false)
+			if q.onError != nil {
+				q.onError()
+			}
+			return
+		}
+		if f.offset > 0 {
+			q.sent.Store(f.offset)
+		}
+	}
+}
+
+// close stops the writer. With drain set, frames already queued are still
+// written before run returns.
+func (q *clientQueue) close(drain bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return
+	}
+	q.closed = true
+	q.drain = drain
+	q.cond.Broadcast()
+}
+
+func (q *clientQueue) queuedBytes() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.size
+}
+
+// handleOverflow applies the overflow policy to a client whose queue is full.
+// Callers hold historyMu.
+func (m *Manager) handleOverflow(at *activeTerminal, instance *webTTYInstance) {
+	if m.overflowPolicy == OverflowDrop {
+		log.Warn().Str("id", at.ID).Str("client", instance.ID).Msg("Terminal client too slow, disconnecting")
+		instance.Queue.reset()
+		instance.Queue.close(false)
+		instance.leave(LeaveOverflow)
+		return
+	}
+
+	q := instance.Queue
+	offset := at.historyBuffer.Offset()
+	writeGap(q, q.reset(), offset)
+	writeOutput(q, instance.Binary, at.screen.Snapshot(), offset)
+}
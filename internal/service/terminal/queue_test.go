@@ -0,0 +1,145 @@
+package terminal
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+// stalledMaster blocks every write until release is closed, simulating a
+// client that stopped reading.
+type stalledMaster struct {
+	mockMaster
+	release chan struct{}
+}
+
+func (m *stalledMaster) Write(p []byte) (int, error) {
+	<-m.release
+	return m.mockMaster.Write(p)
+}
+
+func (m *stalledMaster) WriteBinary(p []byte) (int, error) {
+	return m.Write(p)
+}
+
+func newStalledClient(manager *Manager, at *activeTerminal) (*webTTYInstance, *stalledMaster) {
+	mst := &stalledMaster{release: make(chan struct{})}
+	ctx, cancel := context.WithCancel(context.Background())
+	instance := &webTTYInstance{
+		ID:     "client-1",
+		Queue:  newClientQueue(mst, manager.clientQueueSize),
+		Ctx:    ctx,
+		Cancel: cancel,
+	}
+	instance.Queue.onOverflow = func() { manager.handleOverflow(at, instance) }
+	go instance.Queue.run()
+	at.WebTTYs.Store(instance.ID, instance)
+	manager.terminals.Store(at.ID, at)
+	return instance, mst
+}
+
+func emitOutput(manager *Manager, at *activeTerminal, data string) {
+	at.historyMu.Lock()
+	manager.appendHistory(at, []byte(data))
+	at.screen.Write([]byte(data))
+	at.broadcastOutput([]byte(data), at.historyBuffer.Offset())
+	at.historyMu.Unlock()
+}
+
+func TestClientQueue_WritesInOrder(t *testing.T) {
+	mst := &mockMaster{}
+	q := newClientQueue(mst, 0)
+	go q.run()
+
+	for _, s := range []string{"one", "two", "three"} {
+		q.Write([]byte(s))
+	}
+	q.close(true)
+	<-q.done
+
+	if len(mst.writeData) != 3 {
+		t.Fatalf("expected 3 writes, got %d", len(mst.writeData))
+	}
+	if string(mst.writeData[0]) != "one" || string(mst.writeData[2]) != "three" {
+		t.Errorf("unexpected write order: %q", mst.writeData)
+	}
+}
+
+func TestManager_ClientQueueOverflowSkip(t *testing.T) {
+	db := setupTestDB(t)
+	manager := NewManager(db, &ManagerConfig{Shell: "/bin/sh", ClientQueueSize: 256})
+	at := newTestHistoryTerminal("queue-skip")
+	instance, mst := newStalledClient(manager, at)
+
+	for i := 0; i < 20; i++ {
+		emitOutput(manager, at, "0123456789")
+	}
+
+	clients, err := manager.Clients(at.ID)
+	if err != nil {
+		t.Fatalf("Clients failed: %v", err)
+	}
+	if len(clients) != 1 || clients[0].DroppedBytes == 0 {
+		t.Fatalf("expected dropped bytes to be reported, got %+v", clients)
+	}
+	// A resync snapshot may push the queue past its limit once.
+	if limit := manager.clientQueueSize + len(at.screen.Snapshot()) + 64; clients[0].QueuedBytes > limit {
+		t.Errorf("queue exceeded its limit: %d bytes", clients[0].QueuedBytes)
+	}
+
+	close(mst.release)
+	instance.Queue.close(true)
+	<-instance.Queue.done
+
+	var sawGap bool
+	var last WSMessage
+	for _, frame := range mst.writeData {
+		var msg WSMessage
+		if err := json.Unmarshal(frame, &msg); err != nil {
+			t.Fatalf("invalid frame %q: %v", frame, err)
+		}
+		if msg.Type == MsgTypeGap {
+			sawGap = true
+			continue
+		}
+		last = msg
+	}
+	if !sawGap {
+		t.Error("expected a gap notice after skipping output")
+	}
+	if last.Offset != at.historyBuffer.Offset() {
+		t.Errorf("expected client to catch up to offset %d, got %d", at.historyBuffer.Offset(), last.Offset)
+	}
+	select {
+	case <-instance.Ctx.Done():
+		t.Error("skip policy should keep the client connected")
+	default:
+	}
+}
+
+func TestManager_ClientQueueOverflowDrop(t *testing.T) {
+	db := setupTestDB(t)
+	manager := NewManager(db, &ManagerConfig{
+		Shell:           "/bin/sh",
+		ClientQueueSize: 256,
+		OverflowPolicy:  OverflowDrop,
+	})
+	at := newTestHistoryTerminal("queue-drop")
+	instance, mst := newStalledClient(manager, at)
+	defer close(mst.release)
+
+	for i := 0; i < 20; i++ {
+		emitOutput(manager, at, strings.Repeat("x", 10))
+	}
+
+	select {
+	case <-instance.Ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("expected slow client to be disconnected")
+	}
+	if instance.Queue.dropped.Load() == 0 {
+		t.Error("expected dropped bytes to be counted")
+	}
+}
@@ -0,0 +1,362 @@
+package terminal
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/xxnuo/vibego/internal/model"
+	"gorm.io/gorm"
+)
+
+// castHeader is the first line of an asciicast v2 file.
+type castHeader struct {
+	Version   int               `json:"version"`
+	Width     int               `json:"width"`
+	Height    int               `json:"height"`
+	Timestamp int64             `json:"timestamp"`
+	Title     string            `json:"title,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+}
+
+type RecordingInfo struct {
+	ID        string  `json:"id"`
+	SessionID string  `json:"session_id"`
+	Title     string  `json:"title"`
+	Cols      int     `json:"cols"`
+	Rows      int     `json:"rows"`
+	Size      int64   `json:"size"`
+	Duration  float64 `json:"duration"`
+	Active    bool    `json:"active"`
+	CreatedAt int64   `json:"created_at"`
+	UpdatedAt int64   `json:"updated_at"`
+	Path      string  `json:"-"`
+}
+
+func recordingToInfo(r *model.TerminalRecording) *RecordingInfo {
+	return &RecordingInfo{
+		ID:        r.ID,
+		SessionID: r.SessionID,
+		Title:     r.Title,
+		Cols:      r.Cols,
+		Rows:      r.Rows,
+		Size:      r.Size,
+		Duration:  r.Duration,
+		CreatedAt: r.CreatedAt,
+		UpdatedAt: r.UpdatedAt,
+		Path:      r.Path,
+	}
+}
+
+// recorder appends output and resize events of one session to an asciicast
+// v2 file.
+type recorder struct {
+	id      string
+	file    *os.File
+	start   time.Time
+	size    int64
+	elapsed float64
+	partial []byte
+	mu      sync.Mutex
+}
+
+func newRecorder(id, path string, header castHeader) (*recorder, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return nil, err
+	}
+	r := &recorder{id: id, file: file, start: time.Now()}
+	line, _ := json.Marshal(header)
+	if err := r.writeLine(line); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *recorder) writeLine(line []byte) error {
+	n, err := r.file.Write(append(line, '\n'))
+	r.size += int64(n)
+	return err
+}
+
+func (r *recorder) event(kind, data string) {
+	r.elapsed = math.Round(time.Since(r.start).Seconds()*1e6) / 1e6
+	line, _ := json.Marshal([]any{r.elapsed, kind, data})
+	r.writeLine(line)
+}
+
+// Output records PTY output. Event data must be valid UTF-8, so a multi-byte
+// sequence split across reads is held back until it is complete.
+func (r *recorder) Output(p []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return
+	}
+	if len(r.partial) > 0 {
+		p = append(r.partial, p...)
+		r.partial = nil
+	}
+	p, r.partial = splitUTF8(p)
+	if len(r.partial) > 0 {
+		r.partial = append([]byte(nil), r.partial...)
+	}
+	if len(p) > 0 {
+		r.event("o", string(p))
+	}
+}
+
+func (r *recorder) Resize(cols, rows int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return
+	}
+	r.event("r", fmt.Sprintf("%dx%d", cols, rows))
+}
+
+// Close finishes the file and returns its size and the time of the last
+// event. Closing twice is a no-op.
+func (r *recorder) Close() (int64, float64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return r.size, r.elapsed, nil
+	}
+	if len(r.partial) > 0 {
+		r.event("o", string(r.partial))
+		r.partial = nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return r.size, r.elapsed, err
+}
+
+// splitUTF8 splits p before a trailing incomplete UTF-8 sequence.
+func splitUTF8(p []byte) ([]byte, []byte) {
+	for i := len(p) - 1; i >= 0 && i >= len(p)-utf8.UTFMax; i-- {
+		if !utf8.RuneStart(p[i]) {
+			continue
+		}
+		if utf8.FullRune(p[i:]) {
+			return p, nil
+		}
+		return p[:i], p[i:]
+	}
+	return p, nil
+}
+
+func (m *Manager) startRecording(session *model.TerminalSession, term string) (*recorder, error) {
+	if err := os.MkdirAll(m.recordDir, 0700); err != nil {
+		return nil, err
+	}
+	if term == "" {
+		term = DefaultTerm
+	}
+
+	id := uuid.New().String()
+	path := filepath.Join(m.recordDir, id+".cast")
+	rec, err := newRecorder(id, path, castHeader{
+		Version:   2,
+		Width:     session.Cols,
+		Height:    session.Rows,
+		Timestamp: session.CreatedAt,
+		Title:     session.Name,
+		Env:       map[string]string{"SHELL": session.Shell, "TERM": term},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	recording := &model.TerminalRecording{
+		ID:        id,
+		SessionID: session.ID,
+		UserID:    session.UserID,
+		Title:     session.Name,
+		Path:      path,
+		Cols:      session.Cols,
+		Rows:      session.Rows,
+		Size:      rec.size,
+		CreatedAt: session.CreatedAt,
+		UpdatedAt: session.CreatedAt,
+	}
+	if err := m.db.Create(recording).Error; err != nil {
+		rec.Close()
+		os.Remove(path)
+		return nil, err
+	}
+	return rec, nil
+}
+
+func (m *Manager) finishRecording(at *activeTerminal) {
+	if at.recorder == nil {
+		return
+	}
+	size, duration, _ := at.recorder.Close()
+	m.db.Model(&model.TerminalRecording{}).Where("id = ?", at.recorder.id).Updates(map[string]any{
+		"size":       size,
+		"duration":   duration,
+		"updated_at": time.Now().Unix(),
+	})
+}
+
+func (m *Manager) isRecording(id string) bool {
+	recording := false
+	m.terminals.Range(func(key, value any) bool {
+		at := value.(*activeTerminal)
+		if at.recorder != nil && at.recorder.id == id && at.ptyStatus.Load() == model.PTYStatusRunning {
+			recording = true
+			return false
+		}
+		return true
+	})
+	return recording
+}
+
+// ListRecordings returns the recordings of userID and those shared by every
+// user newest first, optionally limited to one session.
+func (m *Manager) ListRecordings(sessionID, userID string) ([]RecordingInfo, error) {
+	query := m.ownedBy(userID).Order("created_at DESC")
+	if sessionID != "" {
+		query = query.Where("session_id = ?", sessionID)
+	}
+	var recordings []model.TerminalRecording
+	if err := query.Find(&recordings).Error; err != nil {
+		return nil, err
+	}
+	result := make([]RecordingInfo, len(recordings))
+	for i := range recordings {
+		result[i] = *recordingToInfo(&recordings[i])
+		result[i].Active = m.isRecording(recordings[i].ID)
+	}
+	return result, nil
+}
+
+func (m *Manager) GetRecording(id, userID string) (*RecordingInfo, error) {
+	var recording model.TerminalRecording
+	if err := m.ownedBy(userID).First(&recording, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrRecordingNotFound
+		}
+		return nil, err
+	}
+	info := recordingToInfo(&recording)
+	info.Active = m.isRecording(id)
+	return info, nil
+}
+
+func (m *Manager) DeleteRecording(id, userID string) error {
+	info, err := m.GetRecording(id, userID)
+	if err != nil {
+		return err
+	}
+	if info.Active {
+		return ErrRecordingActive
+	}
+	if err := os.Remove(info.Path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return m.db.Where("id = ?", id).Delete(&model.TerminalRecording{}).Error
+}
+
+// Play streams a recording to a WebSocket client with the live terminal
+// protocol, paced by speed.
+func (m *Manager) Play(id, userID string, conn *websocket.Conn, speed float64) (*Connection, error) {
+	info, err := m.GetRecording(id, userID)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(info.Path)
+	if err != nil {
+		return nil, err
+	}
+	if speed <= 0 {
+		speed = 1
+	}
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), m.historyBufferSize)
+	if !scanner.Scan() {
+		file.Close()
+		return nil, ErrRecordingNotFound
+	}
+
+	binary := conn.Subprotocol() == SubprotocolBinary
+	wsm := newWSMaster(conn, m.keepalive)
+	queue := newClientQueue(wsm, 0)
+	go queue.run()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	doneCh := make(chan struct{})
+
+	go func() {
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				cancel()
+				return
+			}
+		}
+	}()
+
+	go func() {
+		defer func() {
+			file.Close()
+			queue.close(ctx.Err() == nil)
+			<-queue.done
+			wsm.Close()
+			close(doneCh)
+		}()
+
+		start := time.Now()
+		var offset int64
+		for scanner.Scan() {
+			var event []any
+			if err := json.Unmarshal(scanner.Bytes(), &event); err != nil || len(event) < 3 {
+				continue
+			}
+			ts, _ := event[0].(float64)
+			kind, _ := event[1].(string)
+			data, _ := event[2].(string)
+
+			delay := time.Duration(ts/speed*float64(time.Second)) - time.Since(start)
+			if delay > 0 {
+				select {
+				case <-time.After(delay):
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			switch kind {
+			case "o":
+				offset += int64(len(data))
+				writeOutput(queue, binary, []byte(data), offset)
+			case "r":
+				var cols, rows int
+				if _, err := fmt.Sscanf(data, "%dx%d", &cols, &rows); err == nil {
+					msgData, _ := json.Marshal(WSMessage{Type: MsgTypeResize, Cols: cols, Rows: rows})
+					queue.Write(msgData)
+				}
+			}
+		}
+
+		msgData, _ := json.Marshal(ExitMessage{Type: MsgTypeExit})
+		queue.Write(msgData)
+	}()
+
+	return &Connection{Done: doneCh}, nil
+}
@@ -0,0 +1,213 @@
+package terminal
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestSplitUTF8(t *testing.T) {
+	text := []byte("aé")
+	done, rest := splitUTF8(text[:2])
+	if string(done) != "a" || len(rest) != 1 {
+		t.Errorf("expected incomplete rune held back, got %q %q", done, rest)
+	}
+	done, rest = splitUTF8(text)
+	if string(done) != "aé" || rest != nil {
+		t.Errorf("expected complete input, got %q %q", done, rest)
+	}
+}
+
+func createRecordedTerminal(t *testing.T, manager *Manager) (*TerminalInfo, *RecordingInfo) {
+	t.Helper()
+
+	info, err := manager.Create(CreateOptions{
+		Name:    "recorded",
+		Cwd:     os.TempDir(),
+		Command: "/bin/sh",
+		Args:    []string{"-c", "printf 'héllo'; sleep 0.3"},
+		Record:  true,
+		UserID:  "alice",
+	})
+	if err != nil {
+		t.Fatalf("failed to create terminal: %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if err := manager.Resize(info.ID, 100, 30); err != nil {
+		t.Fatalf("resize failed: %v", err)
+	}
+
+	if recordings, _ := manager.ListRecordings(info.ID, "bob"); len(recordings) != 0 {
+		t.Errorf("expected another user's recordings to be hidden, got %+v", recordings)
+	}
+	recordings, err := manager.ListRecordings(info.ID, "alice")
+	if err != nil || len(recordings) != 1 {
+		t.Fatalf("expected one recording, got %v (%v)", recordings, err)
+	}
+	if !recordings[0].Active {
+		t.Error("expected recording to be active while the process runs")
+	}
+
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		term, _ := manager.Get(info.ID)
+		if term.PTYStatus == "exited" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("process did not exit")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	if _, err := manager.GetRecording(recordings[0].ID, "bob"); err != ErrRecordingNotFound {
+		t.Errorf("expected another user's recording to be hidden, got %v", err)
+	}
+	rec, err := manager.GetRecording(recordings[0].ID, "alice")
+	if err != nil {
+		t.Fatalf("GetRecording failed: %v", err)
+	}
+	return info, rec
+}
+
+func TestManager_Recording(t *testing.T) {
+	db := setupTestDB(t)
+	manager := NewManager(db, &ManagerConfig{Shell: "/bin/sh", RecordDir: t.TempDir()})
+	info, rec := createRecordedTerminal(t, manager)
+	defer manager.Close(info.ID)
+
+	if rec.Active || rec.Size == 0 || rec.Duration <= 0 {
+		t.Errorf("expected finished recording with size and duration, got %+v", rec)
+	}
+
+	file, err := os.Open(rec.Path)
+	if err != nil {
+		t.Fatalf("failed to open recording: %v", err)
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+
+	scanner.Scan()
+	var header castHeader
+	if err := json.Unmarshal(scanner.Bytes(), &header); err != nil {
+		t.Fatalf("invalid header: %v", err)
+	}
+	if header.Version != 2 || header.Width != 80 || header.Height != 24 || header.Title != "recorded" {
+		t.Errorf("unexpected header %+v", header)
+	}
+
+	var output strings.Builder
+	var resized bool
+	for scanner.Scan() {
+		var event []any
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatalf("invalid event %q: %v", scanner.Text(), err)
+		}
+		switch event[1] {
+		case "o":
+			output.WriteString(event[2].(string))
+		case "r":
+			resized = event[2] == "100x30"
+		}
+	}
+	if output.String() != "héllo" {
+		t.Errorf("expected recorded output 'héllo', got %q", output.String())
+	}
+	if !resized {
+		t.Error("expected a 100x30 resize event")
+	}
+
+	if err := manager.DeleteRecording(rec.ID, "bob"); err != ErrRecordingNotFound {
+		t.Errorf("expected another user's recording to be kept, got %v", err)
+	}
+	if err := manager.DeleteRecording(rec.ID, "alice"); err != nil {
+		t.Fatalf("DeleteRecording failed: %v", err)
+	}
+	if _, err := os.Stat(rec.Path); !os.IsNotExist(err) {
+		t.Error("expected recording file to be removed")
+	}
+	if _, err := manager.GetRecording(rec.ID, "alice"); err != ErrRecordingNotFound {
+		t.Errorf("expected ErrRecordingNotFound, got %v", err)
+	}
+}
+
+func TestManager_RecordAll(t *testing.T) {
+	db := setupTestDB(t)
+	manager := NewManager(db, &ManagerConfig{Shell: "/bin/sh", RecordDir: t.TempDir(), RecordAll: true})
+
+	info, err := manager.Create(CreateOptions{Cwd: os.TempDir(), Command: "/bin/sh", Args: []string{"-c", "true"}})
+	if err != nil {
+		t.Fatalf("failed to create terminal: %v", err)
+	}
+	defer manager.Close(info.ID)
+
+	recordings, _ := manager.ListRecordings(info.ID, "")
+	if len(recordings) != 1 {
+		t.Errorf("expected session to be recorded, got %d recordings", len(recordings))
+	}
+}
+
+func TestManager_PlayRecording(t *testing.T) {
+	db := setupTestDB(t)
+	manager := NewManager(db, &ManagerConfig{Shell: "/bin/sh", RecordDir: t.TempDir()})
+	info, rec := createRecordedTerminal(t, manager)
+	defer manager.Close(info.ID)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upgrader := websocket.Upgrader{}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		playConn, err := manager.Play(rec.ID, "alice", conn, 10)
+		if err != nil {
+			conn.Close()
+			return
+		}
+		<-playConn.Done
+	}))
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	defer conn.Close()
+
+	var output strings.Builder
+	var resize WSMessage
+	for {
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("playback ended without exit message: %v", err)
+		}
+		var msg WSMessage
+		json.Unmarshal(data, &msg)
+		if msg.Type == MsgTypeExit {
+			break
+		}
+		switch msg.Type {
+		case MsgTypeCmd:
+			decoded, _ := base64.StdEncoding.DecodeString(msg.Data)
+			output.Write(decoded)
+		case MsgTypeResize:
+			resize = msg
+		}
+	}
+
+	if output.String() != "héllo" {
+		t.Errorf("expected played output 'héllo', got %q", output.String())
+	}
+	if resize.Cols != 100 || resize.Rows != 30 {
+		t.Errorf("expected resize to 100x30, got %dx%d", resize.Cols, resize.Rows)
+	}
+}
@@ -0,0 +1,171 @@
+package terminal
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/xxnuo/vibego/internal/model"
+)
+
+// Resize policies decide the PTY size when several writers are attached.
+// Read-only clients never affect it.
+const (
+	ResizeSmallest = "smallest"
+	ResizeLatest   = "latest"
+	ResizePinned   = "pinned"
+)
+
+func validResizePolicy(policy string) bool {
+	switch policy {
+	case ResizeSmallest, ResizeLatest, ResizePinned:
+		return true
+	}
+	return false
+}
+
+func (at *activeTerminal) size() (cols, rows int) {
+	at.resizeMu.Lock()
+	defer at.resizeMu.Unlock()
+	return at.cols, at.rows
+}
+
+func (at *activeTerminal) resizeState() (policy, pinned string) {
+	at.resizeMu.Lock()
+	defer at.resizeMu.Unlock()
+	return at.resizePolicy, at.pinnedClient
+}
+
+func (at *activeTerminal) resizeMessage() []byte {
+	msgData, _ := json.Marshal(ResizeMessage{Type: MsgTypeResize, Cols: at.cols, Rows: at.rows})
+	return msgData
+}
+
+// clientResize records the size a client asked for and applies the size
+// chosen by the terminal's policy.
+func (m *Manager) clientResize(at *activeTerminal, instance *webTTYInstance, cols, rows int) {
+	at.resizeMu.Lock()
+	defer at.resizeMu.Unlock()
+	instance.cols, instance.rows = cols, rows
+	instance.lastActive.Store(time.Now().UnixNano())
+	m.arbitrateSize(at)
+	if at.cols != cols || at.rows != rows {
+		instance.Queue.Write(at.resizeMessage())
+	}
+}
+
+// clientInput records client activity. Under ResizeLatest the typing
+// client takes over the size.
+func (m *Manager) clientInput(at *activeTerminal, instance *webTTYInstance) {
+	instance.lastActive.Store(time.Now().UnixNano())
+	at.resizeMu.Lock()
+	defer at.resizeMu.Unlock()
+	if at.resizePolicy == ResizeLatest {
+		m.arbitrateSize(at)
+	}
+}
+
+// addClient registers an attached client. A client returning with the token
+// of the pinned client takes over the pin. Callers must hold resizeMu.
+func (at *activeTerminal) addClient(instance *webTTYInstance) {
+	at.WebTTYs.Store(instance.ID, instance)
+	if at.pinnedToken != "" && instance.token == at.pinnedToken {
+		at.pinnedClient = instance.ID
+	}
+}
+
+// removeClient detaches a client and lets the remaining ones decide the size.
+func (m *Manager) removeClient(at *activeTerminal, clientID string) {
+	at.resizeMu.Lock()
+	defer at.resizeMu.Unlock()
+	at.WebTTYs.Delete(clientID)
+	m.arbitrateSize(at)
+}
+
+// arbitrateSize applies the size preferred by the resize policy when it
+// differs from the current one. Callers must hold resizeMu.
+func (m *Manager) arbitrateSize(at *activeTerminal) {
+	if at.ptyStatus.Load() == model.PTYStatusExited {
+		return
+	}
+	cols, rows, ok := at.preferredSize()
+	if !ok || (cols == at.cols && rows == at.rows) {
+		return
+	}
+	m.applySize(at, cols, rows)
+}
+
+func (at *activeTerminal) preferredSize() (cols, rows int, ok bool) {
+	var latest int64
+	at.WebTTYs.Range(func(key, value any) bool {
+		instance := value.(*webTTYInstance)
+		if instance.ReadOnly || instance.cols <= 0 || instance.rows <= 0 {
+			return true
+		}
+		switch at.resizePolicy {
+		case ResizePinned:
+			if instance.ID == at.pinnedClient {
+				cols, rows, ok = instance.cols, instance.rows, true
+				return false
+			}
+		case ResizeLatest:
+			if active := instance.lastActive.Load(); !ok || active > latest {
+				cols, rows, ok, latest = instance.cols, instance.rows, true, active
+			}
+		default:
+			if !ok {
+				cols, rows, ok = instance.cols, instance.rows, true
+			}
+			cols, rows = min(cols, instance.cols), min(rows, instance.rows)
+		}
+		return true
+	})
+	return cols, rows, ok
+}
+
+// applySize resizes the PTY and the server-side emulator and tells every
+// client the new size. Callers must hold resizeMu.
+func (m *Manager) applySize(at *activeTerminal, cols, rows int) error {
+	if err := at.PTY.ResizeTerminal(cols, rows); err != nil {
+		return err
+	}
+	at.cols, at.rows = cols, rows
+	at.screen.Resize(cols, rows)
+	if at.recorder != nil {
+		at.recorder.Resize(cols, rows)
+	}
+
+	m.db.Model(&model.TerminalSession{}).Where("id = ?", at.ID).Updates(map[string]any{
+		"cols":       cols,
+		"rows":       rows,
+		"updated_at": time.Now().Unix(),
+	})
+
+	at.broadcast(at.resizeMessage())
+	return nil
+}
+
+// SetResizePolicy changes how a terminal's size is chosen.
+func (m *Manager) SetResizePolicy(id, policy, clientID string) error {
+	if !validResizePolicy(policy) {
+		return ErrInvalidResizePolicy
+	}
+	at, ok := m.getActive(id)
+	if !ok {
+		return ErrTerminalNotFound
+	}
+	token := ""
+	if policy != ResizePinned {
+		clientID = ""
+	} else if value, ok := at.WebTTYs.Load(clientID); ok {
+		token = value.(*webTTYInstance).token
+	} else {
+		return ErrClientNotFound
+	}
+
+	at.resizeMu.Lock()
+	defer at.resizeMu.Unlock()
+	at.resizePolicy, at.pinnedClient, at.pinnedToken = policy, clientID, token
+	m.db.Model(&model.TerminalSession{}).Where("id = ?", id).Update("resize_policy", policy)
+	m.arbitrateSize(at)
+	return nil
+}
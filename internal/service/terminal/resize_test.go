@@ -0,0 +1,153 @@
+package terminal
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+)
+
+func addResizeClient(at *activeTerminal, id string, readOnly bool) (*webTTYInstance, *mockMaster) {
+	mst := &mockMaster{}
+	queue := newClientQueue(mst, 0)
+	go queue.run()
+	_, cancel := context.WithCancel(context.Background())
+	instance := &webTTYInstance{ID: id, Queue: queue, ReadOnly: readOnly, Cancel: cancel}
+	at.WebTTYs.Store(id, instance)
+	return instance, mst
+}
+
+// lastResize returns the most recent resize message a client received,
+// waiting a while for it to be cols wide.
+func lastResize(mst *mockMaster, cols int) *ResizeMessage {
+	var last *ResizeMessage
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		last = nil
+		mst.mu.Lock()
+		for i := len(mst.writeData) - 1; i >= 0 && last == nil; i-- {
+			var msg ResizeMessage
+			if json.Unmarshal(mst.writeData[i], &msg) == nil && msg.Type == MsgTypeResize {
+				last = &msg
+			}
+		}
+		mst.mu.Unlock()
+		if last != nil && last.Cols == cols {
+			break
+		}
+	}
+	return last
+}
+
+func TestManager_ResizeSmallest(t *testing.T) {
+	db := setupTestDB(t)
+	manager := NewManager(db, &ManagerConfig{Shell: "/bin/sh"})
+
+	info, err := manager.Create(CreateOptions{Cwd: os.TempDir(), Command: "/bin/sh", Cols: 100, Rows: 40})
+	if err != nil {
+		t.Fatalf("failed to create terminal: %v", err)
+	}
+	defer manager.Close(info.ID)
+	at, _ := manager.getActive(info.ID)
+
+	laptop, laptopMaster := addResizeClient(at, "laptop", false)
+	phone, _ := addResizeClient(at, "phone", false)
+	viewer, viewerMaster := addResizeClient(at, "viewer", true)
+
+	manager.clientResize(at, laptop, 200, 50)
+	manager.clientResize(at, phone, 40, 60)
+	manager.clientResize(at, viewer, 10, 5)
+	if cols, rows := at.size(); cols != 40 || rows != 50 {
+		t.Errorf("expected the smallest writer size 40x50, got %dx%d", cols, rows)
+	}
+	if msg := lastResize(viewerMaster, 40); msg == nil || msg.Cols != 40 || msg.Rows != 50 {
+		t.Errorf("expected overruled viewer to be told the size, got %+v", msg)
+	}
+
+	result, err := manager.Run(context.Background(), info.ID, "stty size", RunOptions{Timeout: 3 * time.Second})
+	if err != nil || result.Output != "50 40\n" {
+		t.Errorf("expected the PTY to be resized, got %+v (%v)", result, err)
+	}
+
+	manager.removeClient(at, "phone")
+	if cols, rows := at.size(); cols != 200 || rows != 50 {
+		t.Errorf("expected the remaining writer's size after the phone left, got %dx%d", cols, rows)
+	}
+	if msg := lastResize(laptopMaster, 200); msg == nil || msg.Cols != 200 || msg.Rows != 50 {
+		t.Errorf("expected clients to be told the new size, got %+v", msg)
+	}
+	if term, _ := manager.Get(info.ID); term.Cols != 200 || term.Rows != 50 || term.ResizePolicy != ResizeSmallest {
+		t.Errorf("unexpected terminal info %+v", term)
+	}
+}
+
+func TestManager_ResizeLatestAndPinned(t *testing.T) {
+	db := setupTestDB(t)
+	manager := NewManager(db, &ManagerConfig{Shell: "/bin/sh"})
+
+	info, err := manager.Create(CreateOptions{
+		Cwd:          os.TempDir(),
+		Command:      "/bin/sh",
+		Args:         []string{"-c", "sleep 30"},
+		ResizePolicy: ResizeLatest,
+	})
+	if err != nil {
+		t.Fatalf("failed to create terminal: %v", err)
+	}
+	defer manager.Close(info.ID)
+	at, _ := manager.getActive(info.ID)
+
+	laptop, _ := addResizeClient(at, "laptop", false)
+	phone, phoneMaster := addResizeClient(at, "phone", false)
+
+	manager.clientResize(at, laptop, 200, 50)
+	manager.clientResize(at, phone, 40, 20)
+	if cols, rows := at.size(); cols != 40 || rows != 20 {
+		t.Errorf("expected the latest resize to win, got %dx%d", cols, rows)
+	}
+	time.Sleep(time.Millisecond)
+	manager.clientInput(at, laptop)
+	if cols, rows := at.size(); cols != 200 || rows != 50 {
+		t.Errorf("expected the typing client to take over, got %dx%d", cols, rows)
+	}
+
+	if err := manager.SetResizePolicy(info.ID, "largest", ""); err != ErrInvalidResizePolicy {
+		t.Errorf("expected ErrInvalidResizePolicy, got %v", err)
+	}
+	if err := manager.SetResizePolicy(info.ID, ResizePinned, "tablet"); err != ErrClientNotFound {
+		t.Errorf("expected ErrClientNotFound, got %v", err)
+	}
+	phone.token = "phone-token"
+	if err := manager.SetResizePolicy(info.ID, ResizePinned, "phone"); err != nil {
+		t.Fatalf("SetResizePolicy failed: %v", err)
+	}
+	if cols, rows := at.size(); cols != 40 || rows != 20 {
+		t.Errorf("expected the pinned client's size, got %dx%d", cols, rows)
+	}
+
+	manager.clientResize(at, laptop, 120, 30)
+	manager.clientInput(at, laptop)
+	manager.removeClient(at, "phone")
+	if cols, rows := at.size(); cols != 40 || rows != 20 {
+		t.Errorf("expected the pinned size to hold, got %dx%d", cols, rows)
+	}
+	if msg := lastResize(phoneMaster, 40); msg == nil || msg.Cols != 40 {
+		t.Errorf("expected the phone to know its size, got %+v", msg)
+	}
+	if term, _ := manager.Get(info.ID); term.ResizePolicy != ResizePinned || term.PinnedClient != "phone" {
+		t.Errorf("unexpected terminal info %+v", term)
+	}
+
+	// The phone reconnects as a new client with the same token.
+	returned := &webTTYInstance{ID: "phone-2", Queue: phone.Queue, Cancel: phone.Cancel, token: "phone-token"}
+	at.resizeMu.Lock()
+	at.addClient(returned)
+	at.resizeMu.Unlock()
+	manager.clientResize(at, returned, 60, 25)
+	if cols, rows := at.size(); cols != 60 || rows != 25 {
+		t.Errorf("expected the pin to follow the returning client, got %dx%d", cols, rows)
+	}
+	if term, _ := manager.Get(info.ID); term.PinnedClient != "phone-2" {
+		t.Errorf("expected the returning client to hold the pin, got %+v", term)
+	}
+}
@@ -0,0 +1,266 @@
+package terminal
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/xxnuo/vibego/internal/model"
+)
+
+const (
+	defaultRunTimeout = 30 * time.Second
+	maxRunTimeout     = 10 * time.Minute
+	maxRunOutput      = 1024 * 1024
+	// maxTapBuffer is the raw output a tap keeps: the output returned plus
+	// room for the escape sequences stripped from it and the end mark.
+	maxTapBuffer = maxRunOutput + 64*1024
+)
+
+// Completion detection methods reported in RunResult.
+const (
+	RunMethodShellIntegration = "shell_integration"
+	RunMethodSentinel         = "sentinel"
+)
+
+// RunOptions controls Manager.Run. Timeout defaults to 30 seconds.
+type RunOptions struct {
+	Timeout   time.Duration
+	Interrupt bool
+}
+
+// RunResult is the outcome of a command run through Manager.Run. Output has
+// escape sequences removed; when it exceeds 1 MiB only the tail is kept.
+type RunResult struct {
+	Output    string  `json:"output"`
+	ExitCode  int     `json:"exit_code"`
+	TimedOut  bool    `json:"timed_out"`
+	Truncated bool    `json:"truncated,omitempty"`
+	Duration  float64 `json:"duration"`
+	Method    string  `json:"method"`
+}
+
+// outputTap collects the last maxTapBuffer or so bytes of PTY output; base
+// counts those dropped before buf.
+type outputTap struct {
+	mu     sync.Mutex
+	buf    []byte
+	base   int
+	notify chan struct{}
+}
+
+// bytes returns the output kept and the position of its first byte in the
+// output since the tap was added.
+func (t *outputTap) bytes() ([]byte, int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.buf, t.base
+}
+
+func (at *activeTerminal) addTap() *outputTap {
+	tap := &outputTap{notify: make(chan struct{}, 1)}
+	at.taps.Store(tap, struct{}{})
+	return tap
+}
+
+func (at *activeTerminal) feedTaps(data []byte) {
+	at.taps.Range(func(key, value any) bool {
+		tap := key.(*outputTap)
+		tap.mu.Lock()
+		tap.buf = append(tap.buf, data...)
+		// The output is dropped in large steps to keep copying rare, into a
+		// new array as callers may still hold the old one.
+		if len(tap.buf) > 2*maxTapBuffer {
+			over := len(tap.buf) - maxTapBuffer
+			tap.buf = append([]byte(nil), tap.buf[over:]...)
+			tap.base += over
+		}
+		tap.mu.Unlock()
+		select {
+		case tap.notify <- struct{}{}:
+		default:
+		}
+		return true
+	})
+}
+
+// WriteInput types data into a terminal as if it came from a client.
+func (m *Manager) WriteInput(id string, data []byte) error {
+	at, ok := m.getActive(id)
+	if !ok {
+		return ErrTerminalNotFound
+	}
+	if at.ptyStatus.Load() == model.PTYStatusExited {
+		return ErrTerminalExited
+	}
+	_, err := at.PTY.Write(data)
+	return err
+}
+
+// Run types command into a terminal and waits for it to finish, using shell
+// integration marks or, failing those, sentinel lines.
+func (m *Manager) Run(ctx context.Context, id, command string, opts RunOptions) (*RunResult, error) {
+	at, ok := m.getActive(id)
+	if !ok {
+		return nil, ErrTerminalNotFound
+	}
+	if at.ptyStatus.Load() == model.PTYStatusExited {
+		return nil, ErrTerminalExited
+	}
+	if !at.runMu.TryLock() {
+		return nil, ErrTerminalBusy
+	}
+	defer at.runMu.Unlock()
+
+	marked, running := at.shell.integrated()
+	if running {
+		return nil, ErrTerminalBusy
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultRunTimeout
+	}
+	opts.Timeout = min(opts.Timeout, maxRunTimeout)
+
+	var line []byte
+	var scanner *runScanner
+	result := &RunResult{ExitCode: -1}
+	if marked {
+		result.Method = RunMethodShellIntegration
+		line = []byte(command + "\n")
+		scanner = newRunScanner("\x1b]133;C", "\x1b]133;D", true)
+	} else {
+		result.Method = RunMethodSentinel
+		token := make([]byte, 8)
+		rand.Read(token)
+		tok := hex.EncodeToString(token)
+		// The format strings keep the marks out of the echoed command line.
+		// Grouping makes the shell read the whole input before running it,
+		// so no prompt is printed between the marks.
+		line = fmt.Appendf(nil, " { printf '__VGS_%%s__\\n' %s; %s\n}; printf '__VGE_%%s_%%d__\\n' %s \"$?\"\n", tok, command, tok)
+		scanner = newRunScanner("__VGS_"+tok, "__VGE_"+tok+"_", false)
+	}
+
+	tap := at.addTap()
+	defer at.taps.Delete(tap)
+
+	start := time.Now()
+	if _, err := at.PTY.Write(line); err != nil {
+		return nil, err
+	}
+
+	timer := time.NewTimer(opts.Timeout)
+	defer timer.Stop()
+	for {
+		select {
+		case <-tap.notify:
+			if raw, base := tap.bytes(); scanner.scan(raw, base) {
+				result.ExitCode = scanner.code
+				result.finish(scanner.output(raw, base))
+				result.Duration = time.Since(start).Seconds()
+				return result, nil
+			}
+		case <-timer.C:
+			result.TimedOut = true
+			result.finish(scanner.output(tap.bytes()))
+			result.Duration = time.Since(start).Seconds()
+			if opts.Interrupt {
+				at.PTY.Write([]byte{0x03})
+			}
+			return result, nil
+		case <-at.readDone:
+			return nil, ErrTerminalExited
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// finish sets the output, which is truncated when the tap already dropped
+// part of it.
+func (r *RunResult) finish(output []byte, truncated bool) {
+	output = stripANSI(output)
+	if len(output) > maxRunOutput {
+		output, truncated = output[len(output)-maxRunOutput:], true
+	}
+	r.Output = string(output)
+	r.Truncated = truncated
+}
+
+// runScanner finds the marks framing a command's output.
+type runScanner struct {
+	startMark []byte
+	endMark   []byte
+	osc       bool
+	start     int
+	end       int
+	code      int
+	next      int
+}
+
+func newRunScanner(startMark, endMark string, osc bool) *runScanner {
+	return &runScanner{startMark: []byte(startMark), endMark: []byte(endMark), osc: osc, start: -1, end: -1}
+}
+
+// scan looks for the marks in raw, resuming where the previous call
+// stopped, and reports whether the command has finished.
+func (s *runScanner) scan(raw []byte, base int) bool {
+	s.next = max(s.next, base)
+	if s.start < 0 {
+		_, after, _ := s.find(raw, base, s.startMark)
+		if after < 0 {
+			return false
+		}
+		s.start, s.next = after, after
+	}
+	i, after, payload := s.find(raw, base, s.endMark)
+	if after < 0 {
+		return false
+	}
+	s.end = i
+	s.code, _ = strconv.Atoi(strings.Trim(payload, ";_\r"))
+	return true
+}
+
+// find returns the index of mark in raw at or after s.next, the index past
+// its terminator and the text in between, or -1 until the terminator arrives.
+func (s *runScanner) find(raw []byte, base int, mark []byte) (int, int, string) {
+	i := bytes.Index(raw[s.next-base:], mark)
+	if i < 0 {
+		s.next = max(s.next, base+len(raw)-len(mark))
+		return -1, -1, ""
+	}
+	i += s.next
+	rest := raw[i-base+len(mark):]
+
+	end, size := bytes.IndexByte(rest, '\n'), 1
+	if s.osc {
+		end = bytes.IndexByte(rest, 0x07)
+		if st := bytes.Index(rest, []byte("\x1b\\")); st >= 0 && (end < 0 || st < end) {
+			end, size = st, 2
+		}
+	}
+	if end < 0 {
+		s.next = i
+		return -1, -1, ""
+	}
+	return i, i + len(mark) + end + size, string(rest[:end])
+}
+
+// output returns the command output captured so far and whether its
+// beginning was dropped.
+func (s *runScanner) output(raw []byte, base int) ([]byte, bool) {
+	if s.start < 0 {
+		return nil, false
+	}
+	end := base + len(raw)
+	if s.end >= 0 {
+		end = s.end
+	}
+	return raw[max(s.start, base)-base : end-base], s.start < base
+}
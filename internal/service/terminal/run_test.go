@@ -0,0 +1,160 @@
+package terminal
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunScanner(t *testing.T) {
+	s := newRunScanner("__VGS_t", "__VGE_t_", false)
+	raw := []byte("$  printf '__VGS_%s__\\n' t; ls\r\n__VGS_t__\r\nfile\r\n__V")
+	if s.scan(raw, 0) {
+		t.Fatal("expected command to be unfinished")
+	}
+	raw = append(raw, "GE_t_2__\r\n$ "...)
+	finished := s.scan(raw, 0)
+	if out, truncated := s.output(raw, 0); !finished || s.code != 2 || string(out) != "file\r\n" || truncated {
+		t.Errorf("unexpected scan result code=%d output=%q", s.code, out)
+	}
+
+	s = newRunScanner("\x1b]133;C", "\x1b]133;D", true)
+	raw = []byte("ls\r\n\x1b]133;C;cmdline_url=ls\x1b\\out\r\n\x1b]133;D;0\x07")
+	finished = s.scan(raw, 0)
+	if out, _ := s.output(raw, 0); !finished || s.code != 0 || string(out) != "out\r\n" {
+		t.Errorf("unexpected scan result code=%d output=%q", s.code, out)
+	}
+
+	// The tap dropped the start of the output.
+	s = newRunScanner("__VGS_t", "__VGE_t_", false)
+	raw = []byte("__VGS_t__\r\nline 1\r\n")
+	if s.scan(raw, 0) {
+		t.Fatal("expected command to be unfinished")
+	}
+	raw, base := []byte("line 2\r\n__VGE_t_0__\r\n"), len(raw)
+	if !s.scan(raw, base) {
+		t.Fatal("expected command to be finished")
+	}
+	if out, truncated := s.output(raw, base); string(out) != "line 2\r\n" || !truncated {
+		t.Errorf("expected the kept tail to be truncated, got %q (%v)", out, truncated)
+	}
+}
+
+func TestOutputTap_Bounded(t *testing.T) {
+	at := &activeTerminal{}
+	tap := at.addTap()
+	chunk := make([]byte, 64*1024)
+	for i := 0; i < 3*maxTapBuffer/len(chunk); i++ {
+		at.feedTaps(chunk)
+	}
+	buf, base := tap.bytes()
+	if len(buf) > 2*maxTapBuffer || base+len(buf) != 3*maxTapBuffer/len(chunk)*len(chunk) {
+		t.Errorf("expected the tap to keep a bounded tail, got %d bytes at %d", len(buf), base)
+	}
+}
+
+func TestManager_WriteInput(t *testing.T) {
+	db := setupTestDB(t)
+	manager := NewManager(db, &ManagerConfig{Shell: "/bin/sh"})
+
+	if err := manager.WriteInput("missing", []byte("x")); err != ErrTerminalNotFound {
+		t.Errorf("expected ErrTerminalNotFound, got %v", err)
+	}
+
+	info, err := manager.Create(CreateOptions{
+		Cwd:     os.TempDir(),
+		Command: "/bin/sh",
+		Args:    []string{"-c", `read line; printf 'got:%s' "$line"; sleep 1`},
+	})
+	if err != nil {
+		t.Fatalf("failed to create terminal: %v", err)
+	}
+	defer manager.Close(info.ID)
+
+	if err := manager.WriteInput(info.ID, []byte("abc\n")); err != nil {
+		t.Fatalf("WriteInput failed: %v", err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		dump, _ := manager.Screen(info.ID, false)
+		if strings.Contains(strings.Join(dump.Lines, "\n"), "got:abc") {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("input did not reach the process, screen: %q", dump.Lines)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestManager_RunSentinel(t *testing.T) {
+	db := setupTestDB(t)
+	manager := NewManager(db, &ManagerConfig{Shell: "/bin/sh"})
+
+	info, err := manager.Create(CreateOptions{Cwd: os.TempDir(), Command: "/bin/sh"})
+	if err != nil {
+		t.Fatalf("failed to create terminal: %v", err)
+	}
+	defer manager.Close(info.ID)
+
+	result, err := manager.Run(context.Background(), info.ID, "echo hello; echo world; false", RunOptions{Timeout: 3 * time.Second})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if result.Method != RunMethodSentinel || result.Output != "hello\nworld\n" || result.ExitCode != 1 || result.TimedOut {
+		t.Errorf("unexpected result %+v", result)
+	}
+
+	result, err = manager.Run(context.Background(), info.ID, "echo slow; sleep 5", RunOptions{Timeout: 300 * time.Millisecond, Interrupt: true})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if !result.TimedOut || result.ExitCode != -1 || result.Output != "slow\n" {
+		t.Errorf("expected timeout with partial output, got %+v", result)
+	}
+
+	result, err = manager.Run(context.Background(), info.ID, "echo again", RunOptions{Timeout: 3 * time.Second})
+	if err != nil || result.Output != "again\n" || result.ExitCode != 0 {
+		t.Errorf("expected terminal to be usable after interrupt, got %+v (%v)", result, err)
+	}
+}
+
+func TestManager_RunShellIntegration(t *testing.T) {
+	if _, err := os.Stat("/bin/bash"); err != nil {
+		t.Skip("bash not available")
+	}
+
+	db := setupTestDB(t)
+	manager := NewManager(db, &ManagerConfig{Shell: "/bin/sh"})
+
+	info, err := manager.Create(CreateOptions{
+		Cwd:              os.TempDir(),
+		Command:          "/bin/bash",
+		Env:              map[string]string{"HOME": t.TempDir()},
+		ShellIntegration: true,
+	})
+	if err != nil {
+		t.Fatalf("failed to create terminal: %v", err)
+	}
+	defer manager.Close(info.ID)
+
+	// Wait for the first prompt so the integration marks have been seen.
+	deadline := time.Now().Add(3 * time.Second)
+	at, _ := manager.getActive(info.ID)
+	for marked, _ := at.shell.integrated(); !marked; marked, _ = at.shell.integrated() {
+		if time.Now().After(deadline) {
+			t.Fatal("shell integration marks not seen")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	result, err := manager.Run(context.Background(), info.ID, "printf 'a\\nb\\n'; (exit 3)", RunOptions{Timeout: 3 * time.Second})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if result.Method != RunMethodShellIntegration || result.Output != "a\nb\n" || result.ExitCode != 3 {
+		t.Errorf("unexpected result %+v", result)
+	}
+}
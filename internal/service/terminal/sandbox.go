@@ -0,0 +1,66 @@
+package terminal
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/xxnuo/vibego/internal/model"
+)
+
+// SandboxEnv carries the JSON sandboxSpec of a VibeGo binary re-executed
+// to start a terminal's process under resource limits.
+const SandboxEnv = "VG_TERMINAL_SANDBOX"
+
+// sandboxFailed is the exit code of a launcher that could not apply the
+// limits. The reason is printed to the terminal.
+const sandboxFailed = 126
+
+// sandboxSpec describes the process a launcher starts.
+type sandboxSpec struct {
+	Command string               `json:"command"`
+	Args    []string             `json:"args"`
+	Limits  model.TerminalLimits `json:"limits"`
+	Cgroup  string               `json:"cgroup,omitempty"`
+	// Workspace is the only writable directory in the sandbox. ReadOnly
+	// lists extra paths the process needs, such as shell integration
+	// scripts.
+	Workspace string   `json:"workspace"`
+	ReadOnly  []string `json:"read_only,omitempty"`
+	// Isolated is set on the launcher started inside the namespaces, which
+	// stays their init process.
+	Isolated bool `json:"isolated,omitempty"`
+}
+
+// IsSandbox reports whether this process was started as a terminal
+// launcher and should call RunSandbox instead of serving.
+func IsSandbox() bool {
+	return os.Getenv(SandboxEnv) != ""
+}
+
+// RunSandbox applies the spec in SandboxEnv and executes the terminal's
+// process. It returns only on failure.
+func RunSandbox() int {
+	var spec sandboxSpec
+	err := json.Unmarshal([]byte(os.Getenv(SandboxEnv)), &spec)
+	os.Unsetenv(SandboxEnv)
+	if err == nil {
+		err = spec.run()
+	}
+	fmt.Fprintf(os.Stderr, "vibego: sandbox: %v\r\n", err)
+	return sandboxFailed
+}
+
+// launcher returns the program and environment that start spec through a
+// re-executed VibeGo binary.
+func (spec *sandboxSpec) launcher() (string, string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return "", "", err
+	}
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return "", "", err
+	}
+	return exe, SandboxEnv + "=" + string(data), nil
+}
@@ -0,0 +1,414 @@
+package terminal
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/xxnuo/vibego/internal/model"
+	"golang.org/x/sys/unix"
+)
+
+// sandboxSupported reports whether terminals can be started under limits
+// on this platform.
+const sandboxSupported = true
+
+// sandboxOldRoot is where the host's root stays reachable while the
+// sandbox's root is assembled.
+const sandboxOldRoot = "/oldroot"
+
+// sandboxSystemDirs are exposed read-only in the sandbox when present.
+var sandboxSystemDirs = []string{"/bin", "/sbin", "/lib", "/lib32", "/lib64", "/libx32", "/usr", "/etc", "/opt"}
+
+// sandboxDevices are the only device nodes of the host exposed in the
+// sandbox, besides the terminal itself.
+var sandboxDevices = []string{"/dev/null", "/dev/zero", "/dev/full", "/dev/random", "/dev/urandom", "/dev/tty"}
+
+// sandboxStatusFd is where the launcher inside the namespaces reports the
+// wait status of the terminal's process.
+const sandboxStatusFd = 3
+
+func (spec *sandboxSpec) run() error {
+	if spec.Isolated {
+		return spec.runInit()
+	}
+	if spec.Cgroup != "" {
+		procs := filepath.Join(spec.Cgroup, "cgroup.procs")
+		if err := os.WriteFile(procs, []byte(strconv.Itoa(os.Getpid())), 0644); err != nil {
+			return fmt.Errorf("join cgroup: %w", err)
+		}
+	}
+	if spec.Limits.Sandbox {
+		return spec.runIsolated()
+	}
+	return spec.exec()
+}
+
+// exec sets the rlimits and replaces the launcher with the terminal's
+// process.
+func (spec *sandboxSpec) exec() error {
+	if err := setRlimits(spec.Limits); err != nil {
+		return err
+	}
+	path, err := exec.LookPath(spec.Command)
+	if err != nil {
+		return err
+	}
+	return syscall.Exec(path, append([]string{spec.Command}, spec.Args...), os.Environ())
+}
+
+func setRlimits(limits model.TerminalLimits) error {
+	for _, l := range []struct {
+		resource int
+		value    int64
+		name     string
+	}{
+		{unix.RLIMIT_CPU, limits.CPUTime, "cpu time"},
+		{unix.RLIMIT_AS, limits.AddressSpace, "address space"},
+		{unix.RLIMIT_NOFILE, limits.OpenFiles, "open files"},
+		{unix.RLIMIT_NPROC, limits.Processes, "process"},
+	} {
+		if l.value <= 0 {
+			continue
+		}
+		var rlim syscall.Rlimit
+		if err := syscall.Getrlimit(l.resource, &rlim); err != nil {
+			return err
+		}
+		soft, hard := uint64(l.value), uint64(l.value)
+		if l.resource == unix.RLIMIT_CPU {
+			// The soft limit raises SIGXCPU, which tells the exit apart
+			// from other kills. The kernel sends SIGKILL a second later.
+			hard++
+		}
+		// Limits are only ever lowered.
+		rlim.Cur, rlim.Max = min(soft, rlim.Max), min(hard, rlim.Max)
+		if err := syscall.Setrlimit(l.resource, &rlim); err != nil {
+			return fmt.Errorf("set %s limit: %w", l.name, err)
+		}
+	}
+	return nil
+}
+
+// runIsolated starts the launcher again in new namespaces.
+func (spec *sandboxSpec) runIsolated() error {
+	inner := *spec
+	inner.Isolated = true
+	exe, env, err := inner.launcher()
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(exe)
+	cmd.Env = append(os.Environ(), env)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	status, statusWriter, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	cmd.ExtraFiles = []*os.File{statusWriter}
+	cmd.SysProcAttr = &syscall.SysProcAttr{Cloneflags: syscall.CLONE_NEWNS | syscall.CLONE_NEWPID, Pdeathsig: syscall.SIGKILL}
+	if uid := os.Getuid(); uid != 0 {
+		gid := os.Getgid()
+		cmd.SysProcAttr.Cloneflags |= syscall.CLONE_NEWUSER
+		cmd.SysProcAttr.UidMappings = []syscall.SysProcIDMap{{ContainerID: uid, HostID: uid, Size: 1}}
+		cmd.SysProcAttr.GidMappings = []syscall.SysProcIDMap{{ContainerID: gid, HostID: gid, Size: 1}}
+		// Mounting and dropping the bounding set need capabilities in the
+		// namespace, which a user other than root only keeps across exec
+		// as ambient ones.
+		cmd.SysProcAttr.AmbientCaps = []uintptr{unix.CAP_SYS_ADMIN, unix.CAP_SETPCAP}
+	}
+	// Pdeathsig fires when the thread that started the child exits.
+	runtime.LockOSThread()
+
+	// The terminal delivers SIGINT and SIGQUIT to the child as well, and
+	// the launcher stands in for it as the session leader for the rest.
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGQUIT, syscall.SIGHUP, syscall.SIGTERM)
+	err = cmd.Start()
+	statusWriter.Close()
+	if err != nil {
+		return err
+	}
+	go forwardSignals(signals, cmd.Process)
+	report, _ := io.ReadAll(status)
+	cmd.Wait()
+	// Without a report the inner launcher failed, and its own exit is
+	// the one to pass on.
+	ws, _ := cmd.ProcessState.Sys().(syscall.WaitStatus)
+	if n, err := strconv.Atoi(string(report)); err == nil {
+		ws = syscall.WaitStatus(n)
+	}
+	exitLike(ws)
+	return nil
+}
+
+// forwardSignals passes the hangup and termination signals a launcher
+// receives on to its child.
+func forwardSignals(signals <-chan os.Signal, child *os.Process) {
+	for sig := range signals {
+		if sig == syscall.SIGHUP || sig == syscall.SIGTERM {
+			child.Signal(sig)
+		}
+	}
+}
+
+// runInit is the init process of the sandbox's PID namespace.
+func (spec *sandboxSpec) runInit() error {
+	// Capabilities belong to the thread, which must be the one starting
+	// the process.
+	runtime.LockOSThread()
+	syscall.CloseOnExec(sandboxStatusFd)
+	status := os.NewFile(sandboxStatusFd, "status")
+	if err := spec.buildRoot(); err != nil {
+		return err
+	}
+	if err := dropCapabilities(); err != nil {
+		return err
+	}
+	if err := setRlimits(spec.Limits); err != nil {
+		return err
+	}
+	path, err := exec.LookPath(spec.Command)
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(path, spec.Args...)
+	cmd.Args[0] = spec.Command
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGQUIT, syscall.SIGHUP, syscall.SIGTERM)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	go forwardSignals(signals, cmd.Process)
+	for {
+		var ws syscall.WaitStatus
+		pid, err := syscall.Wait4(-1, &ws, 0, nil)
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if pid == cmd.Process.Pid {
+			fmt.Fprint(status, int(ws))
+			os.Exit(0)
+		}
+	}
+}
+
+// dropCapabilities empties the bounding and inheritable sets and sets
+// no_new_privs.
+func dropCapabilities() error {
+	last := unix.CAP_LAST_CAP
+	if data, err := os.ReadFile("/proc/sys/kernel/cap_last_cap"); err == nil {
+		fmt.Sscan(string(data), &last)
+	}
+	for c := 0; c <= last; c++ {
+		if err := unix.Prctl(unix.PR_CAPBSET_DROP, uintptr(c), 0, 0, 0); err != nil {
+			return fmt.Errorf("drop capabilities: %w", err)
+		}
+	}
+	// Clearing the inheritable set clears the ambient one as well.
+	hdr := unix.CapUserHeader{Version: unix.LINUX_CAPABILITY_VERSION_3}
+	var data [2]unix.CapUserData
+	if err := unix.Capget(&hdr, &data[0]); err != nil {
+		return fmt.Errorf("drop capabilities: %w", err)
+	}
+	data[0].Inheritable, data[1].Inheritable = 0, 0
+	if err := unix.Capset(&hdr, &data[0]); err != nil {
+		return fmt.Errorf("drop capabilities: %w", err)
+	}
+	return unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0)
+}
+
+// exitLike ends the launcher with the wait status of the terminal's
+// process, so that the terminal reports its exit code or signal.
+func exitLike(ws syscall.WaitStatus) {
+	if !ws.Signaled() {
+		os.Exit(ws.ExitStatus())
+	}
+	// The Go runtime handles most signals itself, so the default action is
+	// restored before raising the signal. The launcher's core is of no use
+	// and would land in the workspace.
+	syscall.Setrlimit(unix.RLIMIT_CORE, &syscall.Rlimit{})
+	var dfl [4]uint64 // struct sigaction with SIG_DFL, no flags, empty mask
+	unix.RawSyscall6(unix.SYS_RT_SIGACTION, uintptr(ws.Signal()), uintptr(unsafe.Pointer(&dfl)), 0, 8, 0, 0)
+	syscall.Kill(os.Getpid(), ws.Signal())
+	time.Sleep(time.Second)
+	os.Exit(128 + int(ws.Signal()))
+}
+
+// buildRoot replaces the root with a read-only tmpfs that only exposes the
+// workspace read-write.
+func (spec *sandboxSpec) buildRoot() error {
+	workspace, err := filepath.EvalSymlinks(spec.Workspace)
+	if err != nil {
+		return err
+	}
+	if workspace, err = filepath.Abs(workspace); err != nil {
+		return err
+	}
+	// The terminal is found through the host's /proc while it is still
+	// there.
+	tty, _ := os.Readlink("/proc/self/fd/0")
+
+	if err := unix.Mount("", "/", "", unix.MS_REC|unix.MS_PRIVATE, ""); err != nil {
+		return fmt.Errorf("make mounts private: %w", err)
+	}
+	// The new root is assembled on a tmpfs mounted over /tmp. Once it is
+	// the root, the host's /tmp is reachable again under the old root.
+	if err := unix.Mount("tmpfs", "/tmp", "tmpfs", unix.MS_NOSUID|unix.MS_NODEV, "mode=0755"); err != nil {
+		return fmt.Errorf("mount root: %w", err)
+	}
+	if err := os.Mkdir("/tmp"+sandboxOldRoot, 0700); err != nil {
+		return err
+	}
+	if err := unix.PivotRoot("/tmp", "/tmp"+sandboxOldRoot); err != nil {
+		return fmt.Errorf("pivot root: %w", err)
+	}
+	if err := os.Chdir("/"); err != nil {
+		return err
+	}
+
+	for _, dir := range sandboxSystemDirs {
+		if err := sandboxBind(dir, true, false); err != nil {
+			return err
+		}
+	}
+	// A procfs of the PID namespace only shows the sandbox's processes.
+	// It is mounted before the old root goes, as the kernel requires a
+	// procfs to be visible already.
+	if err := os.Mkdir("/proc", 0555); err != nil {
+		return err
+	}
+	if err := unix.Mount("proc", "/proc", "proc", unix.MS_NOSUID|unix.MS_NODEV|unix.MS_NOEXEC, ""); err != nil {
+		return fmt.Errorf("mount /proc: %w", err)
+	}
+	if err := buildDev(tty); err != nil {
+		return err
+	}
+	if err := os.Mkdir("/tmp", 0755); err != nil {
+		return err
+	}
+	if err := unix.Mount("tmpfs", "/tmp", "tmpfs", unix.MS_NOSUID|unix.MS_NODEV, "mode=1777"); err != nil {
+		return fmt.Errorf("mount /tmp: %w", err)
+	}
+	for _, path := range spec.ReadOnly {
+		if err := sandboxBind(path, true, false); err != nil {
+			return err
+		}
+	}
+	if err := sandboxBind(workspace, false, true); err != nil {
+		return err
+	}
+
+	if err := unix.Unmount(sandboxOldRoot, unix.MNT_DETACH); err != nil {
+		return fmt.Errorf("unmount old root: %w", err)
+	}
+	os.Remove(sandboxOldRoot)
+	if err := unix.Mount("", "/", "", unix.MS_BIND|unix.MS_REMOUNT|unix.MS_RDONLY|unix.MS_NOSUID|unix.MS_NODEV, ""); err != nil {
+		return fmt.Errorf("remount root: %w", err)
+	}
+	return os.Chdir(workspace)
+}
+
+// buildDev mounts a tmpfs on /dev holding the sandboxDevices, the
+// terminal tty and a private /dev/shm.
+func buildDev(tty string) error {
+	if err := os.Mkdir("/dev", 0755); err != nil {
+		return err
+	}
+	if err := unix.Mount("tmpfs", "/dev", "tmpfs", unix.MS_NOSUID|unix.MS_NOEXEC, "mode=0755"); err != nil {
+		return fmt.Errorf("mount /dev: %w", err)
+	}
+	devices := sandboxDevices
+	if strings.HasPrefix(tty, "/dev/pts/") {
+		devices = append(devices[:len(devices):len(devices)], tty)
+	}
+	for _, dev := range devices {
+		if err := sandboxBind(dev, false, false); err != nil {
+			return err
+		}
+	}
+	for name, target := range map[string]string{
+		"fd":     "/proc/self/fd",
+		"stdin":  "/proc/self/fd/0",
+		"stdout": "/proc/self/fd/1",
+		"stderr": "/proc/self/fd/2",
+	} {
+		if err := os.Symlink(target, filepath.Join("/dev", name)); err != nil {
+			return err
+		}
+	}
+	if err := os.Mkdir("/dev/shm", 0755); err != nil {
+		return err
+	}
+	if err := unix.Mount("tmpfs", "/dev/shm", "tmpfs", unix.MS_NOSUID|unix.MS_NODEV, "mode=1777"); err != nil {
+		return fmt.Errorf("mount /dev/shm: %w", err)
+	}
+	return nil
+}
+
+// sandboxBind mounts path of the host's root at the same place in the
+// sandbox. Symlinks, such as /bin on merged /usr systems, are recreated.
+func sandboxBind(path string, readOnly, required bool) error {
+	src := filepath.Join(sandboxOldRoot, path)
+	info, err := os.Lstat(src)
+	if os.IsNotExist(err) && !required {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Readlink(src)
+		if err != nil {
+			return err
+		}
+		return os.Symlink(target, path)
+	}
+	if info.IsDir() {
+		err = os.MkdirAll(path, 0755)
+	} else if _, err = os.Stat(path); os.IsNotExist(err) {
+		err = os.WriteFile(path, nil, 0644)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := unix.Mount(src, path, "", unix.MS_BIND|unix.MS_REC, ""); err != nil {
+		return fmt.Errorf("bind %s: %w", path, err)
+	}
+	if !readOnly {
+		return nil
+	}
+	attr := unix.MountAttr{Attr_set: unix.MOUNT_ATTR_RDONLY}
+	if err := unix.MountSetattr(unix.AT_FDCWD, path, unix.AT_RECURSIVE, &attr); err == nil {
+		return nil
+	}
+	// Before Linux 5.12 only the top mount can be made read-only, keeping
+	// the flags the host locked.
+	var st unix.Statfs_t
+	if err := unix.Statfs(path, &st); err != nil {
+		return err
+	}
+	locked := uintptr(st.Flags) & (unix.MS_NOSUID | unix.MS_NODEV | unix.MS_NOEXEC | unix.MS_NOATIME | unix.MS_NODIRATIME | unix.MS_RELATIME)
+	if err := unix.Mount("", path, "", unix.MS_BIND|unix.MS_REMOUNT|unix.MS_RDONLY|locked, ""); err != nil {
+		return fmt.Errorf("remount %s read-only: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package terminal
+
+import "errors"
+
+// sandboxSupported reports whether terminals can be started under limits
+// on this platform.
+const sandboxSupported = false
+
+func (spec *sandboxSpec) run() error {
+	return errors.New("resource limits require Linux")
+}
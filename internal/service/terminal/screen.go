@@ -0,0 +1,13 @@
+package terminal
+
+// ScreenDump is a plain-text rendering of a terminal's current screen.
+type ScreenDump struct {
+	Cols       int      `json:"cols"`
+	Rows       int      `json:"rows"`
+	CursorX    int      `json:"cursor_x"`
+	CursorY    int      `json:"cursor_y"`
+	AltScreen  bool     `json:"alt_screen"`
+	Title      string   `json:"title"`
+	Lines      []string `json:"lines"`
+	Scrollback []string `json:"scrollback,omitempty"`
+}
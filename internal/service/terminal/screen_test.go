@@ -0,0 +1,150 @@
+//go:build !windows
+
+package terminal
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestScreen_SnapshotRoundTrip(t *testing.T) {
+	src := newScreen(40, 6, 100)
+	for i := 0; i < 8; i++ {
+		src.Write([]byte(fmt.Sprintf("line %d\r\n", i)))
+	}
+	src.Write([]byte("\x1b[1;31mred\x1b[0m \x1b[44mblue\x1b[0m\x1b[?1h\x1b[?2004h\x1b]0;title\x07"))
+	src.Write([]byte("\x1b[2;5H\x1b[32m"))
+
+	dst := newScreen(40, 6, 100)
+	dst.Write(src.Snapshot())
+
+	want, got := src.Dump(true), dst.Dump(true)
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("snapshot did not reproduce the screen:\nwant %+v\ngot  %+v", want, got)
+	}
+	if src.vt.Mode() != dst.vt.Mode() {
+		t.Errorf("expected modes %b, got %b", src.vt.Mode(), dst.vt.Mode())
+	}
+	if !dst.bracketedPaste {
+		t.Error("expected bracketed paste mode to be restored")
+	}
+	for x := 0; x < 8; x++ {
+		if src.vt.Cell(x, 5) != dst.vt.Cell(x, 5) {
+			t.Errorf("cell %d differs: %+v vs %+v", x, src.vt.Cell(x, 5), dst.vt.Cell(x, 5))
+		}
+	}
+	if src.vt.Cursor().Attr != dst.vt.Cursor().Attr {
+		t.Errorf("expected cursor pen %+v, got %+v", src.vt.Cursor().Attr, dst.vt.Cursor().Attr)
+	}
+}
+
+func TestScreen_AltScreenSnapshot(t *testing.T) {
+	src := newScreen(20, 4, 100)
+	src.Write([]byte("$ vim\r\n\x1b[?1049h\x1b[H~\r\n~\x1b[4;1H-- INSERT --"))
+
+	dst := newScreen(20, 4, 100)
+	dst.Write(src.Snapshot())
+
+	dump := dst.Dump(false)
+	if !dump.AltScreen {
+		t.Fatal("expected snapshot to switch to the alternate screen")
+	}
+	if !reflect.DeepEqual(dump.Lines, src.Dump(false).Lines) {
+		t.Errorf("expected %q, got %q", src.Dump(false).Lines, dump.Lines)
+	}
+
+	// Leaving the alternate screen brings back the primary one.
+	src.Write([]byte("\x1b[?1049l"))
+	dst.Write([]byte("\x1b[?1049l"))
+	if want, got := src.Dump(false), dst.Dump(false); !reflect.DeepEqual(want, got) {
+		t.Errorf("expected the primary screen %+v, got %+v", want, got)
+	}
+	if line := dst.Dump(false).Lines[0]; line != "$ vim" {
+		t.Errorf("expected the primary screen to be kept, got %q", line)
+	}
+}
+
+func TestScreen_Scrollback(t *testing.T) {
+	scr := newScreen(20, 5, 10)
+	for i := 0; i < 30; i++ {
+		scr.Write([]byte(fmt.Sprintf("line %d\r\n", i)))
+	}
+
+	dump := scr.Dump(true)
+	if len(dump.Scrollback) != 10 {
+		t.Fatalf("expected scrollback capped at 10 lines, got %d", len(dump.Scrollback))
+	}
+	if dump.Scrollback[0] != "line 16" || dump.Scrollback[9] != "line 25" {
+		t.Errorf("unexpected scrollback %q", dump.Scrollback)
+	}
+	if dump.Lines[0] != "line 26" || dump.Lines[4] != "" {
+		t.Errorf("unexpected screen %q", dump.Lines)
+	}
+}
+
+func TestScreen_SplitUTF8(t *testing.T) {
+	scr := newScreen(20, 2, 0)
+	text := []byte("héllo 日本")
+	for _, split := range []int{2, 10} {
+		scr.Write(text[:split])
+		text = text[split:]
+	}
+	scr.Write(text)
+
+	if line := scr.Dump(false).Lines[0]; line != "héllo 日本" {
+		t.Errorf("expected 'héllo 日本', got %q", line)
+	}
+}
+
+func TestScreen_SplitAltScreen(t *testing.T) {
+	scr := newScreen(20, 3, 0)
+	scr.Write([]byte("$ top\r\n\x1b[?10"))
+	scr.Write([]byte("49h\x1b[Hload"))
+
+	dst := newScreen(20, 3, 0)
+	dst.Write(scr.Snapshot())
+	dst.Write([]byte("\x1b[?1049l"))
+	if line := dst.Dump(false).Lines[0]; line != "$ top" {
+		t.Errorf("expected the primary screen to be kept, got %q", line)
+	}
+}
+
+func TestManager_ScreenClosedSession(t *testing.T) {
+	db := setupTestDB(t)
+	manager := NewManager(db, &ManagerConfig{Shell: "/bin/sh"})
+
+	info, err := manager.Create(CreateOptions{
+		Cwd:     os.TempDir(),
+		Command: "/bin/sh",
+		Args:    []string{"-c", "printf 'first\\nsecond'; sleep 0.3"},
+	})
+	if err != nil {
+		t.Fatalf("failed to create terminal: %v", err)
+	}
+
+	time.Sleep(200 * time.Millisecond)
+	dump, err := manager.Screen(info.ID, false)
+	if err != nil {
+		t.Fatalf("Screen failed: %v", err)
+	}
+	if dump.Lines[0] != "first" || dump.Lines[1] != "second" {
+		t.Errorf("unexpected live screen %q", dump.Lines[:2])
+	}
+
+	manager.Close(info.ID)
+	dump, err = manager.Screen(info.ID, false)
+	if err != nil {
+		t.Fatalf("Screen failed for closed session: %v", err)
+	}
+	if !strings.HasPrefix(strings.Join(dump.Lines, "\n"), "first\nsecond") {
+		t.Errorf("unexpected closed screen %q", dump.Lines[:2])
+	}
+
+	if _, err := manager.Screen("missing", false); err != ErrTerminalNotFound {
+		t.Errorf("expected ErrTerminalNotFound, got %v", err)
+	}
+}
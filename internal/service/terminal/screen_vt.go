@@ -0,0 +1,375 @@
+//go:build !windows
+
+package terminal
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/hinshun/vt10x"
+)
+
+// Glyph attribute bits as laid out by vt10x.
+const (
+	glyphReverse   = 1 << 0
+	glyphUnderline = 1 << 1
+	glyphBold      = 1 << 2
+	glyphItalic    = 1 << 4
+	glyphBlink     = 1 << 5
+)
+
+var (
+	bracketedPasteOn  = []byte("\x1b[?2004h")
+	bracketedPasteOff = []byte("\x1b[?2004l")
+)
+
+// maxScreenPending bounds the output held back for an unfinished escape
+// sequence, so that a stray ESC cannot stall the screen.
+const maxScreenPending = 4096
+
+// altScreenOn lists the sequences switching to the alternate screen.
+var altScreenOn = [][]byte{[]byte("\x1b[?1049h"), []byte("\x1b[?1047h"), []byte("\x1b[?47h")}
+
+// indexAltScreenOn returns the index and length of the first sequence in p
+// switching to the alternate screen, or -1.
+func indexAltScreenOn(p []byte) (int, int) {
+	index, length := -1, 0
+	for _, seq := range altScreenOn {
+		if i := bytes.Index(p, seq); i >= 0 && (index < 0 || i < index) {
+			index, length = i, len(seq)
+		}
+	}
+	return index, length
+}
+
+// screen keeps a headless emulator in sync with the PTY output for attach
+// snapshots.
+type screen struct {
+	vt            vt10x.Terminal
+	scrollback    [][]vt10x.Glyph
+	maxScrollback int
+	// primary holds the primary screen while the alternate one is shown,
+	// as vt10x only exposes the screen in use.
+	primary        [][]vt10x.Glyph
+	primaryCursor  vt10x.Cursor
+	partial        []byte
+	bracketedPaste bool
+	mu             sync.Mutex
+}
+
+func newScreen(cols, rows, scrollback int) *screen {
+	return &screen{
+		vt:            vt10x.New(vt10x.WithSize(cols, rows)),
+		maxScrollback: scrollback,
+	}
+}
+
+func (s *screen) Write(p []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.partial) > 0 {
+		p = append(s.partial, p...)
+		s.partial = nil
+	}
+	// An escape or UTF-8 sequence split across reads is held back until
+	// the rest of it arrives, so that vt10x decodes it and the sequences
+	// looked for below are seen whole.
+	complete, pending := splitEscape(p)
+	if len(pending) == 0 {
+		complete, pending = splitUTF8(complete)
+	}
+	if len(pending) > maxScreenPending {
+		complete, pending = p, nil
+	}
+	if len(pending) > 0 {
+		s.partial = append([]byte(nil), pending...)
+	}
+	p = complete
+
+	if i := bytes.LastIndex(p, bracketedPasteOn); i >= 0 {
+		s.bracketedPaste = !bytes.Contains(p[i:], bracketedPasteOff)
+	} else if bytes.Contains(p, bracketedPasteOff) {
+		s.bracketedPaste = false
+	}
+
+	for len(p) > 0 {
+		i, n := indexAltScreenOn(p)
+		if i < 0 {
+			s.writeLines(p)
+			return
+		}
+		s.writeLines(p[:i])
+		s.savePrimary()
+		s.vt.Write(p[i : i+n])
+		p = p[i+n:]
+	}
+}
+
+// writeLines feeds the emulator, capturing lines before they scroll off, as
+// vt10x has no scrollback.
+func (s *screen) writeLines(p []byte) {
+	for len(p) > 0 {
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			s.vt.Write(p)
+			return
+		}
+		s.vt.Write(p[:i])
+		s.captureScroll()
+		s.vt.Write(p[i : i+1])
+		p = p[i+1:]
+	}
+}
+
+// savePrimary keeps the primary screen before the alternate one replaces
+// it.
+func (s *screen) savePrimary() {
+	if s.vt.Mode()&vt10x.ModeAltScreen != 0 {
+		return
+	}
+	s.primary = s.lines()
+	s.primaryCursor = s.vt.Cursor()
+}
+
+// lines returns the rows of the screen in use without trailing blanks.
+func (s *screen) lines() [][]vt10x.Glyph {
+	cols, rows := s.vt.Size()
+	lines := make([][]vt10x.Glyph, rows)
+	line := make([]vt10x.Glyph, cols)
+	for y := range lines {
+		for x := range line {
+			line[x] = s.vt.Cell(x, y)
+		}
+		lines[y] = trimGlyphs(line)
+	}
+	return lines
+}
+
+func (s *screen) captureScroll() {
+	if s.maxScrollback <= 0 || s.vt.Mode()&vt10x.ModeAltScreen != 0 {
+		return
+	}
+	cols, rows := s.vt.Size()
+	if s.vt.Cursor().Y != rows-1 {
+		return
+	}
+	line := make([]vt10x.Glyph, cols)
+	for x := range line {
+		line[x] = s.vt.Cell(x, 0)
+	}
+	s.scrollback = append(s.scrollback, trimGlyphs(line))
+	if over := len(s.scrollback) - s.maxScrollback; over > 0 {
+		s.scrollback = append(s.scrollback[:0], s.scrollback[over:]...)
+	}
+}
+
+func (s *screen) Resize(cols, rows int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.vt.Resize(cols, rows)
+}
+
+// Snapshot serializes the scrollback, screen, cursor and modes as an escape
+// sequence stream.
+func (s *screen) Snapshot() []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var b bytes.Buffer
+	b.WriteString("\x1bc")
+
+	for _, line := range s.scrollback {
+		writeGlyphs(&b, line)
+		b.WriteString("\x1b[0m\r\n")
+	}
+
+	// The primary screen goes first so that leaving the alternate screen
+	// brings it back.
+	mode := s.vt.Mode()
+	if mode&vt10x.ModeAltScreen != 0 {
+		writeLines(&b, s.primary)
+		cur := s.primaryCursor
+		b.WriteString("\x1b[" + strconv.Itoa(cur.Y+1) + ";" + strconv.Itoa(cur.X+1) + "H")
+		b.WriteString("\x1b[?1049h\x1b[H")
+	}
+	writeLines(&b, s.lines())
+
+	writeModes(&b, mode)
+	if s.bracketedPaste {
+		b.Write(bracketedPasteOn)
+	}
+	if title := s.vt.Title(); title != "" {
+		b.WriteString("\x1b]0;" + title + "\x07")
+	}
+
+	cur := s.vt.Cursor()
+	b.WriteString("\x1b[" + strconv.Itoa(cur.Y+1) + ";" + strconv.Itoa(cur.X+1) + "H")
+	b.WriteString(sgr(cur.Attr, true))
+
+	return b.Bytes()
+}
+
+// Dump renders the screen as plain text, optionally with the scrollback.
+func (s *screen) Dump(scrollback bool) *ScreenDump {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cols, rows := s.vt.Size()
+	cur := s.vt.Cursor()
+	dump := &ScreenDump{
+		Cols:      cols,
+		Rows:      rows,
+		CursorX:   cur.X,
+		CursorY:   cur.Y,
+		AltScreen: s.vt.Mode()&vt10x.ModeAltScreen != 0,
+		Title:     s.vt.Title(),
+		Lines:     make([]string, rows),
+	}
+
+	line := make([]vt10x.Glyph, cols)
+	for y := 0; y < rows; y++ {
+		for x := range line {
+			line[x] = s.vt.Cell(x, y)
+		}
+		dump.Lines[y] = glyphText(line)
+	}
+	if scrollback {
+		dump.Scrollback = make([]string, len(s.scrollback))
+		for i, l := range s.scrollback {
+			dump.Scrollback[i] = glyphText(l)
+		}
+	}
+	return dump
+}
+
+// writeLines writes the rows of a screen, leaving the cursor on the last
+// one.
+func writeLines(b *bytes.Buffer, lines [][]vt10x.Glyph) {
+	for y, line := range lines {
+		if y > 0 {
+			b.WriteString("\x1b[0m\r\n")
+		}
+		writeGlyphs(b, line)
+	}
+	b.WriteString("\x1b[0m")
+}
+
+func isBlankGlyph(g vt10x.Glyph) bool {
+	return (g.Char == ' ' || g.Char == 0) && g.BG == vt10x.DefaultBG && g.Mode&glyphUnderline == 0
+}
+
+// trimGlyphs returns a copy of line without trailing blank cells.
+func trimGlyphs(line []vt10x.Glyph) []vt10x.Glyph {
+	end := len(line)
+	for end > 0 && isBlankGlyph(line[end-1]) {
+		end--
+	}
+	return append([]vt10x.Glyph(nil), line[:end]...)
+}
+
+func glyphText(line []vt10x.Glyph) string {
+	var b strings.Builder
+	for _, g := range line {
+		if g.Char == 0 {
+			b.WriteByte(' ')
+		} else {
+			b.WriteRune(g.Char)
+		}
+	}
+	return strings.TrimRight(b.String(), " ")
+}
+
+func writeGlyphs(b *bytes.Buffer, line []vt10x.Glyph) {
+	last := ""
+	for _, g := range line {
+		if attr := sgr(g, false); attr != last {
+			b.WriteString(attr)
+			last = attr
+		}
+		if g.Char == 0 {
+			b.WriteByte(' ')
+		} else {
+			b.WriteRune(g.Char)
+		}
+	}
+}
+
+// sgr returns the escape sequence selecting the rendition of g.
+func sgr(g vt10x.Glyph, reverse bool) string {
+	params := []string{"0"}
+	if g.Mode&glyphBold != 0 {
+		params = append(params, "1")
+	}
+	if g.Mode&glyphItalic != 0 {
+		params = append(params, "3")
+	}
+	if g.Mode&glyphUnderline != 0 {
+		params = append(params, "4")
+	}
+	if g.Mode&glyphBlink != 0 {
+		params = append(params, "5")
+	}
+	if reverse && g.Mode&glyphReverse != 0 {
+		params = append(params, "7")
+	}
+	params = appendColor(params, g.FG, vt10x.DefaultFG, 30, 90, "38")
+	params = appendColor(params, g.BG, vt10x.DefaultBG, 40, 100, "48")
+	return "\x1b[" + strings.Join(params, ";") + "m"
+}
+
+func appendColor(params []string, c, def vt10x.Color, base, bright int, extended string) []string {
+	switch {
+	case c == def:
+		return params
+	case c < 8:
+		return append(params, strconv.Itoa(base+int(c)))
+	case c < 16:
+		return append(params, strconv.Itoa(bright+int(c)-8))
+	case c < 256:
+		return append(params, extended, "5", strconv.Itoa(int(c)))
+	}
+	return params
+}
+
+func writeModes(b *bytes.Buffer, mode vt10x.ModeFlag) {
+	if mode&vt10x.ModeWrap == 0 {
+		b.WriteString("\x1b[?7l")
+	}
+	if mode&vt10x.ModeInsert != 0 {
+		b.WriteString("\x1b[4h")
+	}
+	if mode&vt10x.ModeCRLF != 0 {
+		b.WriteString("\x1b[20h")
+	}
+	if mode&vt10x.ModeReverse != 0 {
+		b.WriteString("\x1b[?5h")
+	}
+	if mode&vt10x.ModeAppCursor != 0 {
+		b.WriteString("\x1b[?1h")
+	}
+	if mode&vt10x.ModeAppKeypad != 0 {
+		b.WriteString("\x1b=")
+	}
+	if mode&vt10x.ModeHide != 0 {
+		b.WriteString("\x1b[?25l")
+	}
+	switch {
+	case mode&vt10x.ModeMouseX10 != 0:
+		b.WriteString("\x1b[?9h")
+	case mode&vt10x.ModeMouseButton != 0:
+		b.WriteString("\x1b[?1000h")
+	case mode&vt10x.ModeMouseMotion != 0:
+		b.WriteString("\x1b[?1002h")
+	case mode&vt10x.ModeMouseMany != 0:
+		b.WriteString("\x1b[?1003h")
+	}
+	if mode&vt10x.ModeMouseSgr != 0 {
+		b.WriteString("\x1b[?1006h")
+	}
+	if mode&vt10x.ModeFocus != 0 {
+		b.WriteString("\x1b[?1004h")
+	}
+}
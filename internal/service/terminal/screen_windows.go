@@ -0,0 +1,70 @@
+package terminal
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+)
+
+// screen replays recent output as the snapshot on Windows, where vt10x
+// does not build.
+type screen struct {
+	cols, rows int
+	maxBytes   int
+	data       []byte
+	mu         sync.Mutex
+}
+
+func newScreen(cols, rows, scrollback int) *screen {
+	return &screen{
+		cols:     cols,
+		rows:     rows,
+		maxBytes: max(cols*(rows+scrollback)*4, 64*1024),
+	}
+}
+
+func (s *screen) Write(p []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data = append(s.data, p...)
+	if over := len(s.data) - s.maxBytes; over > 0 {
+		// The replay starts on a line boundary where possible.
+		if i := bytes.IndexByte(s.data[over:], '\n'); i >= 0 {
+			over += i + 1
+		}
+		s.data = append(s.data[:0], s.data[over:]...)
+	}
+}
+
+func (s *screen) Resize(cols, rows int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cols, s.rows = cols, rows
+}
+
+func (s *screen) Snapshot() []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]byte("\x1bc"), s.data...)
+}
+
+func (s *screen) Dump(scrollback bool) *ScreenDump {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	lines := strings.Split(string(stripANSI(s.data)), "\n")
+	dump := &ScreenDump{
+		Cols:    s.cols,
+		Rows:    s.rows,
+		CursorX: len([]rune(lines[len(lines)-1])),
+		Lines:   make([]string, s.rows),
+	}
+	start := max(len(lines)-s.rows, 0)
+	dump.CursorY = len(lines) - 1 - start
+	copy(dump.Lines, lines[start:])
+	if scrollback {
+		dump.Scrollback = lines[:start]
+	}
+	return dump
+}
@@ -0,0 +1,128 @@
+package terminal
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+
+	"github.com/xxnuo/vibego/internal/model"
+)
+
+const (
+	maxSearchContext   = 20
+	defaultSearchLimit = 100
+	maxSearchLimit     = 1000
+)
+
+// historySegment is a contiguous run of output starting at stream offset
+// start.
+type historySegment struct {
+	start int64
+	data  []byte
+}
+
+// Search finds Query in a terminal's persisted and buffered output with
+// escape sequences stripped.
+func (m *Manager) Search(id string, opts SearchOptions) (*SearchResult, error) {
+	pattern := opts.Query
+	if !opts.Regex {
+		pattern = regexp.QuoteMeta(pattern)
+	}
+	if opts.IgnoreCase {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if opts.Query == "" || err != nil {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidQuery, opts.Query)
+	}
+
+	context := min(max(opts.Context, 0), maxSearchContext)
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+	limit = min(limit, maxSearchLimit)
+
+	segments, err := m.historySegments(id)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &SearchResult{Matches: []SearchMatch{}}
+	for _, seg := range segments {
+		if searchSegment(result, seg, re, context, limit) {
+			result.Truncated = true
+			break
+		}
+	}
+	return result, nil
+}
+
+func (m *Manager) historySegments(id string) ([]historySegment, error) {
+	at, active := m.getActive(id)
+	if !active {
+		var session model.TerminalSession
+		if err := m.db.First(&session, "id = ?", id).Error; err != nil {
+			return nil, ErrTerminalNotFound
+		}
+	}
+
+	stored, storedStart, err := m.loadHistoryFromDB(id)
+	if err != nil {
+		return nil, err
+	}
+	if !active {
+		return []historySegment{{start: storedStart, data: stored}}, nil
+	}
+
+	buffered, bufferedStart, _ := at.historyBuffer.ReadSince(0)
+	if len(stored) == 0 || bufferedStart <= storedStart {
+		return []historySegment{{start: bufferedStart, data: buffered}}, nil
+	}
+	// The buffer takes over where it starts; anything stored before that is
+	// only on disk.
+	if keep := bufferedStart - storedStart; keep < int64(len(stored)) {
+		stored = stored[:keep]
+	}
+	return []historySegment{
+		{start: storedStart, data: stored},
+		{start: bufferedStart, data: buffered},
+	}, nil
+}
+
+// searchSegment appends the matches found in seg to result and reports
+// whether limit was reached with output left to search.
+func searchSegment(result *SearchResult, seg historySegment, re *regexp.Regexp, context, limit int) bool {
+	text, index := stripANSIIndex(seg.data, true)
+	lines := bytes.Split(text, []byte{'\n'})
+
+	lineStart := 0
+	for n, line := range lines {
+		for _, loc := range re.FindAllIndex(line, -1) {
+			if loc[0] == loc[1] {
+				continue
+			}
+			if len(result.Matches) == limit {
+				return true
+			}
+			result.Matches = append(result.Matches, SearchMatch{
+				Offset: seg.start + int64(index[lineStart+loc[0]]),
+				End:    seg.start + int64(index[lineStart+loc[1]-1]) + 1,
+				Line:   string(line),
+				Column: loc[0],
+				Before: lineStrings(lines[max(0, n-context):n]),
+				After:  lineStrings(lines[n+1 : min(len(lines), n+1+context)]),
+			})
+		}
+		lineStart += len(line) + 1
+	}
+	return false
+}
+
+func lineStrings(lines [][]byte) []string {
+	out := make([]string, len(lines))
+	for i, line := range lines {
+		out[i] = string(line)
+	}
+	return out
+}
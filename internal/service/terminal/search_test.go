@@ -0,0 +1,91 @@
+package terminal
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestManager_Search(t *testing.T) {
+	db := setupTestDB(t)
+	manager := NewManager(db, &ManagerConfig{Shell: "/bin/sh"})
+
+	info, err := manager.Create(CreateOptions{
+		Cwd:     os.TempDir(),
+		Command: "/bin/sh",
+		Args:    []string{"-c", `printf 'start\n\033[31mERROR\033[0m: disk full\nok\nanother error here\nend\n'; sleep 30`},
+	})
+	if err != nil {
+		t.Fatalf("failed to create terminal: %v", err)
+	}
+	defer manager.Close(info.ID)
+	at, _ := manager.getActive(info.ID)
+	waitScreen(t, manager, info.ID, "end")
+
+	result, err := manager.Search(info.ID, SearchOptions{Query: "ERROR", Context: 1})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(result.Matches) != 1 {
+		t.Fatalf("expected 1 case-sensitive match, got %+v", result.Matches)
+	}
+	match := result.Matches[0]
+	if match.Line != "ERROR: disk full" || match.Column != 0 {
+		t.Errorf("expected the match line with escapes stripped, got %q at %d", match.Line, match.Column)
+	}
+	if len(match.Before) != 1 || match.Before[0] != "start" || len(match.After) != 1 || match.After[0] != "ok" {
+		t.Errorf("unexpected context %q %q", match.Before, match.After)
+	}
+	raw := at.historyBuffer.Read()
+	if got := string(raw[match.Offset:match.End]); got != "ERROR" {
+		t.Errorf("expected offsets to point at the raw output, got %q", got)
+	}
+
+	result, _ = manager.Search(info.ID, SearchOptions{Query: "error", IgnoreCase: true})
+	if len(result.Matches) != 2 {
+		t.Errorf("expected 2 case-insensitive matches, got %d", len(result.Matches))
+	}
+	result, _ = manager.Search(info.ID, SearchOptions{Query: `(?i)err\w+`, Regex: true, Limit: 1})
+	if len(result.Matches) != 1 || !result.Truncated {
+		t.Errorf("expected the limit to truncate the results, got %+v", result)
+	}
+	if _, err := manager.Search(info.ID, SearchOptions{Query: "(", Regex: true}); !errors.Is(err, ErrInvalidQuery) {
+		t.Errorf("expected ErrInvalidQuery, got %v", err)
+	}
+	if _, err := manager.Search("nonexistent", SearchOptions{Query: "x"}); err != ErrTerminalNotFound {
+		t.Errorf("expected ErrTerminalNotFound, got %v", err)
+	}
+
+	manager.Close(info.ID)
+	result, err = manager.Search(info.ID, SearchOptions{Query: "disk full"})
+	if err != nil || len(result.Matches) != 1 {
+		t.Fatalf("expected to search the stored history of a closed session, got %+v (%v)", result, err)
+	}
+	if !strings.HasPrefix(result.Matches[0].Line, "ERROR") || result.Matches[0].End-result.Matches[0].Offset != int64(len("disk full")) {
+		t.Errorf("unexpected match %+v", result.Matches[0])
+	}
+}
+
+func TestHistorySegmentsPreferBuffer(t *testing.T) {
+	db := setupTestDB(t)
+	manager := NewManager(db, &ManagerConfig{Shell: "/bin/sh", HistoryFlushInterval: time.Hour})
+
+	info, err := manager.Create(CreateOptions{Cwd: os.TempDir(), Command: "/bin/sh", Args: []string{"-c", "echo stored; sleep 30"}})
+	if err != nil {
+		t.Fatalf("failed to create terminal: %v", err)
+	}
+	defer manager.Close(info.ID)
+	at, _ := manager.getActive(info.ID)
+	waitScreen(t, manager, info.ID, "stored")
+	manager.flushHistoryToDB(at)
+
+	segments, err := manager.historySegments(info.ID)
+	if err != nil {
+		t.Fatalf("historySegments failed: %v", err)
+	}
+	if len(segments) != 1 || segments[0].start != 0 || !strings.Contains(string(segments[0].data), "stored") {
+		t.Errorf("expected the buffer to cover the stored history, got %d segments", len(segments))
+	}
+}
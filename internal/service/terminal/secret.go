@@ -0,0 +1,118 @@
+package terminal
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// secretPrefix marks a stored credential encrypted by a secretBox.
+const secretPrefix = "enc:v1:"
+
+// secretBox encrypts saved credentials with AES-GCM under a key file kept
+// outside the database.
+type secretBox struct {
+	path string
+	once sync.Once
+	aead cipher.AEAD
+	err  error
+}
+
+func (b *secretBox) load() (cipher.AEAD, error) {
+	b.once.Do(func() {
+		key, err := readOrCreateKey(b.path)
+		if err != nil {
+			b.err = fmt.Errorf("secret key: %w", err)
+			return
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			b.err = fmt.Errorf("secret key: %w", err)
+			return
+		}
+		b.aead, b.err = cipher.NewGCM(block)
+	})
+	return b.aead, b.err
+}
+
+func readOrCreateKey(path string) ([]byte, error) {
+	key, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			return nil, err
+		}
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if errors.Is(err, os.ErrExist) {
+			// Another server created it first.
+			return readOrCreateKey(path)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if _, err := f.Write(key); err != nil {
+			f.Close()
+			return nil, err
+		}
+		return key, f.Close()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("%s does not hold a 256-bit key", path)
+	}
+	return key, nil
+}
+
+// seal encrypts a credential for storage.
+func (b *secretBox) seal(plain string) (string, error) {
+	if plain == "" || b.path == "" {
+		return plain, nil
+	}
+	aead, err := b.load()
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(plain), nil)
+	return secretPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// open decrypts a stored credential. Credentials stored before a key was
+// configured are returned as they are.
+func (b *secretBox) open(stored string) (string, error) {
+	encoded, ok := strings.CutPrefix(stored, secretPrefix)
+	if !ok {
+		return stored, nil
+	}
+	if b.path == "" {
+		return "", errors.New("secret key: credential is encrypted but no key file is configured")
+	}
+	aead, err := b.load()
+	if err != nil {
+		return "", err
+	}
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", errors.New("secret key: malformed credential")
+	}
+	plain, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+	if err != nil {
+		return "", fmt.Errorf("secret key: %w", err)
+	}
+	return string(plain), nil
+}
@@ -0,0 +1,249 @@
+package terminal
+
+import (
+	"bytes"
+	"embed"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+//go:embed shellintegration
+var shellIntegrationFS embed.FS
+
+// shellIntegrationFiles maps the embedded snippets to the names the shells
+// load them under.
+var shellIntegrationFiles = map[string]string{
+	"bash.sh":      "bash.sh",
+	"fish.fish":    "fish.fish",
+	"zshenv.zsh":   "zsh/.zshenv",
+	"zprofile.zsh": "zsh/.zprofile",
+	"zshrc.zsh":    "zsh/.zshrc",
+}
+
+var (
+	shellIntegrationOnce sync.Once
+	shellIntegrationPath string
+	shellIntegrationErr  error
+)
+
+// shellIntegrationDir writes the integration snippets to a fresh private
+// directory once per process.
+func shellIntegrationDir() (string, error) {
+	shellIntegrationOnce.Do(func() {
+		dir, err := os.MkdirTemp("", "vibego-shell-")
+		if err != nil {
+			shellIntegrationErr = err
+			return
+		}
+		for src, dst := range shellIntegrationFiles {
+			data, err := shellIntegrationFS.ReadFile("shellintegration/" + src)
+			if err != nil {
+				shellIntegrationErr = err
+				return
+			}
+			path := filepath.Join(dir, dst)
+			if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+				shellIntegrationErr = err
+				return
+			}
+			if err := os.WriteFile(path, data, 0600); err != nil {
+				shellIntegrationErr = err
+				return
+			}
+		}
+		shellIntegrationPath = dir
+	})
+	return shellIntegrationPath, shellIntegrationErr
+}
+
+// injectShellIntegration makes bash, zsh and fish load the integration
+// snippets.
+func (lc *localCommand) injectShellIntegration() error {
+	if len(lc.argv) > 0 {
+		return nil
+	}
+	shell := filepath.Base(lc.command)
+	if shell != "bash" && shell != "zsh" && shell != "fish" {
+		return nil
+	}
+
+	dir, err := shellIntegrationDir()
+	if err != nil {
+		return err
+	}
+
+	switch shell {
+	case "bash":
+		lc.argv = []string{"--init-file", filepath.Join(dir, "bash.sh")}
+	case "zsh":
+		if zdotdir := os.Getenv("ZDOTDIR"); zdotdir != "" {
+			lc.env = append(lc.env, "VIBEGO_USER_ZDOTDIR="+zdotdir)
+		}
+		lc.env = append(lc.env, "ZDOTDIR="+filepath.Join(dir, "zsh"))
+	case "fish":
+		lc.argv = []string{"--init-command", "source " + filepath.Join(dir, "fish.fish")}
+	}
+	return nil
+}
+
+const maxOSCLength = 4096
+
+// oscParser extracts OSC sequences from a byte stream. It keeps its state
+// between calls so sequences split across reads are still recognized.
+type oscParser struct {
+	state int
+	buf   []byte
+}
+
+const (
+	oscGround = iota
+	oscEscape
+	oscString
+	oscStringEscape
+)
+
+// Feed calls fn with the payload of every complete OSC sequence and reports
+// whether a BEL rang outside of one.
+func (p *oscParser) Feed(data []byte, fn func(payload []byte)) bool {
+	bell := false
+	for _, b := range data {
+		switch p.state {
+		case oscGround:
+			switch b {
+			case 0x1b:
+				p.state = oscEscape
+			case 0x07:
+				bell = true
+			}
+		case oscEscape:
+			switch b {
+			case ']':
+				p.state = oscString
+				p.buf = p.buf[:0]
+			case 0x1b:
+			default:
+				p.state = oscGround
+			}
+		case oscString:
+			switch b {
+			case 0x07:
+				fn(p.buf)
+				p.state = oscGround
+			case 0x1b:
+				p.state = oscStringEscape
+			default:
+				if len(p.buf) >= maxOSCLength {
+					p.state = oscGround
+				} else {
+					p.buf = append(p.buf, b)
+				}
+			}
+		case oscStringEscape:
+			if b == '\\' {
+				fn(p.buf)
+				p.state = oscGround
+			} else if b == ']' {
+				p.state = oscString
+				p.buf = p.buf[:0]
+			} else {
+				p.state = oscGround
+			}
+		}
+	}
+	return bell
+}
+
+// shellState tracks what the shell integration marks report about a
+// terminal.
+type shellState struct {
+	mu             sync.Mutex
+	parser         oscParser
+	cwd            string
+	lastCommand    string
+	lastExitCode   int
+	commandRunning bool
+	marked         bool
+}
+
+// shellEvent is a command lifecycle change found in the output.
+type shellEvent struct {
+	start    bool
+	command  string
+	exitCode int
+	cwd      string
+}
+
+// Feed parses PTY output and returns the command lifecycle events it
+// contains, and whether the output rang the bell.
+func (s *shellState) Feed(data []byte) ([]shellEvent, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var events []shellEvent
+	bell := s.parser.Feed(data, func(payload []byte) {
+		if ev, ok := s.apply(payload); ok {
+			events = append(events, ev)
+		}
+	})
+	return events, bell
+}
+
+func (s *shellState) apply(payload []byte) (shellEvent, bool) {
+	if bytes.HasPrefix(payload, []byte("133;")) {
+		s.marked = true
+	}
+	switch {
+	case bytes.HasPrefix(payload, []byte("7;")):
+		if u, err := url.Parse(string(payload[2:])); err == nil && u.Scheme == "file" {
+			s.cwd = u.Path
+		}
+	case bytes.HasPrefix(payload, []byte("133;C")):
+		command := ""
+		for _, field := range strings.Split(string(payload), ";")[1:] {
+			if v, ok := strings.CutPrefix(field, "cmdline_url="); ok {
+				command, _ = url.PathUnescape(v)
+			}
+		}
+		s.lastCommand = command
+		s.commandRunning = true
+		return shellEvent{start: true, command: command, cwd: s.cwd}, true
+	case bytes.HasPrefix(payload, []byte("133;D")):
+		if !s.commandRunning {
+			return shellEvent{}, false
+		}
+		code := 0
+		if fields := strings.Split(string(payload), ";"); len(fields) > 2 {
+			code, _ = strconv.Atoi(fields[2])
+		}
+		s.lastExitCode = code
+		s.commandRunning = false
+		return shellEvent{command: s.lastCommand, exitCode: code, cwd: s.cwd}, true
+	}
+	return shellEvent{}, false
+}
+
+// integrated reports whether the shell has emitted integration marks, and
+// whether it is running a command.
+func (s *shellState) integrated() (marked, running bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.marked, s.commandRunning
+}
+
+// fill copies the live shell state onto info. The cwd is only replaced once
+// the shell has reported one.
+func (s *shellState) fill(info *TerminalInfo) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.cwd != "" {
+		info.Cwd = s.cwd
+	}
+	info.LastCommand = s.lastCommand
+	info.LastExitCode = s.lastExitCode
+	info.CommandRunning = s.commandRunning
+}
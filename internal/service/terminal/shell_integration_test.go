@@ -0,0 +1,172 @@
+package terminal
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOSCParser(t *testing.T) {
+	var p oscParser
+	var got []string
+	collect := func(payload []byte) { got = append(got, string(payload)) }
+
+	if p.Feed([]byte("plain \x1b[31mred\x1b]0;title\x07 \x1b]133;"), collect) {
+		t.Error("expected OSC terminator not to count as a bell")
+	}
+	if !p.Feed([]byte("A\x1b\\ \x1b]7;file://h/tmp\x1b\\\x07"), collect) {
+		t.Error("expected bell to be reported")
+	}
+
+	want := []string{"0;title", "133;A", "7;file://h/tmp"}
+	if !slices.Equal(got, want) {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestShellState(t *testing.T) {
+	var s shellState
+	events, _ := s.Feed([]byte("\x1b]7;file://host/tmp/a%20b\x07\x1b]133;A\x07$ \x1b]133;B\x07"))
+	if len(events) != 0 {
+		t.Fatalf("expected no events for prompt marks, got %+v", events)
+	}
+
+	events, _ = s.Feed([]byte("\x1b]133;C;cmdline_url=ls%20-la\x07output\r\n\x1b]133;D;2\x07"))
+	if len(events) != 2 {
+		t.Fatalf("expected start and end events, got %+v", events)
+	}
+	if !events[0].start || events[0].command != "ls -la" || events[0].cwd != "/tmp/a b" {
+		t.Errorf("unexpected start event %+v", events[0])
+	}
+	if events[1].start || events[1].exitCode != 2 {
+		t.Errorf("unexpected end event %+v", events[1])
+	}
+
+	var info TerminalInfo
+	s.fill(&info)
+	if info.Cwd != "/tmp/a b" || info.LastCommand != "ls -la" || info.LastExitCode != 2 || info.CommandRunning {
+		t.Errorf("unexpected state %+v", info)
+	}
+
+	if events, _ := s.Feed([]byte("\x1b]133;D;0\x07")); len(events) != 0 {
+		t.Errorf("expected end without start to be ignored, got %+v", events)
+	}
+}
+
+func TestInjectShellIntegration(t *testing.T) {
+	zsh := &localCommand{command: "/usr/bin/zsh"}
+	if err := zsh.injectShellIntegration(); err != nil {
+		t.Fatalf("inject failed: %v", err)
+	}
+	if len(zsh.env) == 0 || filepath.Base(zsh.env[len(zsh.env)-1]) != "zsh" {
+		t.Errorf("expected ZDOTDIR to point at the integration dir, got %v", zsh.env)
+	}
+	dir, _ := shellIntegrationDir()
+	if st, err := os.Stat(dir); err != nil || st.Mode().Perm() != 0700 {
+		t.Errorf("expected a private integration dir, got %v", st)
+	}
+
+	fish := &localCommand{command: "fish"}
+	fish.injectShellIntegration()
+	if len(fish.argv) != 2 || fish.argv[0] != "--init-command" {
+		t.Errorf("expected fish init command, got %v", fish.argv)
+	}
+
+	withArgs := &localCommand{command: "/bin/bash", argv: []string{"-c", "true"}}
+	withArgs.injectShellIntegration()
+	if len(withArgs.argv) != 2 || withArgs.argv[0] != "-c" {
+		t.Errorf("expected explicit args to be kept, got %v", withArgs.argv)
+	}
+
+	sh := &localCommand{command: "/bin/sh"}
+	sh.injectShellIntegration()
+	if sh.argv != nil || sh.env != nil {
+		t.Errorf("expected unknown shell to be left alone, got %v %v", sh.argv, sh.env)
+	}
+}
+
+func TestManager_ShellIntegrationBash(t *testing.T) {
+	if _, err := os.Stat("/bin/bash"); err != nil {
+		t.Skip("bash not available")
+	}
+
+	db := setupTestDB(t)
+	manager := NewManager(db, &ManagerConfig{Shell: "/bin/sh"})
+	workDir := t.TempDir()
+	home := t.TempDir()
+	// The user's DEBUG trap and PROMPT_COMMAND array must keep working.
+	os.WriteFile(filepath.Join(home, ".bashrc"), []byte(`trap 'printf "%s\n" "$BASH_COMMAND" >> "$HOME/debug.log"' DEBUG
+PROMPT_COMMAND=('echo "status=$?" >> "$HOME/prompt.log"' 'echo second >> "$HOME/prompt.log"')
+`), 0644)
+
+	info, err := manager.Create(CreateOptions{
+		Cwd:              os.TempDir(),
+		Command:          "/bin/bash",
+		Env:              map[string]string{"HOME": home},
+		ShellIntegration: true,
+	})
+	if err != nil {
+		t.Fatalf("failed to create terminal: %v", err)
+	}
+	defer manager.Close(info.ID)
+
+	at, _ := manager.getActive(info.ID)
+	mst := &mockMaster{}
+	probe := newClientQueue(mst, 0)
+	go probe.run()
+	at.WebTTYs.Store("probe", &webTTYInstance{ID: "probe", Queue: probe})
+	defer at.WebTTYs.Delete("probe")
+
+	time.Sleep(300 * time.Millisecond)
+	at.PTY.Write([]byte("cd " + workDir + " && false\n"))
+
+	deadline := time.Now().Add(3 * time.Second)
+	var term *TerminalInfo
+	for {
+		term, _ = manager.Get(info.ID)
+		if term.LastCommand != "" && !term.CommandRunning {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("command end not reported, got %+v", term)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	if term.LastCommand != "cd "+workDir+" && false" || term.LastExitCode != 1 {
+		t.Errorf("unexpected command state: %q exited %d", term.LastCommand, term.LastExitCode)
+	}
+
+	// The cwd report follows the end mark in the same prompt.
+	deadline = time.Now().Add(time.Second)
+	for term.Cwd != workDir && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+		term, _ = manager.Get(info.ID)
+	}
+	if term.Cwd != workDir {
+		t.Errorf("expected live cwd %s, got %s", workDir, term.Cwd)
+	}
+	if log, _ := os.ReadFile(filepath.Join(home, "debug.log")); !strings.Contains(string(log), "false\n") {
+		t.Errorf("expected the user's DEBUG trap to run, got %q", log)
+	}
+	if log, _ := os.ReadFile(filepath.Join(home, "prompt.log")); !strings.Contains(string(log), "status=1\nsecond\n") {
+		t.Errorf("expected the user's prompt commands to see the exit status, got %q", log)
+	}
+
+	mst.mu.Lock()
+	defer mst.mu.Unlock()
+	var types []string
+	for _, frame := range mst.writeData {
+		var msg CommandMessage
+		if json.Unmarshal(frame, &msg) == nil && msg.Type != MsgTypeCmd {
+			types = append(types, msg.Type)
+		}
+	}
+	if !slices.Equal(types, []string{MsgTypeCommandStart, MsgTypeCommandEnd}) {
+		t.Errorf("expected command_start and command_end messages, got %v", types)
+	}
+}
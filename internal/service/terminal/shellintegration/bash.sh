@@ -0,0 +1,75 @@
+# VibeGo shell integration for bash. Loaded through --init-file, so the
+# user's ~/.bashrc is sourced first.
+if [ -r ~/.bashrc ]; then
+	. ~/.bashrc
+fi
+
+if [ -z "$__vibego_loaded" ]; then
+__vibego_loaded=1
+
+__vibego_urlencode() {
+	local LC_ALL=C s="$1" out="" c i
+	for ((i = 0; i < ${#s}; i++)); do
+		c=${s:i:1}
+		case "$c" in
+		[a-zA-Z0-9.~_/-]) out+="$c" ;;
+		*) printf -v c '%%%02X' "'$c"; out+="$c" ;;
+		esac
+	done
+	printf '%s' "$out"
+}
+
+__vibego_ready=0
+__vibego_running=0
+# PROMPT_COMMAND may be an array since bash 5.1.
+__vibego_prompt_command=("${PROMPT_COMMAND[@]}")
+
+# __vibego_status sets $? for the user's commands run from our hooks.
+__vibego_status() {
+	return "$1"
+}
+
+__vibego_prompt() {
+	local status=$? cmd
+	if [ "$__vibego_running" = 1 ]; then
+		printf '\e]133;D;%s\a' "$status"
+	fi
+	__vibego_running=0
+	for cmd in "${__vibego_prompt_command[@]}"; do
+		__vibego_status "$status"
+		eval "$cmd"
+	done
+	printf '\e]7;file://%s%s\a' "$HOSTNAME" "$(__vibego_urlencode "$PWD")"
+	printf '\e]133;A\a'
+	__vibego_ready=1
+}
+
+# A DEBUG trap set by the user, such as bash-preexec's, keeps running after
+# ours. trap -p prints it quoted as "trap -- 'command' DEBUG".
+__vibego_debug_trap() {
+	eval "set -- $1"
+	__vibego_debug_command=$3
+}
+__vibego_debug_trap "$(trap -p DEBUG)"
+
+__vibego_preexec() {
+	local status=$?
+	if [ "$__vibego_ready" = 1 ] && [ -z "$COMP_LINE" ] && [ "$BASH_COMMAND" != __vibego_prompt ]; then
+		__vibego_ready=0
+		__vibego_running=1
+		local cmd
+		cmd=$(HISTTIMEFORMAT= builtin history 1)
+		cmd=${cmd#*[0-9]  }
+		printf '\e]133;C;cmdline_url=%s\a' "$(__vibego_urlencode "$cmd")"
+	fi
+	if [ -n "$__vibego_debug_command" ]; then
+		__vibego_status "$status"
+		eval "$__vibego_debug_command"
+	fi
+}
+
+PS1="$PS1\[\e]133;B\a\]"
+unset PROMPT_COMMAND
+PROMPT_COMMAND=__vibego_prompt
+trap '__vibego_preexec' DEBUG
+fi
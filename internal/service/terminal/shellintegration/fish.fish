@@ -0,0 +1,18 @@
+# VibeGo shell integration for fish. Sourced through --init-command after the
+# user's configuration.
+if not set -q __vibego_loaded
+    set -g __vibego_loaded 1
+
+    function __vibego_prompt --on-event fish_prompt
+        printf '\e]7;file://%s%s\a' (hostname) (string escape --style=url -- $PWD)
+        printf '\e]133;A\a'
+    end
+
+    function __vibego_preexec --on-event fish_preexec
+        printf '\e]133;C;cmdline_url=%s\a' (string escape --style=url -- $argv[1])
+    end
+
+    function __vibego_postexec --on-event fish_postexec
+        printf '\e]133;D;%s\a' $status
+    end
+end
@@ -0,0 +1,43 @@
+package terminal
+
+import "syscall"
+
+// signalNames names the signals defined on every platform.
+var signalNames = map[syscall.Signal]string{
+	syscall.SIGHUP:  "SIGHUP",
+	syscall.SIGINT:  "SIGINT",
+	syscall.SIGQUIT: "SIGQUIT",
+	syscall.SIGILL:  "SIGILL",
+	syscall.SIGTRAP: "SIGTRAP",
+	syscall.SIGABRT: "SIGABRT",
+	syscall.SIGBUS:  "SIGBUS",
+	syscall.SIGFPE:  "SIGFPE",
+	syscall.SIGKILL: "SIGKILL",
+	syscall.SIGSEGV: "SIGSEGV",
+	syscall.SIGPIPE: "SIGPIPE",
+	syscall.SIGALRM: "SIGALRM",
+	syscall.SIGTERM: "SIGTERM",
+}
+
+func signalName(sig syscall.Signal) string {
+	if name, ok := signalNames[sig]; ok {
+		return name
+	}
+	if name, ok := platformSignalNames[sig]; ok {
+		return name
+	}
+	return sig.String()
+}
+
+// signalNumber returns the number of a named signal on this platform, or 0
+// for a signal it does not know.
+func signalNumber(name string) syscall.Signal {
+	for _, names := range []map[syscall.Signal]string{signalNames, platformSignalNames} {
+		for sig, n := range names {
+			if n == name {
+				return sig
+			}
+		}
+	}
+	return 0
+}
@@ -0,0 +1,7 @@
+//go:build !unix
+
+package terminal
+
+import "syscall"
+
+var platformSignalNames = map[syscall.Signal]string{}
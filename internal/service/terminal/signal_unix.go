@@ -0,0 +1,15 @@
+//go:build unix
+
+package terminal
+
+import "syscall"
+
+var platformSignalNames = map[syscall.Signal]string{
+	syscall.SIGUSR1:   "SIGUSR1",
+	syscall.SIGUSR2:   "SIGUSR2",
+	syscall.SIGSYS:    "SIGSYS",
+	syscall.SIGXCPU:   "SIGXCPU",
+	syscall.SIGXFSZ:   "SIGXFSZ",
+	syscall.SIGVTALRM: "SIGVTALRM",
+	syscall.SIGPROF:   "SIGPROF",
+}
@@ -7,3 +7,10 @@ type slave interface {
 	WindowTitleVariables() map[string]interface{}
 	Close() error
 }
+
+// process is a slave backed by a process whose exit can be waited for.
+type process interface {
+	slave
+	done() <-chan struct{}
+	exitStatus() (int, string)
+}
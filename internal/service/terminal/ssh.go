@@ -0,0 +1,289 @@
+package terminal
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xxnuo/vibego/internal/model"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+const sshDialTimeout = 10 * time.Second
+
+// sshCommand is a terminal running on a remote host over an SSH session.
+type sshCommand struct {
+	client     *ssh.Client
+	session    *ssh.Session
+	stdin      io.WriteCloser
+	stdout     io.Reader
+	host       string
+	command    string
+	argv       []string
+	cwd        string
+	exited     chan struct{}
+	exitCode   int
+	exitSignal string
+}
+
+// startSSH opens a terminal on the session's saved SSH host. An empty
+// command starts the remote user's login shell.
+func (m *Manager) startSSH(session *model.TerminalSession, opts CreateOptions) (process, error) {
+	host, err := m.loadSSHHost(session.HostID, session.UserID)
+	if err != nil {
+		return nil, err
+	}
+	auth, release, err := m.sshAuth(host)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	hostKeyCallback, err := m.sshHostKeyCallback(host)
+	if err != nil {
+		return nil, err
+	}
+
+	port := host.Port
+	if port == 0 {
+		port = 22
+	}
+	client, err := ssh.Dial("tcp", net.JoinHostPort(host.Host, strconv.Itoa(port)), &ssh.ClientConfig{
+		User:            host.User,
+		Auth:            []ssh.AuthMethod{auth},
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         sshDialTimeout,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sc, err := newSSHCommand(client, session, opts)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	sc.host = host.Host
+	return sc, nil
+}
+
+func newSSHCommand(client *ssh.Client, s *model.TerminalSession, opts CreateOptions) (*sshCommand, error) {
+	session, err := client.NewSession()
+	if err != nil {
+		return nil, err
+	}
+	// Servers commonly refuse variables outside their AcceptEnv list, so
+	// failures are not fatal.
+	for k, v := range opts.Env {
+		session.Setenv(k, v)
+	}
+
+	term := opts.Term
+	if term == "" {
+		term = DefaultTerm
+	}
+	modes := ssh.TerminalModes{ssh.ECHO: 1, ssh.TTY_OP_ISPEED: 38400, ssh.TTY_OP_OSPEED: 38400}
+	if err := session.RequestPty(term, s.Rows, s.Cols, modes); err != nil {
+		session.Close()
+		return nil, err
+	}
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		session.Close()
+		return nil, err
+	}
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		session.Close()
+		return nil, err
+	}
+
+	if command := remoteCommand(s.Shell, s.Args, s.Cwd); command == "" {
+		err = session.Shell()
+	} else {
+		err = session.Start(command)
+	}
+	if err != nil {
+		session.Close()
+		return nil, err
+	}
+
+	sc := &sshCommand{
+		client:  client,
+		session: session,
+		stdin:   stdin,
+		stdout:  stdout,
+		command: s.Shell,
+		argv:    s.Args,
+		cwd:     s.Cwd,
+		exited:  make(chan struct{}),
+	}
+	go func() {
+		defer close(sc.exited)
+		sc.exitCode, sc.exitSignal = sshWaitStatus(session.Wait())
+		client.Close()
+	}()
+	return sc, nil
+}
+
+// remoteCommand builds the command line run by the remote shell. It is
+// empty when the login shell should be started as is.
+func remoteCommand(command string, args []string, cwd string) string {
+	var line string
+	if command != "" {
+		words := []string{shellQuote(command)}
+		for _, arg := range args {
+			words = append(words, shellQuote(arg))
+		}
+		line = "exec " + strings.Join(words, " ")
+	}
+	if cwd == "" {
+		return line
+	}
+	if line == "" {
+		line = `exec "${SHELL:-/bin/sh}" -l`
+	}
+	return "cd " + shellQuote(cwd) + " && " + line
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// sshWaitStatus converts the error returned by ssh.Session.Wait into a
+// shell style exit code like waitStatus.
+func sshWaitStatus(err error) (int, string) {
+	if err == nil {
+		return 0, ""
+	}
+	var exitErr *ssh.ExitError
+	if !errors.As(err, &exitErr) {
+		return -1, ""
+	}
+	if sig := exitErr.Signal(); sig != "" {
+		name := "SIG" + sig
+		if signo := signalNumber(name); signo != 0 {
+			return 128 + int(signo), name
+		}
+		return -1, name
+	}
+	return exitErr.ExitStatus(), ""
+}
+
+// sshAuth returns the auth method for a host along with a function that
+// releases what it holds once the handshake is over.
+func (m *Manager) sshAuth(host *model.SSHHost) (ssh.AuthMethod, func(), error) {
+	switch host.Auth {
+	case model.SSHAuthPassword:
+		password, err := m.secrets.open(host.Password)
+		if err != nil {
+			return nil, nil, err
+		}
+		return ssh.Password(password), func() {}, nil
+	case model.SSHAuthKey:
+		key, err := m.secrets.open(host.PrivateKey)
+		if err != nil {
+			return nil, nil, err
+		}
+		passphrase, err := m.secrets.open(host.Passphrase)
+		if err != nil {
+			return nil, nil, err
+		}
+		var signer ssh.Signer
+		if passphrase != "" {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase([]byte(key), []byte(passphrase))
+		} else {
+			signer, err = ssh.ParsePrivateKey([]byte(key))
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %v", ErrInvalidSSHHost, err)
+		}
+		return ssh.PublicKeys(signer), func() {}, nil
+	case model.SSHAuthAgent:
+		if m.sshAgentSocket == "" {
+			return nil, nil, ErrSSHAgentUnavailable
+		}
+		conn, err := net.Dial("unix", m.sshAgentSocket)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %v", ErrSSHAgentUnavailable, err)
+		}
+		// The agent signs during the handshake, so it stays connected
+		// until then.
+		return ssh.PublicKeysCallback(agent.NewClient(conn).Signers), func() { conn.Close() }, nil
+	}
+	return nil, nil, ErrInvalidSSHHost
+}
+
+// sshHostKeyCallback verifies the server against the host's pinned key, or
+// the known_hosts file when none is pinned. Unknown hosts are rejected.
+func (m *Manager) sshHostKeyCallback(host *model.SSHHost) (ssh.HostKeyCallback, error) {
+	var callback ssh.HostKeyCallback
+	if host.HostKey != "" {
+		key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(host.HostKey))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidSSHHost, err)
+		}
+		callback = ssh.FixedHostKey(key)
+	} else {
+		var err error
+		if callback, err = knownhosts.New(m.knownHostsFile); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrSSHHostKey, err)
+		}
+	}
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		if err := callback(hostname, remote, key); err != nil {
+			return fmt.Errorf("%w: %v", ErrSSHHostKey, err)
+		}
+		return nil
+	}, nil
+}
+
+func (sc *sshCommand) Read(p []byte) (int, error) {
+	return sc.stdout.Read(p)
+}
+
+func (sc *sshCommand) Write(p []byte) (int, error) {
+	return sc.stdin.Write(p)
+}
+
+func (sc *sshCommand) ResizeTerminal(cols, rows int) error {
+	return sc.session.WindowChange(rows, cols)
+}
+
+func (sc *sshCommand) WindowTitleVariables() map[string]interface{} {
+	return map[string]interface{}{
+		"command":  sc.command,
+		"argv":     sc.argv,
+		"cwd":      sc.cwd,
+		"hostname": sc.host,
+	}
+}
+
+func (sc *sshCommand) done() <-chan struct{} {
+	return sc.exited
+}
+
+// exitStatus reports the remote exit code and signal. It is only
+// meaningful once done has been closed.
+func (sc *sshCommand) exitStatus() (int, string) {
+	return sc.exitCode, sc.exitSignal
+}
+
+// Close hangs up the remote shell, then drops the connection if the server
+// does not end the session in time.
+func (sc *sshCommand) Close() error {
+	sc.session.Signal(ssh.SIGHUP)
+	sc.session.Close()
+	select {
+	case <-sc.exited:
+	case <-time.After(DefaultCloseTimeout):
+		sc.client.Close()
+		<-sc.exited
+	}
+	return nil
+}
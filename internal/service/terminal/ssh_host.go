@@ -0,0 +1,201 @@
+package terminal
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/xxnuo/vibego/internal/model"
+	"golang.org/x/crypto/ssh"
+	"gorm.io/gorm"
+)
+
+// SSHHostInfo describes a saved SSH host. Credentials are never returned;
+// HasPassword and HasPrivateKey report whether they are set.
+type SSHHostInfo struct {
+	ID            string `json:"id"`
+	Name          string `json:"name"`
+	Host          string `json:"host"`
+	Port          int    `json:"port"`
+	User          string `json:"user"`
+	Auth          string `json:"auth"`
+	HostKey       string `json:"host_key,omitempty"`
+	HasPassword   bool   `json:"has_password"`
+	HasPrivateKey bool   `json:"has_private_key"`
+	CreatedAt     int64  `json:"created_at"`
+	UpdatedAt     int64  `json:"updated_at"`
+}
+
+// SSHHostOptions holds the fields of a saved SSH host. On update, empty
+// credentials keep the stored ones.
+type SSHHostOptions struct {
+	Name       string
+	Host       string
+	Port       int
+	User       string
+	Auth       string
+	Password   string
+	PrivateKey string
+	Passphrase string
+	HostKey    string
+	UserID     string
+}
+
+func sshHostToInfo(h *model.SSHHost) *SSHHostInfo {
+	return &SSHHostInfo{
+		ID:            h.ID,
+		Name:          h.Name,
+		Host:          h.Host,
+		Port:          h.Port,
+		User:          h.User,
+		Auth:          h.Auth,
+		HostKey:       h.HostKey,
+		HasPassword:   h.Password != "",
+		HasPrivateKey: h.PrivateKey != "",
+		CreatedAt:     h.CreatedAt,
+		UpdatedAt:     h.UpdatedAt,
+	}
+}
+
+func (opts *SSHHostOptions) validate() error {
+	if opts.Host == "" || opts.User == "" || opts.Port < 0 || opts.Port > 65535 {
+		return ErrInvalidSSHHost
+	}
+	switch opts.Auth {
+	case model.SSHAuthPassword, model.SSHAuthKey, model.SSHAuthAgent:
+	default:
+		return ErrInvalidSSHHost
+	}
+	if opts.HostKey != "" {
+		if _, _, _, _, err := ssh.ParseAuthorizedKey([]byte(opts.HostKey)); err != nil {
+			return ErrInvalidSSHHost
+		}
+	}
+	return nil
+}
+
+// ListSSHHosts returns the hosts saved by userID and those shared by every
+// user.
+func (m *Manager) ListSSHHosts(userID string) ([]SSHHostInfo, error) {
+	var hosts []model.SSHHost
+	if err := m.ownedBy(userID).Order("created_at ASC").Find(&hosts).Error; err != nil {
+		return nil, err
+	}
+	result := make([]SSHHostInfo, 0, len(hosts))
+	for i := range hosts {
+		result = append(result, *sshHostToInfo(&hosts[i]))
+	}
+	return result, nil
+}
+
+// loadSSHHost returns a host userID may use. Hosts of other users are not
+// found.
+func (m *Manager) loadSSHHost(id, userID string) (*model.SSHHost, error) {
+	var host model.SSHHost
+	if err := m.ownedBy(userID).First(&host, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSSHHostNotFound
+		}
+		return nil, err
+	}
+	return &host, nil
+}
+
+func (m *Manager) GetSSHHost(id, userID string) (*SSHHostInfo, error) {
+	host, err := m.loadSSHHost(id, userID)
+	if err != nil {
+		return nil, err
+	}
+	return sshHostToInfo(host), nil
+}
+
+func (m *Manager) CreateSSHHost(opts SSHHostOptions) (*SSHHostInfo, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	now := time.Now().Unix()
+	host := &model.SSHHost{
+		ID:        uuid.New().String(),
+		UserID:    opts.UserID,
+		Name:      opts.Name,
+		Host:      opts.Host,
+		Port:      opts.Port,
+		User:      opts.User,
+		Auth:      opts.Auth,
+		HostKey:   opts.HostKey,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := m.sealCredentials(host, opts); err != nil {
+		return nil, err
+	}
+	if err := m.db.Create(host).Error; err != nil {
+		return nil, err
+	}
+	return sshHostToInfo(host), nil
+}
+
+func (m *Manager) UpdateSSHHost(id string, opts SSHHostOptions) (*SSHHostInfo, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	host, err := m.loadSSHHost(id, opts.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	host.Name = opts.Name
+	host.Host = opts.Host
+	host.Port = opts.Port
+	host.User = opts.User
+	host.Auth = opts.Auth
+	host.HostKey = opts.HostKey
+	if err := m.sealCredentials(host, opts); err != nil {
+		return nil, err
+	}
+	host.UpdatedAt = time.Now().Unix()
+
+	if err := m.db.Save(host).Error; err != nil {
+		return nil, err
+	}
+	return sshHostToInfo(host), nil
+}
+
+// sealCredentials encrypts the credentials given in opts onto host and clears
+// those the auth method does not use.
+func (m *Manager) sealCredentials(host *model.SSHHost, opts SSHHostOptions) error {
+	if opts.Auth != model.SSHAuthPassword {
+		host.Password = ""
+	} else if opts.Password != "" {
+		password, err := m.secrets.seal(opts.Password)
+		if err != nil {
+			return err
+		}
+		host.Password = password
+	}
+	if opts.Auth != model.SSHAuthKey {
+		host.PrivateKey, host.Passphrase = "", ""
+	} else if opts.PrivateKey != "" {
+		key, err := m.secrets.seal(opts.PrivateKey)
+		if err != nil {
+			return err
+		}
+		passphrase, err := m.secrets.seal(opts.Passphrase)
+		if err != nil {
+			return err
+		}
+		host.PrivateKey, host.Passphrase = key, passphrase
+	}
+	return nil
+}
+
+func (m *Manager) DeleteSSHHost(id, userID string) error {
+	result := m.ownedBy(userID).Where("id = ?", id).Delete(&model.SSHHost{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrSSHHostNotFound
+	}
+	return nil
+}
@@ -0,0 +1,350 @@
+package terminal
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xxnuo/vibego/internal/model"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+func newTestSigner(t *testing.T) (ssh.Signer, ed25519.PrivateKey) {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer, priv
+}
+
+// startTestSSHServer serves SSH sessions backed by local /bin/sh PTYs and
+// returns the server's address and host key.
+func startTestSSHServer(t *testing.T, config *ssh.ServerConfig) (string, int, ssh.PublicKey) {
+	t.Helper()
+	hostKey, _ := newTestSigner(t)
+	config.AddHostKey(hostKey)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				_, chans, reqs, err := ssh.NewServerConn(conn, config)
+				if err != nil {
+					conn.Close()
+					return
+				}
+				go ssh.DiscardRequests(reqs)
+				for newChannel := range chans {
+					if newChannel.ChannelType() != "session" {
+						newChannel.Reject(ssh.UnknownChannelType, "unsupported")
+						continue
+					}
+					ch, requests, err := newChannel.Accept()
+					if err != nil {
+						continue
+					}
+					go serveTestSSHSession(ch, requests)
+				}
+			}()
+		}
+	}()
+
+	addr := listener.Addr().(*net.TCPAddr)
+	return addr.IP.String(), addr.Port, hostKey.PublicKey()
+}
+
+func serveTestSSHSession(ch ssh.Channel, requests <-chan *ssh.Request) {
+	var pty struct {
+		Term       string
+		Cols, Rows uint32
+		W, H       uint32
+		Modes      string
+	}
+	env := make(map[string]string)
+	var lc *localCommand
+
+	for req := range requests {
+		ok := true
+		switch req.Type {
+		case "pty-req":
+			ok = ssh.Unmarshal(req.Payload, &pty) == nil
+		case "env":
+			var kv struct{ Name, Value string }
+			if ok = ssh.Unmarshal(req.Payload, &kv) == nil; ok {
+				env[kv.Name] = kv.Value
+			}
+		case "shell", "exec":
+			var args []string
+			if req.Type == "exec" {
+				var exec struct{ Command string }
+				ssh.Unmarshal(req.Payload, &exec)
+				args = []string{"-c", exec.Command}
+			}
+			var err error
+			lc, err = newLocalCommand("/bin/sh", args, os.TempDir(), int(pty.Cols), int(pty.Rows),
+				withEnv(envList(env)), withTerm(pty.Term))
+			if ok = err == nil; ok {
+				go io.Copy(lc, ch)
+				go func(lc *localCommand) {
+					io.Copy(ch, lc)
+					<-lc.done()
+					code, _ := lc.exitStatus()
+					ch.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{uint32(code)}))
+					ch.Close()
+				}(lc)
+			}
+		case "window-change":
+			var size struct{ Cols, Rows, W, H uint32 }
+			if ssh.Unmarshal(req.Payload, &size) == nil && lc != nil {
+				lc.ResizeTerminal(int(size.Cols), int(size.Rows))
+			}
+		default:
+			ok = false
+		}
+		if req.WantReply {
+			req.Reply(ok, nil)
+		}
+	}
+	if lc != nil {
+		lc.Close()
+	}
+}
+
+func TestManager_SSHBackend(t *testing.T) {
+	host, port, hostKey := startTestSSHServer(t, &ssh.ServerConfig{
+		PasswordCallback: func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if conn.User() == "dev" && string(password) == "secret" {
+				return nil, nil
+			}
+			return nil, errors.New("denied")
+		},
+	})
+
+	db := setupTestDB(t)
+	manager := NewManager(db, &ManagerConfig{
+		Shell:          "/bin/sh",
+		KnownHostsFile: filepath.Join(t.TempDir(), "none"),
+		SecretKeyFile:  filepath.Join(t.TempDir(), "secret.key"),
+	})
+
+	saved, err := manager.CreateSSHHost(SSHHostOptions{
+		Name:     "box",
+		Host:     host,
+		Port:     port,
+		User:     "dev",
+		Auth:     model.SSHAuthPassword,
+		Password: "secret",
+		HostKey:  string(ssh.MarshalAuthorizedKey(hostKey)),
+		UserID:   "alice",
+	})
+	if err != nil {
+		t.Fatalf("CreateSSHHost failed: %v", err)
+	}
+	if !saved.HasPassword || saved.HasPrivateKey {
+		t.Errorf("unexpected host info %+v", saved)
+	}
+	if stored, _ := manager.loadSSHHost(saved.ID, "alice"); !strings.HasPrefix(stored.Password, secretPrefix) || strings.Contains(stored.Password, "secret") {
+		t.Errorf("expected the password to be stored encrypted, got %q", stored.Password)
+	}
+
+	if _, err := manager.GetSSHHost(saved.ID, "bob"); err != ErrSSHHostNotFound {
+		t.Errorf("expected another user's host to be hidden, got %v", err)
+	}
+	if _, err := manager.Create(CreateOptions{HostID: saved.ID, UserID: "bob"}); err != ErrSSHHostNotFound {
+		t.Errorf("expected another user's host to be refused, got %v", err)
+	}
+
+	info, err := manager.Create(CreateOptions{HostID: saved.ID, UserID: "alice", Env: map[string]string{"GREETING": "hello"}})
+	if err != nil {
+		t.Fatalf("failed to create ssh terminal: %v", err)
+	}
+	defer manager.Close(info.ID)
+	if info.Backend != model.BackendSSH || info.HostID != saved.ID || info.Shell != "" {
+		t.Errorf("unexpected terminal info %+v", info)
+	}
+	if got, _ := manager.Get(info.ID); got.HostID != saved.ID {
+		t.Errorf("expected Get to report the host, got %+v", got)
+	}
+
+	manager.WriteInput(info.ID, []byte("echo $GREETING-$((40+2))\n"))
+	waitScreen(t, manager, info.ID, "hello-42")
+
+	if err := manager.Resize(info.ID, 120, 40); err != nil {
+		t.Fatalf("Resize failed: %v", err)
+	}
+	// The window change and the input travel separately, so ask until the
+	// remote side has caught up.
+	for i := 0; ; i++ {
+		manager.WriteInput(info.ID, []byte("stty size\n"))
+		time.Sleep(50 * time.Millisecond)
+		if dump, _ := manager.Screen(info.ID, false); strings.Contains(strings.Join(dump.Lines, "\n"), "40 120") {
+			break
+		}
+		if i == 40 {
+			t.Fatal("expected the remote terminal to be resized")
+		}
+	}
+
+	manager.WriteInput(info.ID, []byte("exit 7\n"))
+	if exited := waitExited(t, manager, info.ID); exited.ExitCode != 7 {
+		t.Errorf("expected the remote exit code, got %d", exited.ExitCode)
+	}
+
+	manager.Close(info.ID)
+	result, err := manager.Search(info.ID, SearchOptions{Query: "hello-42"})
+	if err != nil || len(result.Matches) == 0 {
+		t.Errorf("expected the remote output in the stored history, got %+v (%v)", result, err)
+	}
+
+	manager.UpdateSSHHost(saved.ID, SSHHostOptions{
+		Host: host, Port: port, User: "dev", Auth: model.SSHAuthPassword, Password: "wrong",
+		HostKey: string(ssh.MarshalAuthorizedKey(hostKey)), UserID: "alice",
+	})
+	if _, err := manager.Create(CreateOptions{HostID: saved.ID, UserID: "alice"}); err == nil {
+		t.Error("expected a wrong password to fail")
+	}
+	manager.UpdateSSHHost(saved.ID, SSHHostOptions{Host: host, Port: port, User: "dev", Auth: model.SSHAuthAgent, UserID: "alice"})
+	if stored, _ := manager.loadSSHHost(saved.ID, "alice"); stored.Password != "" {
+		t.Errorf("expected switching to agent auth to drop the password, got %q", stored.Password)
+	}
+	if _, err := manager.Create(CreateOptions{HostID: "missing"}); err != ErrSSHHostNotFound {
+		t.Errorf("expected ErrSSHHostNotFound, got %v", err)
+	}
+	if _, err := manager.Create(CreateOptions{Backend: "telnet"}); err != ErrUnknownBackend {
+		t.Errorf("expected ErrUnknownBackend, got %v", err)
+	}
+}
+
+func TestManager_SSHHostKeyVerification(t *testing.T) {
+	clientKey, clientPriv := newTestSigner(t)
+	config := func() *ssh.ServerConfig {
+		return &ssh.ServerConfig{
+			PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+				if string(key.Marshal()) == string(clientKey.PublicKey().Marshal()) {
+					return nil, nil
+				}
+				return nil, errors.New("denied")
+			},
+		}
+	}
+	host, port, hostKey := startTestSSHServer(t, config())
+	otherHost, otherPort, _ := startTestSSHServer(t, config())
+
+	knownHostsFile := filepath.Join(t.TempDir(), "known_hosts")
+	line := knownhosts.Line([]string{net.JoinHostPort(host, strconv.Itoa(port))}, hostKey)
+	if err := os.WriteFile(knownHostsFile, []byte(line+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	block, err := ssh.MarshalPrivateKey(clientPriv, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	privateKey := string(pem.EncodeToMemory(block))
+
+	db := setupTestDB(t)
+	manager := NewManager(db, &ManagerConfig{Shell: "/bin/sh", KnownHostsFile: knownHostsFile})
+
+	known, _ := manager.CreateSSHHost(SSHHostOptions{Host: host, Port: port, User: "dev", Auth: model.SSHAuthKey, PrivateKey: privateKey})
+	info, err := manager.Create(CreateOptions{HostID: known.ID, Command: "echo", Args: []string{"it's", "known"}})
+	if err != nil {
+		t.Fatalf("expected a host in known_hosts to be accepted: %v", err)
+	}
+	defer manager.Close(info.ID)
+	waitExited(t, manager, info.ID)
+
+	unknown, _ := manager.CreateSSHHost(SSHHostOptions{Host: otherHost, Port: otherPort, User: "dev", Auth: model.SSHAuthKey, PrivateKey: privateKey})
+	if _, err := manager.Create(CreateOptions{HostID: unknown.ID}); !errors.Is(err, ErrSSHHostKey) {
+		t.Errorf("expected an unknown host to be rejected, got %v", err)
+	}
+
+	pinned, _ := manager.CreateSSHHost(SSHHostOptions{
+		Host: otherHost, Port: otherPort, User: "dev", Auth: model.SSHAuthKey, PrivateKey: privateKey,
+		HostKey: string(ssh.MarshalAuthorizedKey(hostKey)),
+	})
+	if _, err := manager.Create(CreateOptions{HostID: pinned.ID}); !errors.Is(err, ErrSSHHostKey) {
+		t.Errorf("expected a mismatched pinned key to be rejected, got %v", err)
+	}
+}
+
+func TestManager_SSHAgentAuth(t *testing.T) {
+	clientKey, clientPriv := newTestSigner(t)
+	host, port, hostKey := startTestSSHServer(t, &ssh.ServerConfig{
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if string(key.Marshal()) == string(clientKey.PublicKey().Marshal()) {
+				return nil, nil
+			}
+			return nil, errors.New("denied")
+		},
+	})
+
+	keyring := agent.NewKeyring()
+	if err := keyring.Add(agent.AddedKey{PrivateKey: clientPriv}); err != nil {
+		t.Fatal(err)
+	}
+	// Unix socket paths are short, so the test's TempDir won't do.
+	dir, err := os.MkdirTemp("", "vg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "agent.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go agent.ServeAgent(keyring, conn)
+		}
+	}()
+
+	db := setupTestDB(t)
+	manager := NewManager(db, &ManagerConfig{Shell: "/bin/sh", SSHAgentSocket: socket})
+
+	saved, err := manager.CreateSSHHost(SSHHostOptions{
+		Host: host, Port: port, User: "dev", Auth: model.SSHAuthAgent,
+		HostKey: string(ssh.MarshalAuthorizedKey(hostKey)),
+	})
+	if err != nil {
+		t.Fatalf("CreateSSHHost failed: %v", err)
+	}
+	info, err := manager.Create(CreateOptions{HostID: saved.ID, Cwd: "/", Command: "pwd"})
+	if err != nil {
+		t.Fatalf("failed to create ssh terminal with agent auth: %v", err)
+	}
+	defer manager.Close(info.ID)
+	if exited := waitExited(t, manager, info.ID); exited.ExitCode != 0 {
+		t.Errorf("expected the remote command to succeed, got %d", exited.ExitCode)
+	}
+}
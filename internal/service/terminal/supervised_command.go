@@ -0,0 +1,203 @@
+package terminal
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const supervisorDialTimeout = 2 * time.Second
+
+// supervisedCommand is a slave whose process is hosted by a supervisor.
+type supervisedCommand struct {
+	command      string
+	argv         []string
+	cwd          string
+	conn         net.Conn
+	writeMu      sync.Mutex
+	output       *io.PipeReader
+	outputWriter *io.PipeWriter
+	pid          int
+	pgid         chan int
+	queryMu      sync.Mutex
+	exited       chan struct{}
+	detached     chan struct{}
+	detaching    atomic.Bool
+	closeTimeout time.Duration
+	exitCode     int
+	exitSignal   string
+}
+
+func dialSupervisor(socket string) (*supervisedCommand, error) {
+	conn, err := net.DialTimeout("unix", socket, supervisorDialTimeout)
+	if err != nil {
+		return nil, err
+	}
+	reader := bufio.NewReader(conn)
+
+	conn.SetReadDeadline(time.Now().Add(supervisorDialTimeout))
+	typ, payload, err := readFrame(reader)
+	if err == nil && (typ != frameHello || len(payload) != 4) {
+		err = errors.New("unexpected supervisor handshake")
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetReadDeadline(time.Time{})
+
+	output, outputWriter := io.Pipe()
+	sc := &supervisedCommand{
+		conn:         conn,
+		output:       output,
+		outputWriter: outputWriter,
+		pid:          int(binary.BigEndian.Uint32(payload)),
+		pgid:         make(chan int, 1),
+		exited:       make(chan struct{}),
+		detached:     make(chan struct{}),
+		closeTimeout: DefaultCloseTimeout,
+	}
+	go sc.readLoop(reader)
+	return sc, nil
+}
+
+func (sc *supervisedCommand) readLoop(reader *bufio.Reader) {
+	defer sc.conn.Close()
+	for {
+		typ, payload, err := readFrame(reader)
+		if err != nil {
+			sc.outputWriter.Close()
+			if sc.detaching.Load() {
+				close(sc.detached)
+				return
+			}
+			// The supervisor went away without reporting an exit status.
+			sc.exitCode = -1
+			close(sc.exited)
+			return
+		}
+		switch typ {
+		case frameOutput:
+			sc.outputWriter.Write(payload)
+		case frameForeground:
+			if len(payload) == 4 {
+				select {
+				case sc.pgid <- int(binary.BigEndian.Uint32(payload)):
+				default:
+				}
+			}
+		case frameExit:
+			var exit supervisorExit
+			json.Unmarshal(payload, &exit)
+			sc.exitCode, sc.exitSignal = exit.Code, exit.Signal
+			sc.outputWriter.Close()
+			close(sc.exited)
+			return
+		case frameDetach:
+			sc.outputWriter.Close()
+			close(sc.detached)
+			return
+		}
+	}
+}
+
+func (sc *supervisedCommand) send(typ byte, payload []byte) error {
+	sc.writeMu.Lock()
+	defer sc.writeMu.Unlock()
+	return writeFrame(sc.conn, typ, payload)
+}
+
+func (sc *supervisedCommand) done() <-chan struct{} {
+	return sc.exited
+}
+
+func (sc *supervisedCommand) exitStatus() (int, string) {
+	return sc.exitCode, sc.exitSignal
+}
+
+func (sc *supervisedCommand) Read(p []byte) (int, error) {
+	return sc.output.Read(p)
+}
+
+func (sc *supervisedCommand) Write(p []byte) (int, error) {
+	if err := sc.send(frameInput, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (sc *supervisedCommand) ResizeTerminal(cols, rows int) error {
+	payload := binary.BigEndian.AppendUint16(nil, uint16(cols))
+	payload = binary.BigEndian.AppendUint16(payload, uint16(rows))
+	return sc.send(frameResize, payload)
+}
+
+func (sc *supervisedCommand) WindowTitleVariables() map[string]interface{} {
+	return map[string]interface{}{
+		"command": sc.command,
+		"argv":    sc.argv,
+		"pid":     sc.pid,
+		"cwd":     sc.cwd,
+	}
+}
+
+func (sc *supervisedCommand) Pid() int {
+	return sc.pid
+}
+
+// ForegroundProcessGroup asks the supervisor which process group owns the
+// terminal, since only it holds the PTY.
+func (sc *supervisedCommand) ForegroundProcessGroup() (int, error) {
+	sc.queryMu.Lock()
+	defer sc.queryMu.Unlock()
+
+	select {
+	case <-sc.pgid:
+	default:
+	}
+	if err := sc.send(frameForeground, nil); err != nil {
+		return 0, err
+	}
+	select {
+	case pgid := <-sc.pgid:
+		if pgid <= 0 {
+			return 0, ErrNoProcess
+		}
+		return pgid, nil
+	case <-sc.exited:
+		return 0, ErrTerminalExited
+	case <-time.After(time.Second):
+		return 0, ErrNoProcess
+	}
+}
+
+// Close kills the process and waits for the supervisor to report its exit.
+func (sc *supervisedCommand) Close() error {
+	sc.send(frameKill, nil)
+	select {
+	case <-sc.exited:
+	case <-sc.detached:
+	case <-time.After(sc.closeTimeout):
+	}
+	return sc.conn.Close()
+}
+
+// detach disconnects from the supervisor and leaves the process running.
+// Output produced afterwards is kept by the supervisor for the next server.
+func (sc *supervisedCommand) detach() {
+	sc.detaching.Store(true)
+	if err := sc.send(frameDetach, nil); err == nil {
+		select {
+		case <-sc.detached:
+		case <-sc.exited:
+		case <-time.After(supervisorDialTimeout):
+		}
+	}
+	sc.conn.Close()
+}
@@ -0,0 +1,362 @@
+package terminal
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/xxnuo/vibego/internal/model"
+)
+
+// SupervisorEnv is set in the environment of a VibeGo binary re-executed as
+// a session supervisor.
+const SupervisorEnv = "VG_TERMINAL_SUPERVISOR"
+
+const (
+	// supervisorBufferSize caps the output a supervisor holds while no
+	// server is connected. Older output is dropped first.
+	supervisorBufferSize = 1024 * 1024
+	// supervisorLinger is how long a supervisor whose process has exited
+	// waits for a server to collect the exit status.
+	supervisorLinger = 24 * time.Hour
+	maxFrameSize     = 16 * 1024 * 1024
+)
+
+// Frame types of the supervisor protocol. Every frame is a type byte, a
+// big-endian uint32 payload length and the payload.
+const (
+	frameHello      = 'h' // supervisor: uint32 pid
+	frameOutput     = 'o' // supervisor: PTY output
+	frameExit       = 'x' // supervisor: JSON supervisorExit
+	frameInput      = 'i' // server: PTY input
+	frameResize     = 'r' // server: uint16 cols, uint16 rows
+	frameKill       = 'k' // server: kill the process
+	frameForeground = 'g' // server: query; supervisor: uint32 pgid
+	frameDetach     = 'd' // server: stop sending output; supervisor: ack
+)
+
+// supervisorSpec describes the process a supervisor hosts. It is passed as
+// JSON on the supervisor's stdin.
+type supervisorSpec struct {
+	Socket           string                `json:"socket"`
+	Command          string                `json:"command"`
+	Args             []string              `json:"args"`
+	Cwd              string                `json:"cwd"`
+	Cols             int                   `json:"cols"`
+	Rows             int                   `json:"rows"`
+	BaseEnv          []string              `json:"base_env"`
+	Env              []string              `json:"env"`
+	Term             string                `json:"term"`
+	ShellIntegration bool                  `json:"shell_integration"`
+	Limits           *model.TerminalLimits `json:"limits,omitempty"`
+	Cgroup           string                `json:"cgroup,omitempty"`
+}
+
+type supervisorExit struct {
+	Code   int    `json:"code"`
+	Signal string `json:"signal"`
+}
+
+func writeFrame(w io.Writer, typ byte, payload []byte) error {
+	frame := make([]byte, 5+len(payload))
+	frame[0] = typ
+	binary.BigEndian.PutUint32(frame[1:5], uint32(len(payload)))
+	copy(frame[5:], payload)
+	_, err := w.Write(frame)
+	return err
+}
+
+func readFrame(r io.Reader) (byte, []byte, error) {
+	var header [5]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, nil, err
+	}
+	size := binary.BigEndian.Uint32(header[1:])
+	if size > maxFrameSize {
+		return 0, nil, fmt.Errorf("supervisor frame of %d bytes exceeds limit", size)
+	}
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+	return header[0], payload, nil
+}
+
+// IsSupervisor reports whether this process was started as a session
+// supervisor and should call RunSupervisor instead of serving.
+func IsSupervisor() bool {
+	return os.Getenv(SupervisorEnv) != ""
+}
+
+// RunSupervisor hosts a single terminal process on a unix socket.
+func RunSupervisor() int {
+	os.Unsetenv(SupervisorEnv)
+
+	var spec supervisorSpec
+	if err := json.NewDecoder(os.Stdin).Decode(&spec); err != nil {
+		fmt.Printf("error: %v\n", err)
+		return 1
+	}
+	s, err := newSupervisor(spec)
+	if err != nil {
+		fmt.Printf("error: %v\n", err)
+		return 1
+	}
+	fmt.Println("ok")
+	os.Stdout.Close()
+	os.Stdin.Close()
+
+	s.serve()
+	return 0
+}
+
+type supervisor struct {
+	socket    string
+	listener  net.Listener
+	pty       *localCommand
+	mu        sync.Mutex
+	client    net.Conn
+	pending   []byte
+	exit      *supervisorExit
+	exited    chan struct{}
+	delivered chan struct{}
+	once      sync.Once
+}
+
+func newSupervisor(spec supervisorSpec) (*supervisor, error) {
+	os.Remove(spec.Socket)
+	listener, err := net.Listen("unix", spec.Socket)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(spec.Socket, 0600); err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	pty, err := newLocalCommand(spec.Command, spec.Args, spec.Cwd, spec.Cols, spec.Rows,
+		withBaseEnv(spec.BaseEnv),
+		withEnv(spec.Env),
+		withTerm(spec.Term),
+		withShellIntegration(spec.ShellIntegration),
+		withLimits(spec.Limits, spec.Cgroup),
+	)
+	if err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	return &supervisor{
+		socket:    spec.Socket,
+		listener:  listener,
+		pty:       pty,
+		exited:    make(chan struct{}),
+		delivered: make(chan struct{}),
+	}, nil
+}
+
+func (s *supervisor) serve() {
+	go s.accept()
+	go s.pump()
+
+	<-s.exited
+	select {
+	case <-s.delivered:
+	case <-time.After(supervisorLinger):
+	}
+	s.listener.Close()
+	os.Remove(s.socket)
+}
+
+func (s *supervisor) accept() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.attach(conn)
+	}
+}
+
+// attach makes conn the connected server, replacing any previous one, and
+// sends it the output buffered while nobody was connected.
+func (s *supervisor) attach(conn net.Conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.client != nil {
+		s.client.Close()
+		s.client = nil
+	}
+
+	hello := binary.BigEndian.AppendUint32(nil, uint32(s.pty.Pid()))
+	if err := writeFrame(conn, frameHello, hello); err != nil {
+		conn.Close()
+		return
+	}
+	if len(s.pending) > 0 {
+		if err := writeFrame(conn, frameOutput, s.pending); err != nil {
+			conn.Close()
+			return
+		}
+		s.pending = nil
+	}
+	if s.exit != nil {
+		s.sendExit(conn)
+		return
+	}
+	s.client = conn
+	go s.handle(conn)
+}
+
+// sendExit delivers the exit status. Callers must hold mu.
+func (s *supervisor) sendExit(conn net.Conn) {
+	payload, _ := json.Marshal(s.exit)
+	if err := writeFrame(conn, frameExit, payload); err != nil {
+		conn.Close()
+		return
+	}
+	s.once.Do(func() { close(s.delivered) })
+}
+
+func (s *supervisor) pump() {
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := s.pty.Read(buf)
+		if n > 0 {
+			s.output(buf[:n])
+		}
+		if err != nil {
+			break
+		}
+	}
+
+	<-s.pty.done()
+	code, signal := s.pty.exitStatus()
+
+	s.mu.Lock()
+	s.exit = &supervisorExit{Code: code, Signal: signal}
+	if s.client != nil {
+		s.sendExit(s.client)
+		s.client = nil
+	}
+	s.mu.Unlock()
+	close(s.exited)
+}
+
+func (s *supervisor) output(data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.client != nil {
+		if err := writeFrame(s.client, frameOutput, data); err == nil {
+			return
+		}
+		s.client.Close()
+		s.client = nil
+	}
+	s.pending = append(s.pending, data...)
+	if over := len(s.pending) - supervisorBufferSize; over > 0 {
+		s.pending = append(s.pending[:0], s.pending[over:]...)
+	}
+}
+
+func (s *supervisor) handle(conn net.Conn) {
+	defer func() {
+		s.mu.Lock()
+		if s.client == conn {
+			s.client = nil
+		}
+		s.mu.Unlock()
+		conn.Close()
+	}()
+
+	reader := bufio.NewReader(conn)
+	for {
+		typ, payload, err := readFrame(reader)
+		if err != nil {
+			return
+		}
+		switch typ {
+		case frameInput:
+			s.pty.Write(payload)
+		case frameResize:
+			if len(payload) == 4 {
+				s.pty.ResizeTerminal(int(binary.BigEndian.Uint16(payload)), int(binary.BigEndian.Uint16(payload[2:])))
+			}
+		case frameKill:
+			go s.pty.Close()
+		case frameForeground:
+			pgid, err := s.pty.ForegroundProcessGroup()
+			if err != nil {
+				pgid = 0
+			}
+			s.mu.Lock()
+			writeFrame(conn, frameForeground, binary.BigEndian.AppendUint32(nil, uint32(pgid)))
+			s.mu.Unlock()
+		case frameDetach:
+			// The ack is written under mu so no output can follow it.
+			s.mu.Lock()
+			if s.client == conn {
+				s.client = nil
+			}
+			writeFrame(conn, frameDetach, nil)
+			s.mu.Unlock()
+		}
+	}
+}
+
+// startSupervisor re-executes the binary as a detached supervisor for spec
+// and connects to it.
+func startSupervisor(spec supervisorSpec) (*supervisedCommand, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(spec.Socket), 0700); err != nil {
+		return nil, err
+	}
+	input, err := json.Marshal(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command(exe)
+	// The supervisor only needs what its terminal inherits, so the server's
+	// own settings never reach it either.
+	cmd.Env = append(spec.BaseEnv[:len(spec.BaseEnv):len(spec.BaseEnv)], SupervisorEnv+"=1")
+	cmd.Dir = "/"
+	cmd.Stdin = bytes.NewReader(input)
+	detachSupervisor(cmd)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	line, err := bufio.NewReader(stdout).ReadString('\n')
+	// The supervisor is reaped here while the server runs and by init after
+	// a restart.
+	go cmd.Wait()
+	if err != nil {
+		return nil, fmt.Errorf("supervisor failed to start: %w", err)
+	}
+	if line = strings.TrimSpace(line); line != "ok" {
+		return nil, errors.New(strings.TrimPrefix(line, "error: "))
+	}
+
+	return dialSupervisor(spec.Socket)
+}
@@ -0,0 +1,169 @@
+package terminal
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xxnuo/vibego/internal/model"
+)
+
+// TestMain lets the test binary act as the supervisor and launcher it
+// re-executes.
+func TestMain(m *testing.M) {
+	if IsSupervisor() {
+		os.Exit(RunSupervisor())
+	}
+	if IsSandbox() {
+		os.Exit(RunSandbox())
+	}
+	os.Exit(m.Run())
+}
+
+func waitScreen(t *testing.T, manager *Manager, id, want string) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		dump, err := manager.Screen(id, false)
+		if err == nil && strings.Contains(strings.Join(dump.Lines, "\n"), want) {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%q did not appear on screen", want)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func waitExited(t *testing.T, manager *Manager, id string) *TerminalInfo {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		info, ok := manager.Get(id)
+		if ok && info.PTYStatus == model.PTYStatusExited {
+			return info
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("terminal did not exit")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestManager_PersistentReadopt(t *testing.T) {
+	db := setupTestDB(t)
+	cfg := &ManagerConfig{Shell: "/bin/sh", Persistent: true, SupervisorDir: t.TempDir()}
+	manager := NewManager(db, cfg)
+
+	info, err := manager.Create(CreateOptions{Cwd: os.TempDir(), Command: "/bin/sh"})
+	if err != nil {
+		t.Fatalf("failed to create terminal: %v", err)
+	}
+	if info.Backend != model.BackendSupervisor {
+		t.Errorf("expected supervisor backend, got %q", info.Backend)
+	}
+	manager.WriteInput(info.ID, []byte("echo one\n"))
+	waitScreen(t, manager, info.ID, "one")
+
+	tree, err := manager.Processes(info.ID)
+	if err != nil || tree.Command != "sh" {
+		t.Fatalf("expected the supervised shell's process tree, got %+v (%v)", tree, err)
+	}
+	pid := tree.PID
+
+	// Output produced while no server is attached must survive the restart.
+	manager.WriteInput(info.ID, []byte("sleep 0.3; echo two\n"))
+	manager.Shutdown()
+	if _, ok := manager.Get(info.ID); ok {
+		t.Fatal("expected terminal to be detached")
+	}
+	time.Sleep(600 * time.Millisecond)
+
+	restarted := NewManager(db, cfg)
+	restarted.CleanupOnStart()
+	adopted, ok := restarted.Get(info.ID)
+	if !ok || adopted.PTYStatus != model.PTYStatusRunning || adopted.Status != model.StatusActive {
+		t.Fatalf("expected session to be re-adopted, got %+v", adopted)
+	}
+	waitScreen(t, restarted, info.ID, "one")
+	waitScreen(t, restarted, info.ID, "two")
+
+	at, _ := restarted.getActive(info.ID)
+	at.historyMu.RLock()
+	data, start, _ := at.historyBuffer.ReadSince(0)
+	at.historyMu.RUnlock()
+	if start != 0 || !strings.Contains(string(data), "echo one") {
+		t.Errorf("expected history to continue from the stored output, start %d", start)
+	}
+
+	if tree, err := restarted.Processes(info.ID); err != nil || tree.PID != pid {
+		t.Errorf("expected the same shell after restart, got %+v (%v)", tree, err)
+	}
+	restarted.WriteInput(info.ID, []byte("exit 4\n"))
+	if exited := waitExited(t, restarted, info.ID); exited.ExitCode != 4 {
+		t.Errorf("expected exit code 4, got %d", exited.ExitCode)
+	}
+
+	socket := filepath.Join(cfg.SupervisorDir, info.ID+".sock")
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if _, err := os.Stat(socket); os.IsNotExist(err) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("supervisor did not remove its socket")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestManager_PersistentExitWhileDetached(t *testing.T) {
+	db := setupTestDB(t)
+	cfg := &ManagerConfig{Shell: "/bin/sh", Persistent: true, SupervisorDir: t.TempDir()}
+	manager := NewManager(db, cfg)
+
+	info, err := manager.Create(CreateOptions{
+		Cwd:     os.TempDir(),
+		Command: "/bin/sh",
+		Args:    []string{"-c", "sleep 0.3; echo done; exit 3"},
+	})
+	if err != nil {
+		t.Fatalf("failed to create terminal: %v", err)
+	}
+	manager.Shutdown()
+	time.Sleep(600 * time.Millisecond)
+
+	restarted := NewManager(db, cfg)
+	restarted.CleanupOnStart()
+	exited := waitExited(t, restarted, info.ID)
+	if exited.ExitCode != 3 {
+		t.Errorf("expected exit code held by the supervisor, got %d", exited.ExitCode)
+	}
+	waitScreen(t, restarted, info.ID, "done")
+	restarted.Close(info.ID)
+}
+
+func TestManager_PersistentSupervisorGone(t *testing.T) {
+	db := setupTestDB(t)
+	manager := NewManager(db, &ManagerConfig{Shell: "/bin/sh"})
+
+	session := &model.TerminalSession{
+		ID:        "gone-supervisor",
+		Status:    model.StatusActive,
+		PTYStatus: model.PTYStatusRunning,
+		Backend:   model.BackendSupervisor,
+		Socket:    filepath.Join(t.TempDir(), "missing.sock"),
+	}
+	db.Save(session)
+	defer db.Delete(session)
+
+	manager.CleanupOnStart()
+
+	var stored model.TerminalSession
+	db.First(&stored, "id = ?", session.ID)
+	if stored.PTYStatus != model.PTYStatusExited || stored.Status != model.StatusClosed {
+		t.Errorf("expected unreachable session to be marked exited, got %+v", stored)
+	}
+}
@@ -0,0 +1,13 @@
+//go:build unix
+
+package terminal
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// detachSupervisor starts the supervisor in its own session.
+func detachSupervisor(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+}
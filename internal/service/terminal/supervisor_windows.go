@@ -0,0 +1,14 @@
+package terminal
+
+import (
+	"os/exec"
+	"syscall"
+
+	"golang.org/x/sys/windows"
+)
+
+// detachSupervisor starts the supervisor without a console and in its own
+// process group, so that console events for the server do not reach it.
+func detachSupervisor(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{CreationFlags: windows.DETACHED_PROCESS | windows.CREATE_NEW_PROCESS_GROUP}
+}
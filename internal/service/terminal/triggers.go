@@ -0,0 +1,416 @@
+package terminal
+
+import (
+	"encoding/json"
+	"errors"
+	"net/url"
+	"regexp"
+	"regexp/syntax"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"github.com/xxnuo/vibego/internal/model"
+	"gorm.io/gorm"
+)
+
+// maxTriggerTail bounds the recent output triggers are matched against, so
+// a match may span several reads.
+const maxTriggerTail = 4096
+
+// triggerReplyQueue bounds the replies waiting to be typed into a terminal.
+const triggerReplyQueue = 16
+
+// TriggerInfo describes an output trigger.
+type TriggerInfo struct {
+	ID        string `json:"id"`
+	UserID    string `json:"user_id,omitempty"`
+	ProfileID string `json:"profile_id,omitempty"`
+	Name      string `json:"name"`
+	Pattern   string `json:"pattern"`
+	Action    string `json:"action"`
+	Reply     string `json:"reply,omitempty"`
+	URL       string `json:"url,omitempty"`
+	Enabled   bool   `json:"enabled"`
+	CreatedAt int64  `json:"created_at"`
+	UpdatedAt int64  `json:"updated_at"`
+}
+
+// TriggerOptions holds the fields of a trigger. UserID and ProfileID scope
+// it; leave them empty to match every user or profile.
+type TriggerOptions struct {
+	Name      string
+	Pattern   string
+	Action    string
+	Reply     string
+	URL       string
+	Enabled   bool
+	UserID    string
+	ProfileID string
+}
+
+func triggerToInfo(t *model.TerminalTrigger) *TriggerInfo {
+	return &TriggerInfo{
+		ID:        t.ID,
+		UserID:    t.UserID,
+		ProfileID: t.ProfileID,
+		Name:      t.Name,
+		Pattern:   t.Pattern,
+		Action:    t.Action,
+		Reply:     t.Reply,
+		URL:       t.URL,
+		Enabled:   t.Enabled,
+		CreatedAt: t.CreatedAt,
+		UpdatedAt: t.UpdatedAt,
+	}
+}
+
+func (opts *TriggerOptions) validate() error {
+	// A pattern that can match nothing, such as \b, would fire everywhere.
+	parsed, err := syntax.Parse(opts.Pattern, syntax.Perl)
+	if err != nil || minMatchLen(parsed.Simplify()) == 0 {
+		return ErrInvalidTrigger
+	}
+	switch opts.Action {
+	case model.TriggerHighlight, model.TriggerNotify, model.TriggerOpenURL:
+	case model.TriggerReply:
+		if opts.Reply == "" {
+			return ErrInvalidTrigger
+		}
+	default:
+		return ErrInvalidTrigger
+	}
+	return nil
+}
+
+// minMatchLen returns the fewest runes a match of re can span.
+func minMatchLen(re *syntax.Regexp) int {
+	switch re.Op {
+	case syntax.OpLiteral:
+		return len(re.Rune)
+	case syntax.OpCharClass, syntax.OpAnyChar, syntax.OpAnyCharNotNL, syntax.OpNoMatch:
+		return 1
+	case syntax.OpCapture, syntax.OpPlus:
+		return minMatchLen(re.Sub[0])
+	case syntax.OpRepeat:
+		return re.Min * minMatchLen(re.Sub[0])
+	case syntax.OpConcat:
+		n := 0
+		for _, sub := range re.Sub {
+			n += minMatchLen(sub)
+		}
+		return n
+	case syntax.OpAlternate:
+		n := minMatchLen(re.Sub[0])
+		for _, sub := range re.Sub[1:] {
+			n = min(n, minMatchLen(sub))
+		}
+		return n
+	}
+	// Empty matches, anchors, word boundaries, star and quest.
+	return 0
+}
+
+// ListTriggers returns the triggers of userID and those shared by every user.
+func (m *Manager) ListTriggers(userID string) ([]TriggerInfo, error) {
+	var triggers []model.TerminalTrigger
+	if err := m.ownedBy(userID).Order("created_at ASC").Find(&triggers).Error; err != nil {
+		return nil, err
+	}
+	result := make([]TriggerInfo, len(triggers))
+	for i := range triggers {
+		result[i] = *triggerToInfo(&triggers[i])
+	}
+	return result, nil
+}
+
+func (m *Manager) loadTrigger(id, userID string) (*model.TerminalTrigger, error) {
+	var trigger model.TerminalTrigger
+	if err := m.ownedBy(userID).First(&trigger, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTriggerNotFound
+		}
+		return nil, err
+	}
+	return &trigger, nil
+}
+
+func (m *Manager) GetTrigger(id, userID string) (*TriggerInfo, error) {
+	trigger, err := m.loadTrigger(id, userID)
+	if err != nil {
+		return nil, err
+	}
+	return triggerToInfo(trigger), nil
+}
+
+func (m *Manager) CreateTrigger(opts TriggerOptions) (*TriggerInfo, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	now := time.Now().Unix()
+	trigger := &model.TerminalTrigger{
+		ID:        uuid.New().String(),
+		UserID:    opts.UserID,
+		ProfileID: opts.ProfileID,
+		Name:      opts.Name,
+		Pattern:   opts.Pattern,
+		Action:    opts.Action,
+		Reply:     opts.Reply,
+		URL:       opts.URL,
+		Enabled:   opts.Enabled,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := m.db.Create(trigger).Error; err != nil {
+		return nil, err
+	}
+	m.reloadTriggers()
+	return triggerToInfo(trigger), nil
+}
+
+func (m *Manager) UpdateTrigger(id string, opts TriggerOptions) (*TriggerInfo, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	trigger, err := m.loadTrigger(id, opts.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	trigger.ProfileID = opts.ProfileID
+	trigger.Name = opts.Name
+	trigger.Pattern = opts.Pattern
+	trigger.Action = opts.Action
+	trigger.Reply = opts.Reply
+	trigger.URL = opts.URL
+	trigger.Enabled = opts.Enabled
+	trigger.UpdatedAt = time.Now().Unix()
+
+	if err := m.db.Save(trigger).Error; err != nil {
+		return nil, err
+	}
+	m.reloadTriggers()
+	return triggerToInfo(trigger), nil
+}
+
+func (m *Manager) DeleteTrigger(id, userID string) error {
+	result := m.ownedBy(userID).Where("id = ?", id).Delete(&model.TerminalTrigger{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrTriggerNotFound
+	}
+	m.reloadTriggers()
+	return nil
+}
+
+// triggerRule is a trigger compiled for one terminal.
+type triggerRule struct {
+	trigger model.TerminalTrigger
+	re      *regexp.Regexp
+	// from is where matching resumes in the tail, just past the previous
+	// match, so that output only ever fires a trigger once.
+	from int
+	// fired holds the times of the actions within the rate limit window.
+	fired []time.Time
+}
+
+// allow reports whether the rule may act now without exceeding burst
+// actions per window, and records the action if so.
+func (r *triggerRule) allow(now time.Time, burst int, window time.Duration) bool {
+	i := 0
+	for i < len(r.fired) && now.Sub(r.fired[i]) >= window {
+		i++
+	}
+	r.fired = r.fired[i:]
+	if len(r.fired) >= burst {
+		return false
+	}
+	r.fired = append(r.fired, now)
+	return true
+}
+
+// triggerState holds the triggers of a terminal and the output they are
+// matched against.
+type triggerState struct {
+	mu    sync.Mutex
+	tail  []byte
+	rules []*triggerRule
+	// pending holds an escape sequence split across reads, so that its
+	// remainder is not matched as text.
+	pending []byte
+}
+
+// set replaces the rules. They only see output that arrives afterwards.
+func (ts *triggerState) set(rules []*triggerRule) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	for _, r := range rules {
+		r.from = len(ts.tail)
+	}
+	ts.rules = rules
+}
+
+// triggerMatch is a rule that fired, with the text it matched and its
+// expanded reply or URL.
+type triggerMatch struct {
+	trigger model.TerminalTrigger
+	text    string
+	line    string
+	reply   string
+	url     string
+}
+
+// feed appends a chunk of output and returns the matches allowed by the rate
+// limit.
+func (ts *triggerState) feed(data []byte, now time.Time, burst int, window time.Duration) []triggerMatch {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	if len(ts.rules) == 0 {
+		return nil
+	}
+
+	complete, pending := splitEscape(append(ts.pending, data...))
+	// An unterminated string sequence is given up on rather than buffered
+	// forever.
+	if len(pending) > maxTriggerTail {
+		pending = nil
+	}
+	ts.pending = append([]byte(nil), pending...)
+	ts.tail = append(ts.tail, stripANSI(complete)...)
+	if drop := len(ts.tail) - maxTriggerTail; drop > 0 {
+		ts.tail = ts.tail[drop:]
+		for _, r := range ts.rules {
+			r.from = max(r.from-drop, 0)
+		}
+	}
+
+	var matches []triggerMatch
+	for _, r := range ts.rules {
+		for r.from < len(ts.tail) {
+			loc := r.re.FindSubmatchIndex(ts.tail[r.from:])
+			if loc == nil {
+				break
+			}
+			for i := range loc {
+				if loc[i] >= 0 {
+					loc[i] += r.from
+				}
+			}
+			if loc[1] == loc[0] {
+				// Stored triggers predating validation may still match
+				// nothing; skip ahead instead of matching the same spot.
+				_, size := utf8.DecodeRune(ts.tail[loc[1]:])
+				r.from = min(loc[1]+max(size, 1), len(ts.tail))
+				continue
+			}
+			r.from = loc[1]
+			if r.allow(now, burst, window) {
+				matches = append(matches, r.match(ts.tail, loc))
+			}
+		}
+	}
+	return matches
+}
+
+func (r *triggerRule) match(text []byte, loc []int) triggerMatch {
+	match := triggerMatch{
+		trigger: r.trigger,
+		text:    string(text[loc[0]:loc[1]]),
+		line:    matchedLine(text, loc),
+	}
+	switch r.trigger.Action {
+	case model.TriggerReply:
+		match.reply = string(r.re.Expand(nil, []byte(r.trigger.Reply), text, loc))
+	case model.TriggerOpenURL:
+		template := r.trigger.URL
+		if template == "" {
+			template = "$0"
+		}
+		match.url = string(r.re.Expand(nil, []byte(template), text, loc))
+	}
+	return match
+}
+
+// loadTriggers compiles the enabled triggers that apply to a session.
+func (m *Manager) loadTriggers(session *model.TerminalSession) []*triggerRule {
+	var triggers []model.TerminalTrigger
+	err := m.db.Where("enabled = ? AND (user_id = '' OR user_id = ?) AND (profile_id = '' OR profile_id = ?)",
+		true, session.UserID, session.ProfileID).Order("created_at ASC").Find(&triggers).Error
+	if err != nil {
+		log.Warn().Err(err).Str("id", session.ID).Msg("Failed to load terminal triggers")
+		return nil
+	}
+	var rules []*triggerRule
+	for _, t := range triggers {
+		re, err := regexp.Compile(t.Pattern)
+		if err != nil {
+			continue
+		}
+		rules = append(rules, &triggerRule{trigger: t, re: re})
+	}
+	return rules
+}
+
+// reloadTriggers applies trigger changes to the running terminals.
+func (m *Manager) reloadTriggers() {
+	m.terminals.Range(func(key, value any) bool {
+		at := value.(*activeTerminal)
+		at.triggers.set(m.loadTriggers(at.Session))
+		return true
+	})
+}
+
+// runTriggers matches output ending at stream offset offset against the
+// terminal's triggers and performs their actions.
+func (m *Manager) runTriggers(at *activeTerminal, data []byte, offset int64) {
+	matches := at.triggers.feed(data, time.Now(), m.triggerBurst, m.triggerWindow)
+	for _, match := range matches {
+		msg := TriggerMessage{
+			Type:      MsgTypeTrigger,
+			TriggerID: match.trigger.ID,
+			Name:      match.trigger.Name,
+			Action:    match.trigger.Action,
+			Text:      match.text,
+			Line:      match.line,
+			Offset:    offset,
+		}
+		switch match.trigger.Action {
+		case model.TriggerNotify:
+			n := at.newNotification(NotifyTrigger, match.line)
+			n.Trigger = match.trigger.Name
+			m.notify(n)
+		case model.TriggerReply:
+			select {
+			case at.replies <- []byte(match.reply):
+			default:
+				log.Warn().Str("id", at.ID).Str("trigger", match.trigger.ID).Msg("Trigger replies backed up, dropping reply")
+			}
+		case model.TriggerOpenURL:
+			if u, err := url.Parse(match.url); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				log.Warn().Str("id", at.ID).Str("trigger", match.trigger.ID).Msg("Trigger produced an invalid URL")
+				continue
+			}
+			msg.URL = match.url
+		}
+		msgData, _ := json.Marshal(msg)
+		at.broadcast(msgData)
+	}
+}
+
+// writeReplies types trigger replies into a terminal in match order.
+func (m *Manager) writeReplies(at *activeTerminal) {
+	for {
+		select {
+		case reply := <-at.replies:
+			if at.ptyStatus.Load() != model.PTYStatusExited {
+				at.PTY.Write(reply)
+			}
+		case <-at.Done:
+			return
+		}
+	}
+}
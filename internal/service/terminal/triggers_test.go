@@ -0,0 +1,226 @@
+package terminal
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/xxnuo/vibego/internal/model"
+	"gorm.io/gorm"
+)
+
+// setupTriggerDB is setupTestDB for tests creating triggers, which must not
+// leak into the other tests sharing the database.
+func setupTriggerDB(t *testing.T) *gorm.DB {
+	db := setupTestDB(t)
+	t.Cleanup(func() {
+		db.Where("1 = 1").Delete(&model.TerminalTrigger{})
+	})
+	return db
+}
+
+// readTriggerMessage reads until a trigger message arrives.
+func readTriggerMessage(t *testing.T, conn *websocket.Conn) TriggerMessage {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("failed to read trigger message: %v", err)
+		}
+		var msg TriggerMessage
+		if json.Unmarshal(data, &msg) == nil && msg.Type == MsgTypeTrigger {
+			return msg
+		}
+	}
+}
+
+func TestManager_TriggerCRUD(t *testing.T) {
+	db := setupTriggerDB(t)
+	manager := NewManager(db, &ManagerConfig{Shell: "/bin/sh"})
+
+	for _, opts := range []TriggerOptions{
+		{Pattern: "(", Action: model.TriggerHighlight},
+		{Pattern: "a*", Action: model.TriggerHighlight},
+		{Pattern: `\b`, Action: model.TriggerHighlight},
+		{Pattern: `(?m)\bfoo\b|\b`, Action: model.TriggerHighlight},
+		{Pattern: `(x{0,3})^`, Action: model.TriggerHighlight},
+		{Pattern: "y/N", Action: model.TriggerReply},
+		{Pattern: "error", Action: "shout"},
+	} {
+		if _, err := manager.CreateTrigger(opts); err != ErrInvalidTrigger {
+			t.Errorf("%+v: expected ErrInvalidTrigger, got %v", opts, err)
+		}
+	}
+
+	created, err := manager.CreateTrigger(TriggerOptions{Name: "errors", Pattern: "(?i)error", Action: model.TriggerHighlight, Enabled: true, UserID: "alice"})
+	if err != nil {
+		t.Fatalf("CreateTrigger failed: %v", err)
+	}
+	updated, err := manager.UpdateTrigger(created.ID, TriggerOptions{Name: "errors", Pattern: "FAIL", Action: model.TriggerNotify, UserID: "alice"})
+	if err != nil || updated.Pattern != "FAIL" || updated.Enabled {
+		t.Fatalf("UpdateTrigger failed: %+v (%v)", updated, err)
+	}
+	if _, err := manager.CreateTrigger(TriggerOptions{Name: "bob", Pattern: "FAIL", Action: model.TriggerNotify, UserID: "bob"}); err != nil {
+		t.Fatalf("CreateTrigger failed: %v", err)
+	}
+	if triggers, _ := manager.ListTriggers("alice"); len(triggers) != 1 || triggers[0].UserID != "alice" {
+		t.Errorf("unexpected trigger list %+v", triggers)
+	}
+	if _, err := manager.GetTrigger(created.ID, "bob"); err != ErrTriggerNotFound {
+		t.Errorf("expected another user's trigger to be hidden, got %v", err)
+	}
+	if err := manager.DeleteTrigger(created.ID, "bob"); err != ErrTriggerNotFound {
+		t.Errorf("expected another user's trigger to be kept, got %v", err)
+	}
+	if err := manager.DeleteTrigger(created.ID, "alice"); err != nil {
+		t.Fatalf("DeleteTrigger failed: %v", err)
+	}
+	if _, err := manager.GetTrigger(created.ID, "alice"); err != ErrTriggerNotFound {
+		t.Errorf("expected ErrTriggerNotFound, got %v", err)
+	}
+}
+
+// TestTriggerState_EmptyMatch feeds a rule that matches nothing, as a
+// trigger stored before validation could, which must not stall the output.
+func TestTriggerState_EmptyMatch(t *testing.T) {
+	var ts triggerState
+	ts.set([]*triggerRule{
+		{trigger: model.TerminalTrigger{Action: model.TriggerHighlight}, re: regexp.MustCompile(`\b`)},
+		{trigger: model.TerminalTrigger{Action: model.TriggerHighlight}, re: regexp.MustCompile(`ok`)},
+	})
+	done := make(chan []triggerMatch)
+	go func() {
+		done <- ts.feed([]byte("héllo ok"), time.Now(), 5, time.Minute)
+	}()
+	select {
+	case matches := <-done:
+		if len(matches) != 1 || matches[0].text != "ok" {
+			t.Errorf("expected only the non-empty match, got %+v", matches)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("feed did not return")
+	}
+}
+
+func TestTriggerState_SplitEscape(t *testing.T) {
+	var ts triggerState
+	ts.set([]*triggerRule{
+		{trigger: model.TerminalTrigger{Name: "leak"}, re: regexp.MustCompile(`1m`)},
+		{trigger: model.TerminalTrigger{Name: "text"}, re: regexp.MustCompile(`FAIL`)},
+	})
+	var matches []triggerMatch
+	for _, chunk := range []string{"ok \x1b[3", "1mFA", "IL\x1b]0;ti", "tle 1m\x07 done"} {
+		matches = append(matches, ts.feed([]byte(chunk), time.Now(), 5, time.Minute)...)
+	}
+	if len(matches) != 1 || matches[0].trigger.Name != "text" {
+		t.Errorf("expected escape sequences split across reads to be stripped, got %+v", matches)
+	}
+}
+
+func TestManager_TriggerActions(t *testing.T) {
+	db := setupTriggerDB(t)
+	manager := NewManager(db, &ManagerConfig{Shell: "/bin/sh", TriggerBurst: 3, TriggerWindow: time.Minute})
+
+	profile, err := manager.CreateProfile(ProfileOptions{Name: "agent", Program: "/bin/sh"})
+	if err != nil {
+		t.Fatalf("CreateProfile failed: %v", err)
+	}
+	for _, opts := range []TriggerOptions{
+		{Name: "confirm", Pattern: `Continue\? \[y/N\] `, Action: model.TriggerReply, Reply: "y\n", ProfileID: profile.ID},
+		{Name: "docs", Pattern: `see (\S+) for details`, Action: model.TriggerOpenURL, URL: "https://$1"},
+		{Name: "failures", Pattern: `FAILED \w+`, Action: model.TriggerNotify},
+		{Name: "spam", Pattern: `SPAM`, Action: model.TriggerHighlight},
+		{Name: "other user", Pattern: `Continue`, Action: model.TriggerHighlight, UserID: "bob"},
+		{Name: "disabled", Pattern: `Continue`, Action: model.TriggerHighlight},
+	} {
+		opts.Enabled = opts.Name != "disabled"
+		if _, err := manager.CreateTrigger(opts); err != nil {
+			t.Fatalf("CreateTrigger failed: %v", err)
+		}
+	}
+
+	notifications, unsubscribe := manager.Notifications().Subscribe()
+	defer unsubscribe()
+
+	info, err := manager.Create(CreateOptions{Cwd: os.TempDir(), ProfileID: profile.ID, UserID: "alice"})
+	if err != nil {
+		t.Fatalf("failed to create terminal: %v", err)
+	}
+	defer manager.Close(info.ID)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upgrader := websocket.Upgrader{}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		if _, err := manager.Attach(info.ID, conn, AttachOptions{}); err != nil {
+			conn.Close()
+		}
+	}))
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	defer conn.Close()
+	time.Sleep(50 * time.Millisecond)
+
+	// The terminal echoes the commands typed below, which are written so
+	// that only their output matches.
+	manager.WriteInput(info.ID, []byte("printf 'Continue? [y/%s] ' N; read a; echo GOT=$a\n"))
+	waitScreen(t, manager, info.ID, "GOT=y")
+	if msg := readTriggerMessage(t, conn); msg.Name != "confirm" || msg.Action != model.TriggerReply || msg.Text != "Continue? [y/N] " {
+		t.Errorf("expected the reply to be reported, got %+v", msg)
+	}
+
+	manager.WriteInput(info.ID, []byte("echo see example.com/help for 'details'\n"))
+	msg := readTriggerMessage(t, conn)
+	if msg.Action != model.TriggerOpenURL || msg.URL != "https://example.com/help" || msg.Line != "see example.com/help for details" {
+		t.Errorf("expected the URL to be opened, got %+v", msg)
+	}
+
+	manager.WriteInput(info.ID, []byte("echo 'FAILED' build\n"))
+	// The prompt above also raised an awaiting input notification.
+	for n := (*Notification)(nil); n == nil || n.Kind != NotifyTrigger; {
+		select {
+		case n = <-notifications:
+		case <-time.After(3 * time.Second):
+			t.Fatal("expected a trigger notification")
+		}
+		if n.Kind == NotifyTrigger && (n.Trigger != "failures" || n.Message != "FAILED build") {
+			t.Errorf("unexpected notification %+v", n)
+		}
+	}
+	readTriggerMessage(t, conn)
+
+	manager.WriteInput(info.ID, []byte("for i in 1 2 3 4 5 6 7 8 9 10; do echo SP''AM; done; echo DO''NE\n"))
+	waitScreen(t, manager, info.ID, "DONE")
+	time.Sleep(100 * time.Millisecond)
+	spam := 0
+	conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			break
+		}
+		var msg TriggerMessage
+		if json.Unmarshal(data, &msg) == nil && msg.Type == MsgTypeTrigger {
+			if msg.Name != "spam" {
+				t.Errorf("unexpected trigger %+v", msg)
+			}
+			spam++
+		}
+	}
+	if spam != 3 {
+		t.Errorf("expected the burst to cap the actions at 3, got %d", spam)
+	}
+}
@@ -2,30 +2,111 @@ package terminal
 
 import (
 	"os"
+	"path/filepath"
 	"time"
 
 	"github.com/xxnuo/vibego/internal/model"
 )
 
 type TerminalInfo struct {
-	ID        string `json:"id"`
-	Name      string `json:"name"`
-	Shell     string `json:"shell"`
-	Cwd       string `json:"cwd"`
-	Cols      int    `json:"cols"`
-	Rows      int    `json:"rows"`
-	Status    string `json:"status"`
-	PTYStatus string `json:"pty_status"`
-	CreatedAt int64  `json:"created_at"`
-	UpdatedAt int64  `json:"updated_at"`
+	ID             string                `json:"id"`
+	Name           string                `json:"name"`
+	Shell          string                `json:"shell"`
+	Args           []string              `json:"args,omitempty"`
+	StartupCommand string                `json:"startup_command,omitempty"`
+	ProfileID      string                `json:"profile_id,omitempty"`
+	Cwd            string                `json:"cwd"`
+	Cols           int                   `json:"cols"`
+	Rows           int                   `json:"rows"`
+	Status         string                `json:"status"`
+	PTYStatus      string                `json:"pty_status"`
+	ExitCode       int                   `json:"exit_code"`
+	ExitSignal     string                `json:"exit_signal,omitempty"`
+	ExitReason     string                `json:"exit_reason,omitempty"`
+	Backend        string                `json:"backend"`
+	HostID         string                `json:"host_id,omitempty"`
+	ResizePolicy   string                `json:"resize_policy"`
+	PinnedClient   string                `json:"pinned_client,omitempty"`
+	Limits         *model.TerminalLimits `json:"limits,omitempty"`
+	LastCommand    string                `json:"last_command"`
+	LastExitCode   int                   `json:"last_exit_code"`
+	CommandRunning bool                  `json:"command_running"`
+	Writers        int                   `json:"writers"`
+	Viewers        int                   `json:"viewers"`
+	CreatedAt      int64                 `json:"created_at"`
+	UpdatedAt      int64                 `json:"updated_at"`
 }
 
 type CreateOptions struct {
-	Name   string
-	Cwd    string
-	Cols   int
-	Rows   int
-	UserID string
+	Name      string
+	Cwd       string
+	Cols      int
+	Rows      int
+	UserID    string
+	ProfileID string // options set here override the profile
+	Command   string // overrides the default shell
+	Args      []string
+	Env       map[string]string
+	Term      string
+	// StartupCommand is typed into the terminal once it starts.
+	StartupCommand   string
+	ShellIntegration bool
+	Record           bool
+	ResizePolicy     string
+	Backend          string // local, supervisor or ssh
+	HostID           string
+	Limits           *model.TerminalLimits
+}
+
+// AttachOptions controls how a WebSocket client joins a terminal.
+type AttachOptions struct {
+	ReadOnly bool
+	// Resume requests only the output after stream offset Since.
+	Resume      bool
+	Since       int64
+	User        string
+	RemoteAddr  string
+	UserAgent   string
+	ClientToken string // kept across reconnects; a pinned size follows it
+}
+
+type ClientInfo struct {
+	ID           string `json:"id"`
+	User         string `json:"user,omitempty"`
+	RemoteAddr   string `json:"remote_addr"`
+	UserAgent    string `json:"user_agent"`
+	AttachedAt   int64  `json:"attached_at"`
+	Mode         string `json:"mode"`
+	ReadOnly     bool   `json:"read_only"`
+	Binary       bool   `json:"binary"`
+	QueuedBytes  int    `json:"queued_bytes"`
+	DroppedBytes int64  `json:"dropped_bytes"`
+	Cols         int    `json:"cols,omitempty"`
+	Rows         int    `json:"rows,omitempty"`
+}
+
+type SearchOptions struct {
+	Query      string
+	Regex      bool
+	IgnoreCase bool
+	Context    int
+	Limit      int
+}
+
+// SearchMatch is one match in a terminal's output. Offset and End are stream
+// offsets; Line and Column refer to the output with escapes stripped.
+type SearchMatch struct {
+	Offset int64    `json:"offset"`
+	End    int64    `json:"end"`
+	Line   string   `json:"line"`
+	Column int      `json:"column"`
+	Before []string `json:"before"`
+	After  []string `json:"after"`
+}
+
+type SearchResult struct {
+	Matches   []SearchMatch `json:"matches"`
+	Truncated bool          `json:"truncated"`
 }
 
 type Connection struct {
@@ -38,8 +119,41 @@ type ManagerConfig struct {
 	MaxConnections       int
 	HistoryBufferSize    int
 	HistoryFlushInterval time.Duration
-	HistoryMaxRecords    int
+	HistoryMaxBytes      int64
+	// HistoryMaxAge expires the history of closed sessions.
 	HistoryMaxAge        time.Duration
+	RecordDir            string
+	RecordAll            bool
+	ScrollbackLines      int
+	ClientQueueSize      int
+	OverflowPolicy       string
+	AwaitingInputPattern string
+	LongCommandThreshold time.Duration
+	NotifyCooldown       time.Duration
+	// TriggerBurst caps trigger actions per terminal within TriggerWindow.
+	TriggerBurst  int
+	TriggerWindow time.Duration
+	NotifySinks   []NotificationSink
+	// Persistent runs sessions in supervisor processes that outlive the server.
+	Persistent    bool
+	SupervisorDir string
+	// A zero timeout or retention disables it; all run every MaintenanceInterval.
+	ExitedTimeout       time.Duration
+	IdleTimeout         time.Duration
+	SessionRetention    time.Duration
+	MaintenanceInterval time.Duration
+	ResizePolicy        string
+	// A negative PingInterval, PongTimeout or WriteTimeout disables it.
+	PingInterval   time.Duration
+	PongTimeout    time.Duration
+	WriteTimeout   time.Duration
+	Env            EnvPolicy
+	KnownHostsFile string
+	SSHAgentSocket string
+	// SecretKeyFile encrypts saved SSH credentials; without it they are plaintext.
+	SecretKeyFile string
+	// CgroupRoot is a delegated cgroup v2 directory for memory and CPU limits.
+	CgroupRoot string
 }
 
 func (c *ManagerConfig) applyDefaults() {
@@ -58,25 +172,96 @@ func (c *ManagerConfig) applyDefaults() {
 	if c.HistoryFlushInterval <= 0 {
 		c.HistoryFlushInterval = 5 * time.Second
 	}
-	if c.HistoryMaxRecords <= 0 {
-		c.HistoryMaxRecords = 1
+	if c.HistoryMaxBytes <= 0 {
+		c.HistoryMaxBytes = int64(c.HistoryBufferSize)
 	}
 	if c.HistoryMaxAge <= 0 {
 		c.HistoryMaxAge = 7 * 24 * time.Hour
 	}
+	if c.RecordDir == "" {
+		c.RecordDir = filepath.Join(os.TempDir(), "vibego-recordings")
+	}
+	if c.ScrollbackLines <= 0 {
+		c.ScrollbackLines = 1000
+	}
+	if c.ClientQueueSize <= 0 {
+		c.ClientQueueSize = 4 * 1024 * 1024
+	}
+	if c.OverflowPolicy != OverflowDrop {
+		c.OverflowPolicy = OverflowSkip
+	}
+	if c.AwaitingInputPattern == "" {
+		c.AwaitingInputPattern = DefaultAwaitingInputPattern
+	}
+	if c.LongCommandThreshold <= 0 {
+		c.LongCommandThreshold = 30 * time.Second
+	}
+	if c.NotifyCooldown <= 0 {
+		c.NotifyCooldown = 10 * time.Second
+	}
+	if c.TriggerBurst <= 0 {
+		c.TriggerBurst = 5
+	}
+	if c.TriggerWindow <= 0 {
+		c.TriggerWindow = 10 * time.Second
+	}
+	if !validResizePolicy(c.ResizePolicy) {
+		c.ResizePolicy = ResizeSmallest
+	}
+	if c.MaintenanceInterval <= 0 {
+		c.MaintenanceInterval = time.Minute
+	}
+	if c.PingInterval == 0 {
+		c.PingInterval = 30 * time.Second
+	}
+	if c.PongTimeout == 0 {
+		c.PongTimeout = 2 * c.PingInterval
+	}
+	if c.WriteTimeout == 0 {
+		c.WriteTimeout = 10 * time.Second
+	}
+	if c.KnownHostsFile == "" {
+		if home, err := os.UserHomeDir(); err == nil {
+			c.KnownHostsFile = filepath.Join(home, ".ssh", "known_hosts")
+		}
+	}
+	if c.SSHAgentSocket == "" {
+		c.SSHAgentSocket = os.Getenv("SSH_AUTH_SOCK")
+	}
+	if c.SupervisorDir == "" {
+		c.SupervisorDir = filepath.Join(os.TempDir(), "vibego-terminals")
+	}
+}
+
+func (c *ManagerConfig) keepalive() keepalive {
+	return keepalive{
+		pingInterval: c.PingInterval,
+		pongTimeout:  c.PongTimeout,
+		writeTimeout: c.WriteTimeout,
+	}
 }
 
 func sessionToInfo(s *model.TerminalSession) *TerminalInfo {
 	return &TerminalInfo{
-		ID:        s.ID,
-		Name:      s.Name,
-		Shell:     s.Shell,
-		Cwd:       s.Cwd,
-		Cols:      s.Cols,
-		Rows:      s.Rows,
-		Status:    s.Status,
-		PTYStatus: s.PTYStatus,
-		CreatedAt: s.CreatedAt,
-		UpdatedAt: s.UpdatedAt,
+		ID:             s.ID,
+		Name:           s.Name,
+		Shell:          s.Shell,
+		Args:           s.Args,
+		StartupCommand: s.StartupCommand,
+		ProfileID:      s.ProfileID,
+		Cwd:            s.Cwd,
+		Cols:           s.Cols,
+		Rows:           s.Rows,
+		Status:         s.Status,
+		PTYStatus:      s.PTYStatus,
+		ExitCode:       s.ExitCode,
+		ExitSignal:     s.ExitSignal,
+		ExitReason:     s.ExitReason,
+		Backend:        s.Backend,
+		HostID:         s.HostID,
+		ResizePolicy:   s.ResizePolicy,
+		Limits:         s.Limits,
+		CreatedAt:      s.CreatedAt,
+		UpdatedAt:      s.UpdatedAt,
 	}
 }
@@ -17,8 +17,11 @@ type webTTY struct {
 	writeMutex        sync.Mutex
 	onClosed          func()
 	onReady           func()
+	onResize          func(cols, rows int)
+	onInput           func()
 	historyWriter     io.Writer
 	skipSlaveReadLoop bool
+	binary            bool
 }
 
 func newWebTTY(master master, slave slave, options ...webTTYOption) *webTTY {
@@ -38,11 +41,13 @@ func newWebTTY(master master, slave slave, options ...webTTYOption) *webTTY {
 }
 
 func (wt *webTTY) sendOutput(data []byte) error {
-	msg := WSMessage{
-		Type: MsgTypeCmd,
-		Data: wt.encoder.EncodeToString(data),
+	var err error
+	if wt.binary {
+		_, err = wt.masterWriteBinary(encodeOutputBinary(data, 0))
+	} else {
+		_, err = wt.masterWrite(encodeOutputJSON(data, 0))
 	}
-	return wt.sendJSON(msg)
+	return err
 }
 
 func (wt *webTTY) sendJSON(msg WSMessage) error {
@@ -131,6 +136,19 @@ func (wt *webTTY) handleMessage(data []byte) error {
 		return nil
 	}
 
+	// JSON control messages always start with '{', which never collides
+	// with a binary frame type.
+	if wt.binary && data[0] == BinaryFrameInput {
+		if !wt.permitWrite {
+			return nil
+		}
+		wt.inputReceived()
+		if _, err := wt.slave.Write(data[1:]); err != nil {
+			return ErrSlaveClosed
+		}
+		return nil
+	}
+
 	var msg WSMessage
 	if err := json.Unmarshal(data, &msg); err != nil {
 		return nil
@@ -145,6 +163,7 @@ func (wt *webTTY) handleMessage(data []byte) error {
 		if err != nil {
 			return nil
 		}
+		wt.inputReceived()
 		if _, err := wt.slave.Write(decoded); err != nil {
 			return ErrSlaveClosed
 		}
@@ -157,7 +176,15 @@ func (wt *webTTY) handleMessage(data []byte) error {
 		wt.sendJSON(resp)
 
 	case MsgTypeResize:
-		if msg.Cols > 0 && msg.Rows > 0 {
+		if !wt.permitWrite {
+			return nil
+		}
+		if msg.Cols <= 0 || msg.Rows <= 0 {
+			return nil
+		}
+		if wt.onResize != nil {
+			wt.onResize(msg.Cols, msg.Rows)
+		} else {
 			wt.slave.ResizeTerminal(msg.Cols, msg.Rows)
 		}
 	}
@@ -165,6 +192,12 @@ func (wt *webTTY) handleMessage(data []byte) error {
 	return nil
 }
 
+func (wt *webTTY) inputReceived() {
+	if wt.onInput != nil {
+		wt.onInput()
+	}
+}
+
 func (wt *webTTY) sendInitMessage() error {
 	return nil
 }
@@ -174,3 +207,9 @@ func (wt *webTTY) masterWrite(data []byte) (int, error) {
 	defer wt.writeMutex.Unlock()
 	return wt.master.Write(data)
 }
+
+func (wt *webTTY) masterWriteBinary(data []byte) (int, error) {
+	wt.writeMutex.Lock()
+	defer wt.writeMutex.Unlock()
+	return wt.master.WriteBinary(data)
+}
@@ -29,6 +29,10 @@ func (m *mockMaster) Read(p []byte) (int, error) {
 	return n, nil
 }
 
+func (m *mockMaster) WriteBinary(p []byte) (int, error) {
+	return m.Write(p)
+}
+
 func (m *mockMaster) Write(p []byte) (int, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -223,3 +227,82 @@ func TestWebTTY_OnClosed(t *testing.T) {
 		t.Error("expected onClosed callback to be called")
 	}
 }
+
+func TestWebTTY_ReadOnlyIgnoresInput(t *testing.T) {
+	encoded := base64.StdEncoding.EncodeToString([]byte("test input"))
+	inputMsg := WSMessage{Type: MsgTypeCmd, Data: encoded}
+	inputData, _ := json.Marshal(inputMsg)
+
+	master := &mockMaster{
+		readData: inputData,
+	}
+	slave := &mockSlave{}
+
+	wt := newWebTTY(master, slave, withPermitWrite(false))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	go wt.Run(ctx)
+	time.Sleep(100 * time.Millisecond)
+
+	slave.mu.Lock()
+	defer slave.mu.Unlock()
+
+	if len(slave.writeData) != 0 {
+		t.Errorf("expected read-only client input to be dropped, got %q", slave.writeData)
+	}
+}
+
+func TestWebTTY_BinaryInput(t *testing.T) {
+	frame := append([]byte{BinaryFrameInput}, []byte("raw input")...)
+
+	master := &mockMaster{
+		readData: frame,
+	}
+	slave := &mockSlave{}
+
+	wt := newWebTTY(master, slave, withBinary(true))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	go wt.Run(ctx)
+	time.Sleep(100 * time.Millisecond)
+
+	slave.mu.Lock()
+	defer slave.mu.Unlock()
+
+	if len(slave.writeData) == 0 || string(slave.writeData[0]) != "raw input" {
+		t.Errorf("expected 'raw input', got %q", slave.writeData)
+	}
+}
+
+func TestWebTTY_BinaryOutput(t *testing.T) {
+	master := &mockMaster{}
+	slave := &mockSlave{
+		readData: []byte("hello"),
+	}
+
+	wt := newWebTTY(master, slave, withBinary(true))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	go wt.Run(ctx)
+	time.Sleep(100 * time.Millisecond)
+
+	master.mu.Lock()
+	defer master.mu.Unlock()
+
+	found := false
+	for _, msg := range master.writeData {
+		if len(msg) > 9 && msg[0] == BinaryFrameOutput && string(msg[9:]) == "hello" {
+			found = true
+			break
+		}
+	}
+	if !found {
+		t.Error("expected binary output frame containing 'hello'")
+	}
+}
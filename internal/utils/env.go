@@ -3,6 +3,8 @@ package utils
 import (
 	"os"
 	"strconv"
+	"strings"
+	"time"
 )
 
 func GetEnv(key string, defaultValue string) string {
@@ -31,3 +33,24 @@ func GetIntEnv(key string, defaultValue int) int {
 	}
 	return defaultValue
 }
+
+// SplitList splits a comma separated list, dropping blank entries.
+func SplitList(s string) []string {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+func GetDurationEnv(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		result, err := time.ParseDuration(value)
+		if err == nil {
+			return result
+		}
+	}
+	return defaultValue
+}
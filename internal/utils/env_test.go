@@ -3,6 +3,7 @@ package utils
 import (
 	"os"
 	"testing"
+	"time"
 )
 
 func TestGetEnv(t *testing.T) {
@@ -87,6 +88,35 @@ func TestGetIntEnv(t *testing.T) {
 	}
 }
 
+func TestGetDurationEnv(t *testing.T) {
+	key := "TEST_ENV_DURATION"
+	defer os.Unsetenv(key)
+
+	tests := []struct {
+		envVal string
+		setEnv bool
+		defVal time.Duration
+		want   time.Duration
+	}{
+		{"90m", true, 0, 90 * time.Minute},
+		{"0", true, time.Hour, 0},
+		{"invalid", true, time.Second, time.Second},
+		{"", false, time.Minute, time.Minute},
+	}
+
+	for _, tt := range tests {
+		if tt.setEnv {
+			os.Setenv(key, tt.envVal)
+		} else {
+			os.Unsetenv(key)
+		}
+
+		if got := GetDurationEnv(key, tt.defVal); got != tt.want {
+			t.Errorf("GetDurationEnv(%q, %v) (env=%q) = %v, want %v", key, tt.defVal, tt.envVal, got, tt.want)
+		}
+	}
+}
+
 func TestGetBoolEnv_Detailed(t *testing.T) {
 	key := "TEST_ENV_BOOL_DETAILED"
 	defer os.Unsetenv(key)
@@ -107,3 +137,12 @@ func TestGetBoolEnv_Detailed(t *testing.T) {
 		t.Error("Want default true when unset")
 	}
 }
+
+func TestSplitList(t *testing.T) {
+	if got := SplitList(" PATH, LC_*,,HOME "); len(got) != 3 || got[0] != "PATH" || got[1] != "LC_*" || got[2] != "HOME" {
+		t.Errorf("SplitList() = %q", got)
+	}
+	if got := SplitList(""); got != nil {
+		t.Errorf("SplitList(\"\") = %q, want nil", got)
+	}
+}
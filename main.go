@@ -5,7 +5,9 @@ import (
 	"fmt"
 	"io/fs"
 	"net/http"
+	"os"
 	"os/signal"
+	"path/filepath"
 	"strings"
 	"syscall"
 	"time"
@@ -21,6 +23,8 @@ import (
 	"github.com/xxnuo/vibego/internal/logger"
 	"github.com/xxnuo/vibego/internal/middleware"
 	"github.com/xxnuo/vibego/internal/model"
+	"github.com/xxnuo/vibego/internal/service/terminal"
+	"github.com/xxnuo/vibego/internal/utils"
 	"github.com/xxnuo/vibego/internal/version"
 	"github.com/xxnuo/vibego/ui"
 )
@@ -31,6 +35,16 @@ import (
 // @host localhost:1984
 // @BasePath /api
 func main() {
+	// Persistent terminals are hosted by this binary re-executed as a
+	// supervisor, and limited ones started by it as a launcher. Neither
+	// must parse the server's flags.
+	if terminal.IsSupervisor() {
+		os.Exit(terminal.RunSupervisor())
+	}
+	if terminal.IsSandbox() {
+		os.Exit(terminal.RunSandbox())
+	}
+
 	cfg := config.GetConfig()
 
 	logger.Setup(cfg.LogLevel)
@@ -78,6 +92,11 @@ func main() {
 		&model.UserSetting{},
 		&model.TerminalSession{},
 		&model.TerminalHistory{},
+		&model.TerminalProfile{},
+		&model.SSHHost{},
+		&model.TerminalTrigger{},
+		&model.TerminalRecording{},
+		&model.PushSubscription{},
 	)
 
 	api := r.Group("/api")
@@ -90,7 +109,31 @@ func main() {
 	handler.NewSettingsHandler(db).Register(api)
 	handler.NewSessionHandler(db).Register(api)
 	handler.NewFileHandler().Register(api)
-	handler.NewTerminalHandler(db, cfg.DefaultShell).Register(api)
+	var notifySinks []terminal.NotificationSink
+	if cfg.NotifyWebhook != "" {
+		notifySinks = append(notifySinks, &terminal.WebhookSink{URL: cfg.NotifyWebhook})
+	}
+	terminalHandler := handler.NewTerminalHandler(db, &terminal.ManagerConfig{
+		Shell:            cfg.DefaultShell,
+		RecordDir:        filepath.Join(cfg.ConfigDir, "recordings"),
+		RecordAll:        cfg.RecordTerminals,
+		NotifySinks:      notifySinks,
+		Persistent:       cfg.PersistentTerminals,
+		SupervisorDir:    filepath.Join(cfg.ConfigDir, "terminals"),
+		SecretKeyFile:    filepath.Join(cfg.ConfigDir, "secret.key"),
+		ExitedTimeout:    cfg.TerminalExitedTimeout,
+		IdleTimeout:      cfg.TerminalIdleTimeout,
+		SessionRetention: cfg.TerminalSessionRetention,
+		ResizePolicy:     cfg.TerminalResizePolicy,
+		PingInterval:     cfg.TerminalPingInterval,
+		PongTimeout:      cfg.TerminalPongTimeout,
+		CgroupRoot:       cfg.TerminalCgroupRoot,
+		Env: terminal.EnvPolicy{
+			Allow: utils.SplitList(cfg.TerminalEnvAllow),
+			Deny:  utils.SplitList(cfg.TerminalEnvDeny),
+		},
+	})
+	terminalHandler.Register(api)
 	handler.NewGitHandler().Register(api)
 
 	distFS, err := ui.GetDistFS()
@@ -132,4 +175,5 @@ func main() {
 	if err := srv.Shutdown(shutdownCtx); err != nil {
 		log.Error().Err(err).Msg("Server shutdown error")
 	}
+	terminalHandler.Shutdown()
 }
@@ -32,7 +32,7 @@ func main() {
 		log.Fatalf("failed to connect database: %v", err)
 	}
 
-	if err := db.AutoMigrate(&model.TerminalSession{}, &model.TerminalHistory{}); err != nil {
+	if err := db.AutoMigrate(&model.TerminalSession{}, &model.TerminalHistory{}, &model.TerminalProfile{}, &model.TerminalRecording{}, &model.KV{}, &model.PushSubscription{}, &model.SSHHost{}, &model.TerminalTrigger{}); err != nil {
 		log.Fatalf("failed to migrate: %v", err)
 	}
 
@@ -114,7 +114,7 @@ func main() {
 			return
 		}
 
-		conn, err := manager.Attach(id, wsConn)
+		conn, err := manager.Attach(id, wsConn, terminal.AttachOptions{ReadOnly: c.Query("mode") == "view"})
 		if err != nil {
 			log.Printf("attach error: %v", err)
 			wsConn.Close()